@@ -17,6 +17,7 @@ import (
 	"github.com/palomachain/pigeon/chain/paloma"
 	"github.com/palomachain/pigeon/config"
 	"github.com/palomachain/pigeon/health"
+	"github.com/palomachain/pigeon/internal/store"
 	"github.com/palomachain/pigeon/relayer"
 	"github.com/palomachain/pigeon/util/time"
 	log "github.com/sirupsen/logrus"
@@ -38,6 +39,8 @@ var (
 
 	_evmFactory *evm.Factory
 
+	_store *store.Store
+
 	_timeAdapter time.Time
 
 	_healthCheckService *health.Service
@@ -67,7 +70,15 @@ func Relayer() *relayer.Relayer {
 			Time(),
 			relayer.Config{
 				KeepAliveLoopTimeout:    30 * gotime.Second,
-				KeepAliveBlockThreshold: 30,
+				KeepAliveBlockThreshold: defaultValue(Config().Relayer.KeepAliveBlockThreshold, 30),
+				SubscribeToPalomaEvents: Config().Paloma.SubscribeToEvents,
+				PalomaEventsMaxIdle:     Config().Paloma.EventsMaxIdle,
+				Loops:                   Config().Relayer.Loops,
+				CircuitBreaker:          Config().Relayer.CircuitBreaker,
+				Scheduling:              Config().Relayer.Scheduling,
+				// has to be shorter than the time pigeon waits after
+				// the kill signal before it exits forcefully
+				ShutdownTimeout: 25 * gotime.Second,
 			},
 		)
 	}
@@ -92,12 +103,28 @@ func SetConfigPath(path string) {
 
 func EvmFactory() *evm.Factory {
 	if _evmFactory == nil {
-		_evmFactory = evm.NewFactory(PalomaClient())
+		_evmFactory = evm.NewFactory(PalomaClient(), Store())
 	}
 
 	return _evmFactory
 }
 
+func Store() *store.Store {
+	if _store == nil {
+		dir := Config().DataDir.Path()
+		st, err := store.Open(dir)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":  err,
+				"path": dir,
+			}).Fatal("couldn't open data directory")
+		}
+		_store = st
+	}
+
+	return _store
+}
+
 func Config() *config.Root {
 	if len(_configPath) == 0 {
 		log.Fatal("config file path is not set")
@@ -141,7 +168,7 @@ func PalomaClient() *paloma.Client {
 		_palomaClient = &paloma.Client{
 			L:             lensClient,
 			GRPCClient:    paloma.GRPCClientDowner{W: lensClient},
-			MessageSender: paloma.MessageSenderDowner{W: lensClient},
+			MessageSender: &paloma.MessageSenderSerializer{W: paloma.MessageSenderDowner{W: lensClient}},
 			PalomaConfig:  palomaConfig,
 		}
 		_palomaClient.Init()
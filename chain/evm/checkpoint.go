@@ -0,0 +1,75 @@
+package evm
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CheckpointStore persists how far the chains have been scanned for gravity
+// events.
+type CheckpointStore interface {
+	Get(key []byte) ([]byte, bool, error)
+	Put(key, value []byte) error
+}
+
+// eventCheckpoint is how far the gravity events of a chain have been
+// claimed. Paloma acknowledged every event emitted before the log with the
+// given index in the given block, the last of them with the given nonce.
+type eventCheckpoint struct {
+	block uint64
+	index uint
+	nonce uint64
+}
+
+// checkpoints persist how far the gravity events of a chain have been
+// claimed, so that a restarted pigeon resumes where it stopped instead of
+// missing the events emitted while it was down. As the compass contracts of
+// a chain share the nonce sequence, so do they share the checkpoint.
+type checkpoints struct {
+	store            CheckpointStore
+	chainReferenceID string
+}
+
+func newCheckpoints(store CheckpointStore, chainReferenceID string) *checkpoints {
+	if store == nil {
+		return nil
+	}
+	return &checkpoints{store: store, chainReferenceID: chainReferenceID}
+}
+
+func (c *checkpoints) key() []byte {
+	return []byte(fmt.Sprintf("checkpoint/%s/gravity-events", c.chainReferenceID))
+}
+
+// load returns the checkpoint, and false if there is none.
+func (c *checkpoints) load() (eventCheckpoint, bool, error) {
+	if c == nil {
+		return eventCheckpoint{}, false, nil
+	}
+
+	value, found, err := c.store.Get(c.key())
+	if err != nil || !found {
+		return eventCheckpoint{}, false, err
+	}
+	if len(value) != 20 {
+		return eventCheckpoint{}, false, ErrInvalidCheckpoint.Format(c.key())
+	}
+
+	return eventCheckpoint{
+		block: binary.BigEndian.Uint64(value),
+		index: uint(binary.BigEndian.Uint32(value[8:])),
+		nonce: binary.BigEndian.Uint64(value[12:]),
+	}, true, nil
+}
+
+func (c *checkpoints) save(cp eventCheckpoint) error {
+	if c == nil {
+		return nil
+	}
+
+	value := binary.BigEndian.AppendUint64(nil, cp.block)
+	value = binary.BigEndian.AppendUint32(value, uint32(cp.index))
+	value = binary.BigEndian.AppendUint64(value, cp.nonce)
+
+	return c.store.Put(c.key(), value)
+}
@@ -28,8 +28,10 @@ import (
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/palomachain/paloma/x/evm/types"
 	gravitytypes "github.com/palomachain/paloma/x/gravity/types"
+	"github.com/palomachain/pigeon/chain"
 	compassABI "github.com/palomachain/pigeon/chain/evm/abi/compass"
 	"github.com/palomachain/pigeon/config"
 	"github.com/palomachain/pigeon/errors"
@@ -108,7 +110,9 @@ type PalomaClienter interface {
 	SendBatchSendToEVMClaim(ctx context.Context, claim gravitytypes.MsgBatchSendToEthClaim) error
 	SendSendToPalomaClaim(ctx context.Context, claim gravitytypes.MsgSendToPalomaClaim) error
 	QueryGetLastEventNonce(ctx context.Context, orchestrator string) (uint64, error)
+	QueryGetLastObservedEthBlock(ctx context.Context) (uint64, error)
 	QueryBatchRequestByNonce(ctx context.Context, nonce uint64, contract string) (gravitytypes.OutgoingTxBatch, error)
+	QueryMessagesForRelaying(ctx context.Context, queueTypeName string) ([]chain.MessageWithSignatures, error)
 }
 
 type Client struct {
@@ -119,6 +123,13 @@ type Client struct {
 
 	conn   ethClientConn
 	arbcon *arbclient.Client
+	// rpc is the pool of RPC endpoints conn spreads the calls over. It is
+	// nil when conn is a single endpoint.
+	rpc *rpcPool
+
+	nonces *nonceManager
+	txs    *txManager
+	gas    gasStrategy
 
 	paloma    PalomaClienter
 	mevClient mevClient
@@ -128,6 +139,16 @@ func (c Client) GetEthClient() ethClientConn {
 	return c.conn
 }
 
+// GetQuorumEthClient returns a client whose contract calls, log queries and
+// block lookups must be agreed on by the configured quorum of RPC
+// endpoints.
+func (c Client) GetQuorumEthClient() ethClientConn {
+	if c.rpc == nil {
+		return c.conn
+	}
+	return quorumPool{c.rpc}
+}
+
 var _ ethClientConn = &ethclient.Client{}
 
 //go:generate mockery --name=mevClient --inpackage --testonly
@@ -143,6 +164,9 @@ type ethClientConn interface {
 	BlockByHash(ctx context.Context, hash common.Hash) (*etherumtypes.Block, error)
 	BlockNumber(ctx context.Context) (uint64, error)
 	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*etherum.FeeHistory, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*etherumtypes.Receipt, error)
 }
 
 type CompassBindingCaller interface {
@@ -191,11 +215,27 @@ func (c *Client) init() error {
 
 		whoops.Assert(c.keystore.Unlock(acc, config.KeyringPassword(c.config.KeyringPassEnvName)))
 
-		c.conn = whoops.Must(ethclient.Dial(c.config.BaseRPCURL))
+		gas, err := newGasStrategy(c.config)
+		if err != nil {
+			whoops.Assert(errors.Unrecoverable(err))
+		}
+		c.gas = gas
+
+		if _, ok := confirmationBlockTags[c.config.ConfirmationBlockTag]; !ok {
+			whoops.Assert(errors.Unrecoverable(ErrInvalidConfirmationTag.Format(c.config.ConfirmationBlockTag)))
+		}
+
+		c.rpc = whoops.Must(dialRPCPool(rpcURLs(c.config), c.config.RPCQuorum))
+		c.conn = c.rpc
+
+		c.txs = newTxManager(c.conn, c.addr, func(tx *ethtypes.Transaction) (*ethtypes.Transaction, error) {
+			return c.keystore.SignTx(acc, tx, tx.ChainId())
+		}, c.gas, c.config.EVMSpecificClientConfig)
 	})
 }
 
 func (c *Client) injectArbClient() error {
+	// the arbitrum client only talks to the base endpoint
 	ac, err := arbclient.Dial(c.config.BaseRPCURL)
 	if err != nil {
 		return err
@@ -209,28 +249,31 @@ func (c *Client) isArbitrumClient() bool {
 	return c.arbcon != nil
 }
 
-func (c *Client) newCompass(addr common.Address) (CompassBinding, error) {
-	return compassABI.NewCompass(addr, c.conn)
+func (c *Client) newCompass(addr common.Address, backend bind.ContractBackend) (CompassBinding, error) {
+	return compassABI.NewCompass(addr, backend)
 }
 
 //go:generate mockery --name=ethClienter --inpackage --testonly
 type ethClienter interface {
 	bind.ContractBackend
+	gasPricingBackend
 }
 
 type executeSmartContractIn struct {
 	ethClient ethClienter
 	mevClient mevClient
 
-	chainID       *big.Int
-	gasAdjustment float64
-	txType        uint8
+	chainID *big.Int
+	gas     gasStrategy
 
 	abi      abi.ABI
 	contract common.Address
 
 	signingAddr common.Address
 	keystore    *keystore.KeyStore
+	// nonces allocates the nonce of the transaction. Without it, the
+	// pending nonce is used.
+	nonces *nonceManager
 
 	method    string
 	arguments []any
@@ -241,12 +284,11 @@ func callSmartContract(
 	args executeSmartContractIn,
 ) (*etherumtypes.Transaction, error) {
 	logger := liblog.WithContext(ctx).WithFields(log.Fields{
-		"chain-id":        args.chainID,
-		"contract-addr":   args.contract,
-		"method":          args.method,
-		"arguments":       args.arguments,
-		"gas-adjustments": args.gasAdjustment,
-		"signing-addr":    args.signingAddr,
+		"chain-id":      args.chainID,
+		"contract-addr": args.contract,
+		"method":        args.method,
+		"arguments":     args.arguments,
+		"signing-addr":  args.signingAddr,
 	})
 	return whoops.TryVal(func() *etherumtypes.Transaction {
 		packedBytes, err := args.abi.Pack(
@@ -260,51 +302,14 @@ func callSmartContract(
 		}
 		whoops.Assert(err)
 
-		nonce, err := args.ethClient.PendingNonceAt(ctx, args.signingAddr)
+		price, err := args.gas.price(ctx, args.ethClient)
 		if err != nil {
 			logger.
 				WithField("error", err).
-				Error("callSmartContract: error calculating pending nonce")
+				Error("callSmartContract: error pricing the transaction")
 		}
 		whoops.Assert(err)
 
-		gasPrice, err := args.ethClient.SuggestGasPrice(ctx)
-		if err != nil {
-			logger.
-				WithField("error", err).
-				Error("callSmartContract: error calculating suggested gas price")
-		}
-		whoops.Assert(err)
-
-		// adjusting the gas price
-		if args.txType != 2 && args.gasAdjustment > 1.0 {
-			gasAdj := big.NewFloat(args.gasAdjustment)
-			gasAdj = gasAdj.Mul(gasAdj, new(big.Float).SetInt(gasPrice))
-			gasPrice, _ = gasAdj.Int(big.NewInt(0))
-		}
-
-		var gasTipCap *big.Int
-
-		if args.txType == 2 {
-			gasPrice = gasPrice.Mul(gasPrice, big.NewInt(2)) // double gas price for EIP-1559 tx
-			gasTipCap, err = args.ethClient.SuggestGasTipCap(ctx)
-			if err != nil {
-				logger.
-					WithField("error", err).
-					Error("callSmartContract: error calling SuggestGasTipCap")
-			}
-			whoops.Assert(err)
-			gasPrice = gasPrice.Add(gasPrice, gasTipCap)
-			logger.WithFields(log.Fields{
-				"gas-max-price": gasPrice,
-				"gas-max-tip":   gasTipCap,
-			}).Debug("adjusted eip-1559 gas price")
-		} else {
-			logger.WithFields(log.Fields{
-				"gas-price": gasPrice,
-			}).Debug("adjusted legacy gas price")
-		}
-
 		boundContract := bind.NewBoundContract(
 			args.contract,
 			args.abi,
@@ -325,12 +330,64 @@ func callSmartContract(
 		}
 		whoops.Assert(err)
 
-		txOpts.Nonce = big.NewInt(int64(nonce))
 		txOpts.From = args.signingAddr
 
-		if args.txType == 2 {
-			txOpts.GasFeeCap = gasPrice
-			txOpts.GasTipCap = gasTipCap
+		if price.isDynamic() {
+			txOpts.GasFeeCap = price.gasFeeCap
+			txOpts.GasTipCap = price.gasTipCap
+		} else {
+			txOpts.GasPrice = price.gasPrice
+		}
+
+		// The call is simulated against the pending block first, so that a
+		// transaction which would revert is never sent. The estimate is used
+		// as the gas limit.
+		gasLimit, err := args.ethClient.EstimateGas(ctx, ethereum.CallMsg{
+			From:      args.signingAddr,
+			To:        &args.contract,
+			GasPrice:  txOpts.GasPrice,
+			GasFeeCap: txOpts.GasFeeCap,
+			GasTipCap: txOpts.GasTipCap,
+			Data:      packedBytes,
+		})
+		if err != nil {
+			if reason, ok := revertFromError(&args.abi, err); ok {
+				logger.
+					WithField("revert-reason", reason.String()).
+					Warn("callSmartContract: transaction would revert, not sending it")
+				whoops.Assert(&revertError{reason: reason, err: err})
+			}
+			logger.
+				WithField("error", err).
+				Error("callSmartContract: error estimating gas")
+		}
+		whoops.Assert(err)
+		txOpts.GasLimit = gasLimit
+
+		// The nonce is allocated only once the transaction is about to be
+		// sent. A transaction which fails before that would otherwise leave a
+		// gap behind the ones sent in parallel, which are stuck until the gap
+		// is filled.
+		pendingNonce := func(ctx context.Context) (uint64, error) {
+			return args.ethClient.PendingNonceAt(ctx, args.signingAddr)
+		}
+		nonce, err := args.nonces.allocate(ctx, pendingNonce)
+		if err != nil {
+			logger.
+				WithField("error", err).
+				Error("callSmartContract: error calculating pending nonce")
+		}
+		whoops.Assert(err)
+
+		sent := false
+		defer func() {
+			if !sent {
+				args.nonces.release(nonce)
+			}
+		}()
+		txOpts.Nonce = new(big.Int).SetUint64(nonce)
+
+		if price.isDynamic() {
 			logger.WithFields(log.Fields{
 				"gas-limit":     txOpts.GasLimit,
 				"gas-max-price": txOpts.GasFeeCap,
@@ -339,7 +396,6 @@ func callSmartContract(
 				"from":          txOpts.From,
 			}).Debug("executing eip-1559 tx")
 		} else {
-			txOpts.GasPrice = gasPrice
 			logger.WithFields(log.Fields{
 				"gas-limit": txOpts.GasLimit,
 				"gas-price": txOpts.GasPrice,
@@ -354,6 +410,9 @@ func callSmartContract(
 			txOpts.NoSend = true
 		}
 		tx, err := boundContract.RawTransact(txOpts, packedBytes)
+		if isNonceError(err) {
+			args.nonces.resync()
+		}
 		if err != nil {
 			logger.
 				WithField("error", err).
@@ -372,13 +431,14 @@ func callSmartContract(
 				whoops.Assert(err)
 			}
 		}
+		sent = true
 
 		msg := "executed"
 		logger.WithField("txOps-nosend", txOpts.NoSend).Info("Checking for no send")
 		if txOpts.NoSend {
 			msg = "relayed"
 		}
-		if args.txType == 2 {
+		if price.isDynamic() {
 			logger.WithFields(log.Fields{
 				"tx-hash":          tx.Hash(),
 				"tx-gas-limit":     tx.Gas(),
@@ -427,7 +487,7 @@ func (c *Client) BlockByHash(ctx context.Context, blockHash common.Hash) (*ethty
 	if c.isArbitrumClient() {
 		return c.wrapArbitrumBlockByHash(ctx, blockHash)
 	}
-	return c.conn.BlockByHash(ctx, blockHash)
+	return c.GetQuorumEthClient().BlockByHash(ctx, blockHash)
 }
 
 func (c *Client) wrapArbitrumBlockByHash(ctx context.Context, blockHash common.Hash) (*ethtypes.Block, error) {
@@ -601,15 +661,15 @@ func (c *Client) ExecuteSmartContract(
 	return callSmartContract(
 		ctx,
 		executeSmartContractIn{
-			ethClient:     c.conn,
-			mevClient:     mevClient,
-			chainID:       chainID,
-			gasAdjustment: c.config.GasAdjustment,
-			txType:        c.config.TxType,
-			abi:           contractAbi,
-			contract:      addr,
-			signingAddr:   c.addr,
-			keystore:      c.keystore,
+			ethClient:   c.conn,
+			mevClient:   mevClient,
+			chainID:     chainID,
+			gas:         c.gas,
+			abi:         contractAbi,
+			contract:    addr,
+			signingAddr: c.addr,
+			keystore:    c.keystore,
+			nonces:      c.nonces,
 
 			method:    method,
 			arguments: arguments,
@@ -617,6 +677,63 @@ func (c *Client) ExecuteSmartContract(
 	)
 }
 
+// EstimateContractCost returns how much, in wei, calling the method of the
+// contract would cost at the current gas price.
+func (c *Client) EstimateContractCost(
+	ctx context.Context,
+	contractAbi abi.ABI,
+	addr common.Address,
+	method string,
+	arguments []any,
+) (*big.Int, error) {
+	data, err := contractAbi.Pack(method, arguments...)
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := c.gas.price(ctx, c.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, err := c.conn.EstimateGas(ctx, etherum.CallMsg{
+		From: c.addr,
+		To:   &addr,
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).Mul(new(big.Int).SetUint64(gas), price.max()), nil
+}
+
+// TrackTransaction follows the transaction until its nonce has been used up.
+func (c *Client) TrackTransaction(tx *ethtypes.Transaction, hooks txHooks) {
+	if c.txs == nil {
+		return
+	}
+	c.txs.track(tx, hooks)
+}
+
+// hasPendingTransaction reports whether a tracked transaction which was sent
+// for ref is still pending.
+func (c *Client) hasPendingTransaction(ref string) bool {
+	if c == nil || c.txs == nil {
+		return false
+	}
+	return c.txs.has(ref)
+}
+
+// CheckTransactions resolves the tracked transactions which made it into a
+// block and replaces the ones which are stuck.
+func (c *Client) CheckTransactions(ctx context.Context) error {
+	if c.txs == nil {
+		return nil
+	}
+	return c.txs.check(ctx)
+}
+
 func (c *Client) BalanceAt(ctx context.Context, address common.Address, blockHeight uint64) (*big.Int, error) {
 	var bh *big.Int
 	if blockHeight > 0 {
@@ -681,12 +798,53 @@ func (c *Client) FindCurrentBlockNumber(ctx context.Context) (*big.Int, error) {
 	return header.Number, nil
 }
 
+func (c *Client) FindCurrentBlockTime(ctx context.Context) (time.Time, error) {
+	header, err := c.conn.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(header.Time), 0).UTC(), nil
+}
+
+// confirmationBlockTags are the block tags the confirmed block can be
+// looked up by. No tag means that the confirmation depth is used.
+var confirmationBlockTags = map[string]*big.Int{
+	"":          nil,
+	"safe":      big.NewInt(int64(rpc.SafeBlockNumber)),
+	"finalized": big.NewInt(int64(rpc.FinalizedBlockNumber)),
+}
+
+// FindConfirmedBlockNumber returns the highest block which is deep enough in
+// the chain for its logs to be acted upon.
+func (c *Client) FindConfirmedBlockNumber(ctx context.Context) (*big.Int, error) {
+	if tag := confirmationBlockTags[c.config.ConfirmationBlockTag]; tag != nil {
+		header, err := c.conn.HeaderByNumber(ctx, tag)
+		if err == nil {
+			return header.Number, nil
+		}
+		liblog.WithContext(ctx).WithError(err).WithField("tag", c.config.ConfirmationBlockTag).
+			Warn("couldn't get block by its tag, falling back to the confirmation depth")
+	}
+
+	header, err := c.conn.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(big.Int).Sub(header.Number, new(big.Int).SetUint64(c.config.ConfirmationDepth))
+	if res.Sign() < 0 {
+		res.SetInt64(0)
+	}
+
+	return res, nil
+}
+
 func (c *Client) LastValsetID(ctx context.Context, addr common.Address) (*big.Int, error) {
 	log.
 		WithField("address", addr.String()).
 		Debug("called LastValsetID in EVM client")
 
-	cmps, err := c.newCompass(addr)
+	cmps, err := c.newCompass(addr, c.GetQuorumEthClient())
 	if err != nil {
 		log.
 			WithField("error", err.Error()).
@@ -2,6 +2,7 @@ package evm
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"testing"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/palomachain/pigeon/config"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -63,9 +66,9 @@ func TestExecutingSmartContract(t *testing.T) {
 
 				ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
 
-				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+				ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
 
-				ethMock.On("PendingCodeAt", mock.Anything, args.contract).Return([]byte("a"), nil)
+				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
 
 				ethMock.On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(222), nil)
 
@@ -80,6 +83,14 @@ func TestExecutingSmartContract(t *testing.T) {
 			setup: func(t *testing.T, args *executeSmartContractIn) {
 				ethMock := newMockEthClienter(t)
 
+				ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
+
+				ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
+
+				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+
+				ethMock.On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(222), nil)
+
 				ethMock.On("PendingNonceAt", mock.Anything, mock.Anything).Return(uint64(0), fakeErr)
 
 				args.ethClient = ethMock
@@ -95,9 +106,9 @@ func TestExecutingSmartContract(t *testing.T) {
 
 				ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
 
-				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+				ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
 
-				ethMock.On("PendingCodeAt", mock.Anything, args.contract).Return([]byte("a"), nil)
+				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
 
 				ethMock.On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(222), nil)
 
@@ -106,14 +117,29 @@ func TestExecutingSmartContract(t *testing.T) {
 				args.ethClient = ethMock
 			},
 		},
+		{
+			name:        "a transaction which would revert is not sent",
+			expectedErr: ErrTxWouldRevert,
+			setup: func(t *testing.T, args *executeSmartContractIn) {
+				ethMock := newMockEthClienter(t)
+
+				ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
+
+				ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
+
+				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+
+				ethMock.On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(0), fakeJsonRpcError("0x"))
+
+				args.ethClient = ethMock
+			},
+		},
 		{
 			name:        "gas estimation returns an error and it returns error back",
 			expectedErr: fakeErr,
 			setup: func(t *testing.T, args *executeSmartContractIn) {
 				ethMock := newMockEthClienter(t)
 
-				ethMock.On("PendingNonceAt", mock.Anything, mock.Anything).Return(uint64(333), nil)
-
 				ethMock.On("SuggestGasPrice", mock.Anything).Return(nil, fakeErr)
 
 				args.ethClient = ethMock
@@ -128,9 +154,9 @@ func TestExecutingSmartContract(t *testing.T) {
 
 				ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
 
-				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+				ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
 
-				ethMock.On("PendingCodeAt", mock.Anything, args.contract).Return([]byte("a"), nil)
+				ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
 
 				ethMock.On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(222), nil)
 
@@ -150,15 +176,14 @@ func TestExecutingSmartContract(t *testing.T) {
 			ks.Unlock(acc, "bla")
 			contract := StoredContracts()["simple"]
 			args := executeSmartContractIn{
-				chainID:       big.NewInt(1337),
-				gasAdjustment: 2.0,
-				txType:        2,
-				contract:      common.HexToAddress("0xBABA"),
-				signingAddr:   acc.Address,
-				abi:           contract.ABI,
-				method:        "store",
-				arguments:     []any{big.NewInt(123)},
-				keystore:      ks,
+				chainID:     big.NewInt(1337),
+				gas:         suggestedGasStrategy{dynamic: true, gasAdjustment: 2.0},
+				contract:    common.HexToAddress("0xBABA"),
+				signingAddr: acc.Address,
+				abi:         contract.ABI,
+				method:      "store",
+				arguments:   []any{big.NewInt(123)},
+				keystore:    ks,
 			}
 
 			tt.setup(t, &args)
@@ -173,6 +198,74 @@ func TestExecutingSmartContract(t *testing.T) {
 	}
 }
 
+func TestExecutingSmartContractsInParallel(t *testing.T) {
+	cryptokey, err := crypto.HexToECDSA(privateKeyBob)
+	require.NoError(t, err)
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.StandardScryptN, keystore.StandardScryptP)
+	acc, err := ks.ImportECDSA(cryptokey, "bla")
+	require.NoError(t, err)
+	require.NoError(t, ks.Unlock(acc, "bla"))
+	contract := StoredContracts()["simple"]
+
+	ethMock := newMockEthClienter(t)
+	ethMock.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(444), nil)
+	ethMock.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(4)}}, nil)
+	ethMock.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+	ethMock.On("PendingNonceAt", mock.Anything, mock.Anything).Return(uint64(333), nil).Once()
+
+	calling := func(value int64) interface{} {
+		data := whoops.Must(contract.ABI.Pack("store", big.NewInt(value)))
+		return mock.MatchedBy(func(msg ethereum.CallMsg) bool {
+			return string(msg.Data) == string(data)
+		})
+	}
+	// the simulation of the first call only fails once the second call was
+	// sent
+	firstSimulating, secondSent := make(chan struct{}), make(chan struct{})
+	ethMock.On("EstimateGas", mock.Anything, calling(1)).Run(func(mock.Arguments) {
+		close(firstSimulating)
+		<-secondSent
+	}).Return(uint64(0), fakeJsonRpcError("0x"))
+	ethMock.On("EstimateGas", mock.Anything, calling(2)).Return(uint64(222), nil)
+	ethMock.On("EstimateGas", mock.Anything, calling(3)).Return(uint64(222), nil)
+
+	var sentNonces []uint64
+	ethMock.On("SendTransaction", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sentNonces = append(sentNonces, args.Get(1).(*ethtypes.Transaction).Nonce())
+	}).Return(nil)
+
+	nonces := &nonceManager{}
+	send := func(value int64) error {
+		_, err := callSmartContract(context.Background(), executeSmartContractIn{
+			ethClient:   ethMock,
+			chainID:     big.NewInt(1337),
+			gas:         suggestedGasStrategy{dynamic: true, gasAdjustment: 2.0},
+			contract:    common.HexToAddress("0xBABA"),
+			signingAddr: acc.Address,
+			abi:         contract.ABI,
+			method:      "store",
+			arguments:   []any{big.NewInt(value)},
+			keystore:    ks,
+			nonces:      nonces,
+		})
+		return err
+	}
+
+	failed := make(chan error)
+	go func() {
+		failed <- send(1)
+	}()
+	<-firstSimulating
+	require.NoError(t, send(2))
+	close(secondSent)
+	require.ErrorIs(t, <-failed, ErrTxWouldRevert)
+
+	// the failed call never took a nonce, so there is no gap behind the
+	// sent one
+	require.NoError(t, send(3))
+	require.Equal(t, []uint64{333, 334}, sentNonces)
+}
+
 func TestFilterLogs(t *testing.T) {
 	fakeErr := whoops.String("fake error")
 
@@ -594,3 +687,42 @@ func TestFindingTheBlockNearestToTime(t *testing.T) {
 		})
 	}
 }
+
+func TestFindingConfirmedBlockNumber(t *testing.T) {
+	ctx := context.Background()
+	finalized := big.NewInt(int64(rpc.FinalizedBlockNumber))
+
+	t.Run("the confirmation depth is subtracted from the head", func(t *testing.T) {
+		m := newMockEthClientConn(t)
+		c := Client{conn: m, config: config.EVM{EVMSpecificClientConfig: config.EVMSpecificClientConfig{ConfirmationDepth: 12}}}
+		m.On("HeaderByNumber", mock.Anything, (*big.Int)(nil)).Return(&ethtypes.Header{Number: big.NewInt(100)}, nil)
+
+		res, err := c.FindConfirmedBlockNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(88), res)
+	})
+
+	t.Run("the block tag is used when the chain supports it", func(t *testing.T) {
+		m := newMockEthClientConn(t)
+		c := Client{conn: m, config: config.EVM{EVMSpecificClientConfig: config.EVMSpecificClientConfig{ConfirmationBlockTag: "finalized"}}}
+		m.On("HeaderByNumber", mock.Anything, finalized).Return(&ethtypes.Header{Number: big.NewInt(64)}, nil)
+
+		res, err := c.FindConfirmedBlockNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(64), res)
+	})
+
+	t.Run("chains without block tags fall back to the confirmation depth", func(t *testing.T) {
+		m := newMockEthClientConn(t)
+		c := Client{conn: m, config: config.EVM{EVMSpecificClientConfig: config.EVMSpecificClientConfig{
+			ConfirmationBlockTag: "finalized",
+			ConfirmationDepth:    200,
+		}}}
+		m.On("HeaderByNumber", mock.Anything, finalized).Return(nil, errors.New("invalid block number"))
+		m.On("HeaderByNumber", mock.Anything, (*big.Int)(nil)).Return(&ethtypes.Header{Number: big.NewInt(100)}, nil)
+
+		res, err := c.FindConfirmedBlockNumber(ctx)
+		require.NoError(t, err)
+		require.Zero(t, res.Sign())
+	})
+}
@@ -2,10 +2,12 @@ package evm
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	goerrors "errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/VolumeFi/whoops"
@@ -16,11 +18,11 @@ import (
 	etherumtypes "github.com/ethereum/go-ethereum/core/types"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/rpc"
 	evmtypes "github.com/palomachain/paloma/x/evm/types"
 	gravitytypes "github.com/palomachain/paloma/x/gravity/types"
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/internal/liblog"
+	"github.com/palomachain/pigeon/internal/queue"
 	"github.com/palomachain/pigeon/util/slice"
 	log "github.com/sirupsen/logrus"
 )
@@ -29,23 +31,45 @@ const (
 	SignedMessagePrefix = "\x19Ethereum Signed Message:\n32"
 )
 
+// valsetUpdateWaitTimeout and valsetUpdatePollInterval bound the wait for
+// a preempting valset update to make it into a block.
+var (
+	valsetUpdateWaitTimeout  = time.Minute
+	valsetUpdatePollInterval = 2 * time.Second
+)
+
+// maxGravityScanWindow is the most blocks scanned for gravity events in one
+// go.
+const maxGravityScanWindow = 5000
+
+// lookbackBlocks is how far back compass is looked through for the events of
+// a message when they are not indexed.
+const lookbackBlocks = 9999
+
+var (
+	logicCallEventTopic     = crypto.Keccak256Hash([]byte("LogicCallEvent(address,bytes,uint256)"))
+	valsetUpdatedEventTopic = crypto.Keccak256Hash([]byte("ValsetUpdated(bytes32,uint256)"))
+	batchSendEventTopic     = crypto.Keccak256Hash([]byte("BatchSendEvent(address,uint256)"))
+	sendToPalomaEventTopic  = crypto.Keccak256Hash([]byte("SendToPalomaEvent(address,address,string,uint256)"))
+)
+
 //go:generate mockery --name=evmClienter --inpackage --testonly
 type evmClienter interface {
 	FilterLogs(ctx context.Context, fq ethereum.FilterQuery, currBlockHeight *big.Int, fn func(logs []ethtypes.Log) bool) (bool, error)
 	ExecuteSmartContract(ctx context.Context, chainID *big.Int, contractAbi abi.ABI, addr common.Address, mevRelay bool, method string, arguments []any) (*etherumtypes.Transaction, error)
+	EstimateContractCost(ctx context.Context, contractAbi abi.ABI, addr common.Address, method string, arguments []any) (*big.Int, error)
 	DeployContract(ctx context.Context, chainID *big.Int, rawABI string, bytecode, constructorInput []byte) (contractAddr common.Address, tx *ethtypes.Transaction, err error)
 	TransactionByHash(ctx context.Context, txHash common.Hash) (*ethtypes.Transaction, bool, error)
 
 	BalanceAt(ctx context.Context, address common.Address, blockHeight uint64) (*big.Int, error)
 	FindBlockNearestToTime(ctx context.Context, startingHeight uint64, when time.Time) (uint64, error)
 	FindCurrentBlockNumber(ctx context.Context) (*big.Int, error)
+	FindCurrentBlockTime(ctx context.Context) (time.Time, error)
+	FindConfirmedBlockNumber(ctx context.Context) (*big.Int, error)
+	TrackTransaction(tx *ethtypes.Transaction, hooks txHooks)
 	LastValsetID(ctx context.Context, addr common.Address) (*big.Int, error)
 	GetEthClient() ethClientConn
-}
-
-type observedHeights struct {
-	batchSendEvent    int64
-	sendToPalomaEvent int64
+	GetQuorumEthClient() ethClientConn
 }
 
 type compass struct {
@@ -59,8 +83,32 @@ type compass struct {
 
 	startingBlockHeight int64
 
-	chainID                  *big.Int
-	lastObservedBlockHeights observedHeights
+	chainID *big.Int
+
+	// parallelLogicCalls is the maximum number of SubmitLogicCall messages
+	// relayed at the same time.
+	parallelLogicCalls int
+
+	// gravityWatcher receives the gravity events through a log
+	// subscription. It is nil when the events are polled for.
+	gravityWatcher *logWatcher
+
+	// checkpoints persist how far the gravity events have been claimed.
+	// They are nil when nothing is persisted.
+	checkpoints *checkpoints
+
+	// eventIndex keeps the events compass emitted. It is nil when compass
+	// is asked for its logs every time.
+	eventIndex *eventIndex
+
+	// eventNonces are shared with the compass the chain was upgraded from,
+	// as the events of both are claimed in the same sequence. They also
+	// keep how far the gravity events have been scanned for.
+	eventNonces *eventNonces
+
+	// profitability is nil when gravity batches are relayed whatever they
+	// cost.
+	profitability *batchProfitability
 }
 
 func newCompassClient(
@@ -83,6 +131,7 @@ func newCompassClient(
 		compassAbi:        compassAbi,
 		paloma:            paloma,
 		evm:               evm,
+		eventNonces:       &eventNonces{},
 	}
 }
 
@@ -170,6 +219,7 @@ func (t compass) submitLogicCall(
 	queueTypeName string,
 	msg *evmtypes.SubmitLogicCall,
 	origMessage chain.MessageWithSignatures,
+	state *chainState,
 ) (*ethtypes.Transaction, error) {
 	return whoops.TryVal(func() *ethtypes.Transaction {
 		executed, err := t.isArbitraryCallAlreadyExecuted(ctx, origMessage.ID)
@@ -178,10 +228,19 @@ func (t compass) submitLogicCall(
 			return nil
 		}
 
-		valsetID, err := t.findLastValsetMessageID(ctx)
-		whoops.Assert(err)
+		if deadline := msg.GetDeadline(); deadline > 0 {
+			blockTime, err := state.blockTime(ctx, t)
+			whoops.Assert(err)
+			// compass reverts once the block timestamp is past the deadline,
+			// and the transaction can't make it into a block older than the
+			// latest one.
+			if blockTime.Unix() >= deadline {
+				whoops.Assert(t.reportExpired(ctx, queueTypeName, origMessage.ID, deadline, blockTime))
+				return nil
+			}
+		}
 
-		valset, err := t.paloma.QueryGetEVMValsetByID(ctx, valsetID, t.ChainReferenceID)
+		valset, err := state.valset(ctx, t)
 		whoops.Assert(err)
 
 		consensusReached := isConsensusReached(ctx, valset, origMessage)
@@ -266,29 +325,137 @@ func (t compass) uploadSmartContract(
 	})
 }
 
+// SetErrorData reports an error which happened while relaying a message to
+// Paloma. Reverts are decoded using the compass ABI and categorised, so that
+// a deadline passing can be told apart from a signature not being accepted.
+// It returns true if the error was caused by the smart contract.
 func (t compass) SetErrorData(ctx context.Context, queueTypeName string, msgID uint64, errToProcess error) (bool, error) {
-	var jsonRpcErr rpc.DataError
-	if !errors.As(errToProcess, &jsonRpcErr) {
-		err := t.paloma.SetErrorData(ctx, queueTypeName, msgID, []byte(errToProcess.Error()))
-		return false, err
-	} else {
+	errData, isSmartContractError := newErrorData(t.compassAbi, errToProcess)
+
+	if isSmartContractError {
 		liblog.WithContext(ctx).WithFields(
 			log.Fields{
 				"queue-type-name": queueTypeName,
 				"message-id":      msgID,
-				"error-message":   jsonRpcErr.Error(),
+				"error-message":   errData.Message,
+				"error-category":  errData.Category,
+				"revert-kind":     errData.Revert.Kind,
 			},
 		).Warn("smart contract returned an error")
+	}
+
+	if err := t.reportErrorData(ctx, queueTypeName, msgID, errData); err != nil {
+		return false, err
+	}
+
+	return isSmartContractError, nil
+}
+
+// isLogicCallStillNeeded returns whether a pending logic call can still
+// succeed, which is not the case once it has been executed by someone else
+// or its deadline has passed.
+func (t compass) isLogicCallStillNeeded(msgID uint64, deadline int64) func(context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		executed, err := t.isArbitraryCallAlreadyExecuted(ctx, msgID)
+		if err != nil || executed {
+			return false, err
+		}
+
+		if deadline <= 0 {
+			return true, nil
+		}
 
-		err := t.paloma.SetErrorData(ctx, queueTypeName, msgID, []byte(jsonRpcErr.Error()))
+		blockTime, err := t.evm.FindCurrentBlockTime(ctx)
 		if err != nil {
 			return false, err
 		}
 
-		return true, nil
+		return blockTime.Unix() < deadline, nil
+	}
+}
+
+// isValsetUpdateStillNeeded returns whether a pending valset update would
+// still move compass forward.
+func (t compass) isValsetUpdateStillNeeded(valsetID uint64) func(context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		currentValsetID, err := t.findLastValsetMessageID(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		return currentValsetID < valsetID, nil
 	}
 }
 
+// messageTxRef identifies the transactions sent for a message.
+func messageTxRef(queueTypeName string, msgID uint64) string {
+	return fmt.Sprintf("%s/%d", queueTypeName, msgID)
+}
+
+// reportTxResult brings the final status of a relayed message's transaction
+// back to Paloma. The public access data is only set once the nonce has been
+// used up, as Paloma doesn't take it again and the transaction which made it
+// into the block might be a replacement. Reverted transactions are reported
+// as errors as well.
+func (t compass) reportTxResult(queueTypeName string, msgID uint64, sent *ethtypes.Transaction) func(context.Context, txResult) {
+	return func(ctx context.Context, res txResult) {
+		logger := liblog.WithContext(ctx).WithFields(log.Fields{
+			"queue-type-name": queueTypeName,
+			"message-id":      msgID,
+			"tx-hash":         sent.Hash(),
+			"status":          res.status,
+		})
+
+		var err error
+		switch res.status {
+		case txMined:
+			err = t.paloma.SetPublicAccessData(ctx, queueTypeName, msgID, res.tx.Hash().Bytes())
+		case txReverted:
+			err = t.paloma.SetPublicAccessData(ctx, queueTypeName, msgID, res.tx.Hash().Bytes())
+			if err == nil {
+				err = t.reportErrorData(ctx, queueTypeName, msgID, errorData{
+					Category: errorCategoryReverted,
+					Message:  ErrTxReverted.Format(res.tx.Hash()).Error(),
+					TxHash:   res.tx.Hash().Hex(),
+				})
+			}
+		default:
+			logger.Warn("transaction of the message didn't make it into a block, it will be relayed again")
+		}
+
+		if err != nil {
+			logger.WithError(err).Error("couldn't report the transaction status to Paloma")
+		}
+	}
+}
+
+// reportExpired lets Paloma know that the message wasn't relayed because its
+// deadline has passed on the target chain.
+func (t compass) reportExpired(ctx context.Context, queueTypeName string, msgID uint64, deadline int64, blockTime time.Time) error {
+	liblog.WithContext(ctx).WithFields(log.Fields{
+		"queue-type-name": queueTypeName,
+		"message-id":      msgID,
+		"deadline":        deadline,
+		"block-time":      blockTime.Unix(),
+	}).Warn("message expired, skipping it")
+
+	return t.reportErrorData(ctx, queueTypeName, msgID, errorData{
+		Category:  errorCategoryExpired,
+		Message:   ErrMessageExpired.Format(deadline, blockTime.Unix()).Error(),
+		Deadline:  deadline,
+		BlockTime: blockTime.Unix(),
+	})
+}
+
+func (t compass) reportErrorData(ctx context.Context, queueTypeName string, msgID uint64, errData errorData) error {
+	data, err := json.Marshal(errData)
+	if err != nil {
+		return err
+	}
+
+	return t.paloma.SetErrorData(ctx, queueTypeName, msgID, data)
+}
+
 func (t compass) findLastValsetMessageID(ctx context.Context) (uint64, error) {
 	logger := liblog.WithContext(ctx)
 	logger.Debug("fetching last valset message id")
@@ -302,19 +469,34 @@ func (t compass) findLastValsetMessageID(ctx context.Context) (uint64, error) {
 }
 
 func (t compass) isArbitraryCallAlreadyExecuted(ctx context.Context, messageID uint64) (bool, error) {
+	if t.eventIndex != nil && t.compassAbi != nil {
+		indexed := func() (bool, error) {
+			return t.eventIndex.hasLogicCall(messageID)
+		}
+		return t.findEvent(ctx, logicCallEventTopic, indexed, func(l etherumtypes.Log) bool {
+			event, err := t.compassAbi.Unpack("LogicCallEvent", l.Data)
+			if err != nil {
+				liblog.WithContext(ctx).WithError(err).WithField("tx-hash", l.TxHash).Warn("couldn't unpack logic call event")
+				return false
+			}
+			logMessageID, ok := event[2].(*big.Int)
+			return ok && logMessageID.Cmp(new(big.Int).SetUint64(messageID)) == 0
+		})
+	}
+
 	blockNumber, err := t.evm.FindCurrentBlockNumber(ctx)
 	if err != nil {
 		return false, err
 	}
 	fromBlock := *big.NewInt(0)
-	fromBlock.Sub(blockNumber, big.NewInt(9999))
+	fromBlock.Sub(blockNumber, big.NewInt(lookbackBlocks))
 	filter := ethereum.FilterQuery{
 		Addresses: []common.Address{
 			t.smartContractAddr,
 		},
 		Topics: [][]common.Hash{
 			{
-				crypto.Keccak256Hash([]byte("LogicCallEvent(address,bytes,uint256)")),
+				logicCallEventTopic,
 				common.Hash{},
 				common.Hash{},
 				crypto.Keccak256Hash(new(big.Int).SetInt64(int64(messageID)).Bytes()),
@@ -336,43 +518,98 @@ func (t compass) isArbitraryCallAlreadyExecuted(ctx context.Context, messageID u
 	return found, nil
 }
 
-func (t compass) gravityIsBatchAlreadyRelayed(ctx context.Context, batchNonce uint64) (bool, error) {
+// gravityIsBatchAlreadyRelayed returns true if compass accepted the batch, or
+// a later batch of the same token which makes it obsolete. Compass keeps the
+// last batch nonce of every token, and the batch send events are only looked
+// through when its ABI doesn't expose them.
+func (t compass) gravityIsBatchAlreadyRelayed(ctx context.Context, tokenContract common.Address, batchNonce uint64) (bool, error) {
+	if t.compassAbi != nil {
+		if _, ok := t.compassAbi.Methods["last_batch_id"]; ok {
+			lastBatchNonce, err := t.gravityLastBatchNonce(ctx, tokenContract)
+			if err != nil {
+				return false, err
+			}
+			return lastBatchNonce.Cmp(new(big.Int).SetUint64(batchNonce)) >= 0, nil
+		}
+	}
+
+	relays := func(ethLog etherumtypes.Log) bool {
+		event, err := t.compassAbi.Unpack("BatchSendEvent", ethLog.Data)
+		if err != nil {
+			liblog.WithContext(ctx).WithError(err).WithField("tx-hash", ethLog.TxHash).Warn("couldn't unpack batch send event")
+			return false
+		}
+
+		logTokenContract, ok := event[0].(common.Address)
+		if !ok || logTokenContract != tokenContract {
+			return false
+		}
+		logBatchNonce, ok := event[1].(*big.Int)
+		return ok && logBatchNonce.Uint64() >= batchNonce
+	}
+
+	if t.eventIndex != nil {
+		indexed := func() (bool, error) {
+			return t.eventIndex.hasBatch(tokenContract, batchNonce)
+		}
+		return t.findEvent(ctx, batchSendEventTopic, indexed, relays)
+	}
+
 	blockNumber, err := t.evm.FindCurrentBlockNumber(ctx)
 	if err != nil {
 		return false, err
 	}
 	fromBlock := *big.NewInt(0)
-	fromBlock.Sub(blockNumber, big.NewInt(9999))
-	filter := ethereum.FilterQuery{
-		Addresses: []common.Address{
-			t.smartContractAddr,
-		},
-		Topics: [][]common.Hash{
-			{
-				crypto.Keccak256Hash([]byte("BatchSendEvent(address,uint256)")),
-			},
-		},
-		FromBlock: &fromBlock,
-	}
+	fromBlock.Sub(blockNumber, big.NewInt(lookbackBlocks))
+	filter := t.gravityEventQuery(batchSendEventTopic)
+	filter.FromBlock = &fromBlock
 
 	var found bool
 	_, err = t.evm.FilterLogs(ctx, filter, nil, func(logs []etherumtypes.Log) bool {
-		for _, ethLog := range logs {
-			event, err := t.compassAbi.Unpack("BatchSendEvent", ethLog.Data)
-			if err != nil {
-				found = true
-			}
+		found = anyLog(logs, relays)
+		return found
+	})
+	if err != nil {
+		return false, err
+	}
 
-			logBatchNonce, ok := event[1].(*big.Int)
-			if !ok {
-				found = true
-			}
-			found = batchNonce == logBatchNonce.Uint64()
-		}
+	return found, nil
+}
 
+// findEvent returns true if compass emitted an event of the given type which
+// matches. The event index is asked first, and only the blocks which are not
+// indexed yet are asked from the chain. While the index is still backfilling,
+// those blocks don't go back further than the lookback.
+func (t compass) findEvent(ctx context.Context, topic common.Hash, indexed func() (bool, error), match func(etherumtypes.Log) bool) (bool, error) {
+	synced, err := t.indexEvents(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	found, err := indexed()
+	if err != nil || found {
+		return found, err
+	}
+
+	blockNumber, err := t.evm.FindCurrentBlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+	if blockNumber.Uint64() <= synced {
+		return false, nil
+	}
+
+	fromBlock := new(big.Int).SetUint64(synced + 1)
+	if lookback := new(big.Int).Sub(blockNumber, big.NewInt(lookbackBlocks)); lookback.Cmp(fromBlock) > 0 {
+		fromBlock = lookback
+	}
+	filter := t.gravityEventQuery(topic)
+	filter.FromBlock = fromBlock
+
+	_, err = t.evm.FilterLogs(ctx, filter, nil, func(logs []etherumtypes.Log) bool {
+		found = anyLog(logs, match)
 		return found
 	})
-
 	if err != nil {
 		return false, err
 	}
@@ -380,6 +617,78 @@ func (t compass) gravityIsBatchAlreadyRelayed(ctx context.Context, batchNonce ui
 	return found, nil
 }
 
+func anyLog(logs []etherumtypes.Log, match func(etherumtypes.Log) bool) bool {
+	for _, l := range logs {
+		if match(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexEvents catches the event index up with the confirmed block, and
+// returns the block up to which the events are indexed.
+func (t compass) indexEvents(ctx context.Context) (uint64, error) {
+	idx := t.eventIndex
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	synced, err := idx.synced()
+	if err != nil {
+		return 0, err
+	}
+
+	confirmed, err := t.evm.FindConfirmedBlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < maxIndexWindowsPerSync && synced < confirmed.Uint64(); i++ {
+		query := idx.query()
+		query.FromBlock = new(big.Int).SetUint64(synced + 1)
+		query.ToBlock = scanWindowEnd(int64(synced), confirmed)
+
+		logs, err := t.evm.GetQuorumEthClient().FilterLogs(ctx, query)
+		if err != nil {
+			return 0, err
+		}
+		if err := idx.add(logs, query.ToBlock.Uint64()); err != nil {
+			return 0, err
+		}
+		synced = query.ToBlock.Uint64()
+	}
+
+	return synced, nil
+}
+
+// gravityLastBatchNonce returns the nonce of the last batch of the token
+// which compass accepted.
+func (t compass) gravityLastBatchNonce(ctx context.Context, tokenContract common.Address) (*big.Int, error) {
+	data, err := t.compassAbi.Pack("last_batch_id", tokenContract)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := t.evm.GetQuorumEthClient().CallContract(ctx, ethereum.CallMsg{
+		To:   &t.smartContractAddr,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.compassAbi.Unpack("last_batch_id", out)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ok := res[0].(*big.Int)
+	if !ok {
+		return nil, ErrInvalidLastBatchNonce.Format(res[0])
+	}
+
+	return nonce, nil
+}
+
 func BuildCompassConsensus(
 	ctx context.Context,
 	v *evmtypes.Valset,
@@ -419,100 +728,438 @@ func BuildCompassConsensus(
 	return con
 }
 
+// chainState fetches the state of the target chain which is needed to relay
+// messages once and shares it between the messages relayed together.
+type chainState struct {
+	valsetOnce sync.Once
+	currValset *evmtypes.Valset
+	valsetErr  error
+
+	blockTimeOnce sync.Once
+	latestTime    time.Time
+	blockTimeErr  error
+
+	catchUpOnce sync.Once
+	catchUpErr  error
+}
+
+// catchUpValset makes sure that compass runs with Paloma's latest valset
+// before the valset is used.
+func (s *chainState) catchUpValset(ctx context.Context, t compass) error {
+	s.catchUpOnce.Do(func() {
+		s.catchUpErr = t.catchUpValset(ctx)
+	})
+
+	return s.catchUpErr
+}
+
+// valset returns the valset which is currently active on the chain.
+func (s *chainState) valset(ctx context.Context, t compass) (*evmtypes.Valset, error) {
+	s.valsetOnce.Do(func() {
+		var valsetID uint64
+		valsetID, s.valsetErr = t.findLastValsetMessageID(ctx)
+		if s.valsetErr != nil {
+			return
+		}
+
+		s.currValset, s.valsetErr = t.paloma.QueryGetEVMValsetByID(ctx, valsetID, t.ChainReferenceID)
+	})
+
+	return s.currValset, s.valsetErr
+}
+
+// blockTime returns the timestamp of the latest block on the chain.
+func (s *chainState) blockTime(ctx context.Context, t compass) (time.Time, error) {
+	s.blockTimeOnce.Do(func() {
+		s.latestTime, s.blockTimeErr = t.evm.FindCurrentBlockTime(ctx)
+	})
+
+	return s.latestTime, s.blockTimeErr
+}
+
+// groupIndependentMessages splits the messages into groups which are
+// processed one after another. Consecutive SubmitLogicCall messages don't
+// depend on each other and end up in the same group, while every other
+// message, like a valset update, gets a group of its own so that the order
+// around it is kept.
+func groupIndependentMessages(msgs []chain.MessageWithSignatures) [][]chain.MessageWithSignatures {
+	var groups [][]chain.MessageWithSignatures
+	var logicCalls []chain.MessageWithSignatures
+	for _, msg := range msgs {
+		if m, ok := msg.Msg.(*evmtypes.Message); ok {
+			if _, ok := m.GetAction().(*evmtypes.Message_SubmitLogicCall); ok {
+				logicCalls = append(logicCalls, msg)
+				continue
+			}
+		}
+
+		if len(logicCalls) > 0 {
+			groups = append(groups, logicCalls)
+			logicCalls = nil
+		}
+		groups = append(groups, []chain.MessageWithSignatures{msg})
+	}
+
+	if len(logicCalls) > 0 {
+		groups = append(groups, logicCalls)
+	}
+
+	return groups
+}
+
 func (t compass) processMessages(ctx context.Context, queueTypeName string, msgs []chain.MessageWithSignatures) error {
 	var gErr whoops.Group
 	logger := liblog.WithContext(ctx).WithField("queue-type-name", queueTypeName)
-	for _, rawMsg := range msgs {
-		logger = logger.WithField("message-id", rawMsg.ID)
 
+	msgs, err := t.preemptValsetUpdate(ctx, queueTypeName, msgs, &gErr)
+	if err != nil {
+		return gErr.Return()
+	}
+
+	for _, group := range groupIndependentMessages(msgs) {
 		if ctx.Err() != nil {
 			logger.Debug("exiting processing message context")
 			break
 		}
 
-		var processingErr error
-		var tx *ethtypes.Transaction
-		msg := rawMsg.Msg.(*evmtypes.Message)
-		logger := logger.WithFields(log.Fields{
-			"chain-reference-id": t.ChainReferenceID,
-			"queue-name":         queueTypeName,
-			"msg-id":             rawMsg.ID,
-			"message-type":       fmt.Sprintf("%T", msg.GetAction()),
-		})
-		logger.Debug("processing")
-
-		switch action := msg.GetAction().(type) {
-		case *evmtypes.Message_SubmitLogicCall:
-			tx, processingErr = t.submitLogicCall(
-				ctx,
-				queueTypeName,
-				action.SubmitLogicCall,
-				rawMsg,
-			)
-		case *evmtypes.Message_UpdateValset:
-			logger := logger.WithFields(log.Fields{
-				"chain-reference-id":     t.ChainReferenceID,
-				"queue-name":             queueTypeName,
-				"msg-id":                 rawMsg.ID,
-				"msg-bytes-to-sign":      rawMsg.BytesToSign,
-				"msg-msg":                rawMsg.Msg,
-				"msg-nonce":              rawMsg.Nonce,
-				"msg-public-access-data": rawMsg.PublicAccessData,
-				"message-type":           "Message_UpdateValset",
-			})
-			logger.Debug("switch-case-message-update-valset")
-			tx, processingErr = t.updateValset(
-				ctx,
-				queueTypeName,
-				action.UpdateValset.Valset,
-				rawMsg,
-			)
-		case *evmtypes.Message_UploadSmartContract:
-			logger := logger.WithFields(log.Fields{
-				"chain-reference-id":     t.ChainReferenceID,
-				"queue-name":             queueTypeName,
-				"msg-id":                 rawMsg.ID,
-				"msg-bytes-to-sign":      rawMsg.BytesToSign,
-				"msg-msg":                rawMsg.Msg,
-				"msg-nonce":              rawMsg.Nonce,
-				"msg-public-access-data": rawMsg.PublicAccessData,
-				"message-type":           "Message_UploadSmartContract",
-			})
-			logger.Debug("switch-case-message-upload-contract")
-			tx, processingErr = t.uploadSmartContract(
-				ctx,
-				queueTypeName,
-				action.UploadSmartContract,
-				rawMsg,
-			)
-		default:
-			return ErrUnsupportedMessageType.Format(action)
+		if len(group) == 1 || t.parallelLogicCalls <= 1 {
+			state := &chainState{}
+			for _, rawMsg := range group {
+				if ctx.Err() != nil {
+					logger.Debug("exiting processing message context")
+					break
+				}
+
+				processingErr, err := t.processMessage(ctx, queueTypeName, rawMsg, state)
+				if err != nil {
+					gErr.Add(processingErr, err)
+					return gErr.Return()
+				}
+				gErr.Add(processingErr)
+			}
+			continue
 		}
 
-		processingErr = whoops.Enrich(
-			processingErr,
-			FieldMessageID.Val(rawMsg.ID),
-			FieldMessageType.Val(msg.GetAction()),
+		if err := t.processLogicCallsInParallel(ctx, queueTypeName, group, &gErr); err != nil {
+			return gErr.Return()
+		}
+	}
+
+	return gErr.Return()
+}
+
+// preemptValsetUpdate relays the queued valset update first when compass is
+// behind Paloma's latest valset, as the messages after it are signed by the
+// new valset and can't reach consensus on the old one. It waits for the
+// update to make it into a block, so that the rest of the messages can be
+// relayed in the same tick, and returns them. The error is returned when the
+// processing of the whole queue should stop.
+func (t compass) preemptValsetUpdate(ctx context.Context, queueTypeName string, msgs []chain.MessageWithSignatures, gErr *whoops.Group) ([]chain.MessageWithSignatures, error) {
+	updates := make(map[uint64]int)
+	for i, msg := range msgs {
+		if m, ok := msg.Msg.(*evmtypes.Message); ok {
+			if action, ok := m.GetAction().(*evmtypes.Message_UpdateValset); ok {
+				updates[action.UpdateValset.GetValset().GetValsetID()] = i
+			}
+		}
+	}
+	// nothing is blocked by an update which is the only message
+	if len(updates) == 0 || len(msgs) == 1 {
+		return msgs, nil
+	}
+
+	logger := liblog.WithContext(ctx).WithFields(log.Fields{
+		"chain-reference-id": t.ChainReferenceID,
+		"queue-type-name":    queueTypeName,
+	})
+
+	onChain, latest, err := t.valsetDrift(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("couldn't check for valset drift")
+		return msgs, nil
+	}
+	if latest == nil {
+		return msgs, nil
+	}
+
+	logger = logger.WithFields(log.Fields{
+		"on-chain-valset-id": onChain,
+		"latest-valset-id":   latest.GetValsetID(),
+	})
+	i, ok := updates[latest.GetValsetID()]
+	if !ok {
+		logger.Warn("compass is behind Paloma's valset, but the update isn't queued")
+		return msgs, nil
+	}
+
+	logger.Info("compass is behind Paloma's valset, relaying the update first")
+	update := msgs[i]
+	rest := make([]chain.MessageWithSignatures, 0, len(msgs)-1)
+	rest = append(rest, msgs[:i]...)
+	rest = append(rest, msgs[i+1:]...)
+
+	processingErr, err := t.processMessage(ctx, queueTypeName, update, &chainState{})
+	gErr.Add(processingErr)
+	if err != nil {
+		gErr.Add(err)
+		return nil, err
+	}
+	if processingErr != nil {
+		return rest, nil
+	}
+
+	if err := t.waitForValset(ctx, latest.GetValsetID()); err != nil {
+		logger.WithError(err).Warn("valset update didn't make it into a block in time")
+	}
+
+	return rest, nil
+}
+
+// valsetDrift returns the ID of the valset compass runs with, and Paloma's
+// latest valset if compass is behind it. The valset is nil if compass runs
+// with the latest one.
+func (t compass) valsetDrift(ctx context.Context) (uint64, *evmtypes.Valset, error) {
+	onChain, err := t.findLastValsetMessageID(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	// 0 means to get the latest valset
+	latest, err := t.paloma.QueryGetEVMValsetByID(ctx, 0, t.ChainReferenceID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if latest == nil || onChain >= latest.GetValsetID() {
+		return onChain, nil, nil
+	}
+
+	return onChain, latest, nil
+}
+
+// catchUpValset relays Paloma's latest valset update from the turnstone
+// queue when compass is behind it, and waits for the update to make it into
+// a block. Gravity batches are signed by the latest valset, so they can't
+// reach consensus on compass before that. ErrValsetBehind is returned when
+// compass didn't catch up.
+func (t compass) catchUpValset(ctx context.Context) error {
+	logger := liblog.WithContext(ctx).WithField("chain-reference-id", t.ChainReferenceID)
+	onChain, latest, err := t.valsetDrift(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("couldn't check for valset drift")
+		return nil
+	}
+	if latest == nil {
+		return nil
+	}
+
+	logger = logger.WithFields(log.Fields{
+		"on-chain-valset-id": onChain,
+		"latest-valset-id":   latest.GetValsetID(),
+	})
+	behind := ErrValsetBehind.Format(onChain, latest.GetValsetID())
+
+	queueTypeName := fmt.Sprintf("evm/%s/%s", t.ChainReferenceID, queue.QueueSuffixTurnstone)
+	msgs, err := t.paloma.QueryMessagesForRelaying(ctx, queueTypeName)
+	if err != nil {
+		return err
+	}
+	var update *chain.MessageWithSignatures
+	for i, msg := range msgs {
+		if m, ok := msg.Msg.(*evmtypes.Message); ok {
+			action, ok := m.GetAction().(*evmtypes.Message_UpdateValset)
+			if ok && action.UpdateValset.GetValset().GetValsetID() == latest.GetValsetID() {
+				update = &msgs[i]
+				break
+			}
+		}
+	}
+	if update == nil {
+		logger.Warn("compass is behind Paloma's valset, but the update isn't queued")
+		return behind
+	}
+
+	logger.Info("compass is behind Paloma's valset, relaying the update first")
+	processingErr, err := t.processMessage(ctx, queueTypeName, *update, &chainState{})
+	if err != nil {
+		return err
+	}
+	if processingErr != nil {
+		logger.WithError(processingErr).Warn("couldn't relay the valset update")
+		return behind
+	}
+
+	if err := t.waitForValset(ctx, latest.GetValsetID()); err != nil {
+		logger.WithError(err).Warn("valset update didn't make it into a block in time")
+		return behind
+	}
+
+	return nil
+}
+
+// waitForValset waits until compass runs with the given valset, or gives up
+// after a while.
+func (t compass) waitForValset(ctx context.Context, valsetID uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, valsetUpdateWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(valsetUpdatePollInterval)
+	defer ticker.Stop()
+	for {
+		onChain, err := t.findLastValsetMessageID(ctx)
+		if err == nil && onChain >= valsetID {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// processLogicCallsInParallel relays independent SubmitLogicCall messages
+// concurrently, with at most parallelLogicCalls of them in flight. The
+// processing errors are added to the group, while an error which should stop
+// the processing of the whole queue is returned as well.
+func (t compass) processLogicCallsInParallel(ctx context.Context, queueTypeName string, msgs []chain.MessageWithSignatures, gErr *whoops.Group) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		fatalErr error
+	)
+	state := &chainState{}
+	sem := make(chan struct{}, t.parallelLogicCalls)
+	for _, rawMsg := range msgs {
+		rawMsg := rawMsg
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := fatalErr != nil || ctx.Err() != nil
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			processingErr, err := t.processMessage(ctx, queueTypeName, rawMsg, state)
+
+			mu.Lock()
+			defer mu.Unlock()
+			gErr.Add(processingErr, err)
+			if err != nil && fatalErr == nil {
+				fatalErr = err
+			}
+		}()
+	}
+	wg.Wait()
+
+	return fatalErr
+}
+
+// processMessage relays a single message. Errors which happened while relaying
+// the message are returned as the processing error. Errors which should stop
+// the processing of the whole queue are returned as the second error.
+func (t compass) processMessage(ctx context.Context, queueTypeName string, rawMsg chain.MessageWithSignatures, state *chainState) (processingErr, abortErr error) {
+	var tx *ethtypes.Transaction
+	var hooks txHooks
+	// transactions sent through the MEV relay aren't replaced, as their
+	// replacements would end up in the public mempool
+	mev := false
+	msg := rawMsg.Msg.(*evmtypes.Message)
+	logger := liblog.WithContext(ctx).WithFields(log.Fields{
+		"chain-reference-id": t.ChainReferenceID,
+		"queue-name":         queueTypeName,
+		"msg-id":             rawMsg.ID,
+		"message-type":       fmt.Sprintf("%T", msg.GetAction()),
+	})
+	logger.Debug("processing")
+
+	switch action := msg.GetAction().(type) {
+	case *evmtypes.Message_SubmitLogicCall:
+		tx, processingErr = t.submitLogicCall(
+			ctx,
+			queueTypeName,
+			action.SubmitLogicCall,
+			rawMsg,
+			state,
 		)
+		hooks.stillNeeded = t.isLogicCallStillNeeded(rawMsg.ID, action.SubmitLogicCall.GetDeadline())
+		mev = action.SubmitLogicCall.ExecutionRequirements.EnforceMEVRelay
+	case *evmtypes.Message_UpdateValset:
+		logger := logger.WithFields(log.Fields{
+			"msg-bytes-to-sign":      rawMsg.BytesToSign,
+			"msg-msg":                rawMsg.Msg,
+			"msg-nonce":              rawMsg.Nonce,
+			"msg-public-access-data": rawMsg.PublicAccessData,
+			"message-type":           "Message_UpdateValset",
+		})
+		logger.Debug("switch-case-message-update-valset")
+		tx, processingErr = t.updateValset(
+			ctx,
+			queueTypeName,
+			action.UpdateValset.Valset,
+			rawMsg,
+		)
+		hooks.stillNeeded = t.isValsetUpdateStillNeeded(action.UpdateValset.Valset.GetValsetID())
+	case *evmtypes.Message_UploadSmartContract:
+		logger := logger.WithFields(log.Fields{
+			"msg-bytes-to-sign":      rawMsg.BytesToSign,
+			"msg-msg":                rawMsg.Msg,
+			"msg-nonce":              rawMsg.Nonce,
+			"msg-public-access-data": rawMsg.PublicAccessData,
+			"message-type":           "Message_UploadSmartContract",
+		})
+		logger.Debug("switch-case-message-upload-contract")
+		tx, processingErr = t.uploadSmartContract(
+			ctx,
+			queueTypeName,
+			action.UploadSmartContract,
+			rawMsg,
+		)
+	default:
+		return nil, ErrUnsupportedMessageType.Format(action)
+	}
+
+	processingErr = whoops.Enrich(
+		processingErr,
+		FieldMessageID.Val(rawMsg.ID),
+		FieldMessageType.Val(msg.GetAction()),
+	)
 
+	switch {
+	case processingErr == nil:
 		switch {
-		case processingErr == nil:
-			if tx != nil {
-				logger.Debug("setting public access data")
-				if err := t.paloma.SetPublicAccessData(ctx, queueTypeName, rawMsg.ID, tx.Hash().Bytes()); err != nil {
-					gErr.Add(err)
-					return gErr
-				}
+		case tx == nil:
+		case mev:
+			logger.Debug("setting public access data")
+			if err := t.paloma.SetPublicAccessData(ctx, queueTypeName, rawMsg.ID, tx.Hash().Bytes()); err != nil {
+				return nil, err
 			}
-		case goerrors.Is(processingErr, ErrNoConsensus):
-			// does nothing
+
+			// the relay might drop the transaction, which would leave a
+			// gap in the nonces behind
+			t.evm.TrackTransaction(tx, txHooks{private: true})
 		default:
-			logger.WithError(processingErr).Error("processing error")
-			gErr.Add(processingErr)
+			hooks.ref = messageTxRef(queueTypeName, rawMsg.ID)
+			hooks.onFinal = t.reportTxResult(queueTypeName, rawMsg.ID, tx)
+			t.evm.TrackTransaction(tx, hooks)
 		}
+	case goerrors.Is(processingErr, ErrNoConsensus):
+		// does nothing
+	default:
+		logger.WithError(processingErr).Error("processing error")
+		return processingErr, nil
 	}
 
-	return gErr.Return()
+	return nil, nil
 }
 
 func (t compass) provideEvidenceForValidatorBalance(ctx context.Context, queueTypeName string, msgs []chain.MessageWithSignatures) error {
@@ -556,40 +1203,18 @@ func (t compass) provideEvidenceForValidatorBalance(ctx context.Context, queueTy
 	return g.Return()
 }
 
-func (t *compass) GetBatchSendEvents(ctx context.Context, orchestrator string) ([]chain.BatchSendEvent, error) {
-	blockNumber, err := t.evm.FindCurrentBlockNumber(ctx)
+// GetBatchSendEvents returns the batch send events which are waiting to be
+// claimed, ordered by nonce. The events of the compass the chain is handing
+// over from, if any, are scanned for together with the ones of t.
+func (t *compass) GetBatchSendEvents(ctx context.Context, orchestrator string, previous *compass) ([]chain.BatchSendEvent, error) {
+	claims, err := t.gravityClaims(ctx, orchestrator, previous, batchSendEventTopic)
 	if err != nil {
 		return nil, err
 	}
-	if t.lastObservedBlockHeights.batchSendEvent == 0 {
-		t.lastObservedBlockHeights.batchSendEvent = blockNumber.Int64() - 10000
-	}
-
-	fromBlock := *big.NewInt(t.lastObservedBlockHeights.batchSendEvent + 1)
-
-	filter := ethereum.FilterQuery{
-		Addresses: []common.Address{
-			t.smartContractAddr,
-		},
-		Topics: [][]common.Hash{
-			{
-				crypto.Keccak256Hash([]byte("BatchSendEvent(address,uint256)")),
-			},
-		},
-		FromBlock: &fromBlock,
-	}
 
 	var events []chain.BatchSendEvent
-
-	logs, err := t.evm.GetEthClient().FilterLogs(ctx, filter)
-
-	lastEventNonce, err := t.paloma.QueryGetLastEventNonce(ctx, orchestrator)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, ethLog := range logs {
-		event, err := t.compassAbi.Unpack("BatchSendEvent", ethLog.Data)
+	for _, claim := range claims {
+		event, err := t.compassAbi.Unpack("BatchSendEvent", claim.log.Data)
 		if err != nil {
 			return nil, err
 		}
@@ -605,52 +1230,28 @@ func (t *compass) GetBatchSendEvents(ctx context.Context, orchestrator string) (
 		}
 
 		events = append(events, chain.BatchSendEvent{
-			EthBlockHeight: ethLog.BlockNumber,
-			EventNonce:     lastEventNonce + 1,
+			EthBlockHeight: claim.log.BlockNumber,
+			EventNonce:     claim.nonce,
 			BatchNonce:     batchNonce.Uint64(),
 			TokenContract:  tokenContract.String(),
 		})
 	}
 
-	t.lastObservedBlockHeights.batchSendEvent = blockNumber.Int64()
-
-	return events, err
+	return events, nil
 }
 
-func (t *compass) GetSendToPalomaEvents(ctx context.Context, orchestrator string) ([]chain.SendToPalomaEvent, error) {
-	blockNumber, err := t.evm.FindCurrentBlockNumber(ctx)
+// GetSendToPalomaEvents returns the send to Paloma events which are waiting
+// to be claimed, ordered by nonce. The events of the compass the chain is
+// handing over from, if any, are scanned for together with the ones of t.
+func (t *compass) GetSendToPalomaEvents(ctx context.Context, orchestrator string, previous *compass) ([]chain.SendToPalomaEvent, error) {
+	claims, err := t.gravityClaims(ctx, orchestrator, previous, sendToPalomaEventTopic)
 	if err != nil {
 		return nil, err
 	}
-	if t.lastObservedBlockHeights.sendToPalomaEvent == 0 {
-		t.lastObservedBlockHeights.sendToPalomaEvent = blockNumber.Int64() - 1
-	}
-
-	fromBlock := *big.NewInt(t.lastObservedBlockHeights.sendToPalomaEvent + 1)
-
-	filter := ethereum.FilterQuery{
-		Addresses: []common.Address{
-			t.smartContractAddr,
-		},
-		Topics: [][]common.Hash{
-			{
-				crypto.Keccak256Hash([]byte("SendToPalomaEvent(address,address,string,uint256)")),
-			},
-		},
-		FromBlock: &fromBlock,
-	}
 
 	var events []chain.SendToPalomaEvent
-
-	logs, err := t.evm.GetEthClient().FilterLogs(ctx, filter)
-
-	lastEventNonce, err := t.paloma.QueryGetLastEventNonce(ctx, orchestrator)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, ethLog := range logs {
-		event, err := t.compassAbi.Unpack("SendToPalomaEvent", ethLog.Data)
+	for _, claim := range claims {
+		event, err := t.compassAbi.Unpack("SendToPalomaEvent", claim.log.Data)
 		if err != nil {
 			return nil, err
 		}
@@ -665,7 +1266,7 @@ func (t *compass) GetSendToPalomaEvents(ctx context.Context, orchestrator string
 			return nil, fmt.Errorf("invalid sender address")
 		}
 
-		palomaReceiver := event[2].(string)
+		palomaReceiver, ok := event[2].(string)
 		if !ok {
 			return nil, fmt.Errorf("invalid paloma receiver")
 		}
@@ -676,8 +1277,8 @@ func (t *compass) GetSendToPalomaEvents(ctx context.Context, orchestrator string
 		}
 
 		events = append(events, chain.SendToPalomaEvent{
-			EthBlockHeight: ethLog.BlockNumber,
-			EventNonce:     lastEventNonce + 1,
+			EthBlockHeight: claim.log.BlockNumber,
+			EventNonce:     claim.nonce,
 			Amount:         amount.Uint64(),
 			EthereumSender: ethSender.String(),
 			PalomaReceiver: palomaReceiver,
@@ -685,9 +1286,265 @@ func (t *compass) GetSendToPalomaEvents(ctx context.Context, orchestrator string
 		})
 	}
 
-	t.lastObservedBlockHeights.sendToPalomaEvent = blockNumber.Int64()
+	return events, nil
+}
+
+// gravityClaims scans the compass contracts of the chain for the gravity
+// events they emitted since the last scan, and returns the claims of the
+// given event which are waiting to be acknowledged. All gravity events of
+// all contracts are scanned for in one go, so that their nonces don't depend
+// on which kind of event is asked for first.
+func (t *compass) gravityClaims(ctx context.Context, orchestrator string, previous *compass, topic common.Hash) ([]eventClaim, error) {
+	n := t.eventNonces
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// only events which are deep enough in the chain are claimed, so that
+	// a reorg can't take them back
+	confirmed, err := t.evm.FindConfirmedBlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.scanned == 0 && !n.started {
+		t.resumeScan(ctx, n, confirmed.Uint64())
+	}
+
+	compasses := []*compass{t}
+	if previous != nil {
+		compasses = append(compasses, previous)
+	}
+
+	// the blocks are only handed nonces once the events of all contracts
+	// are known for them
+	upTo := confirmed.Uint64()
+	var logs []etherumtypes.Log
+	for _, c := range compasses {
+		found, synced, err := c.gravityLogsAfter(ctx, n.scanned, confirmed)
+		if err != nil {
+			return nil, err
+		}
+		if synced < upTo {
+			upTo = synced
+		}
+		logs = append(logs, found...)
+	}
+
+	if upTo <= n.scanned && !n.waiting() {
+		return nil, nil
+	}
+
+	lastEventNonce, err := t.paloma.QueryGetLastEventNonce(ctx, orchestrator)
+	if err != nil {
+		return nil, err
+	}
+
+	n.assign(lastEventNonce, logs, upTo)
+	for _, c := range compasses {
+		c.gravityWatcher.ack(n.scanned)
+	}
+	// the checkpoint only moves past the events Paloma acknowledged, as
+	// the ones after them must get the same nonces after a restart
+	t.saveCheckpoint(ctx, n.checkpoint())
+
+	return n.pending(topic), nil
+}
+
+// gravityLogsAfter returns the gravity events compass emitted after the given
+// block, together with the block up to which they are complete.
+func (t *compass) gravityLogsAfter(ctx context.Context, after uint64, confirmed *big.Int) ([]etherumtypes.Log, uint64, error) {
+	logs, synced, watched := t.gravityWatcher.pending(confirmed.Uint64())
+	if !watched {
+		if after >= confirmed.Uint64() {
+			return nil, after, nil
+		}
+
+		filter := t.gravityEventsQuery()
+		filter.FromBlock = new(big.Int).SetUint64(after + 1)
+		filter.ToBlock = scanWindowEnd(int64(after), confirmed)
+
+		var err error
+		logs, err = t.gravityLogs(ctx, filter)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		synced = filter.ToBlock.Uint64()
+		if synced == confirmed.Uint64() {
+			// the watcher only takes over once the scan caught up
+			t.gravityWatcher.start(synced + 1)
+		}
+	}
+
+	logs, err := t.canonicalLogs(ctx, t.gravityEventsQuery(), logs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logs, synced, nil
+}
+
+// resumeScan sets up where the scan for gravity events starts. That is the
+// stored checkpoint if there is one, or else the last block in which Paloma
+// observed a gravity event. Without either, the scan starts after the block
+// before the confirmed one, as claiming events which were claimed before
+// would have them executed twice.
+func (t *compass) resumeScan(ctx context.Context, n *eventNonces, confirmed uint64) {
+	logger := liblog.WithContext(ctx).WithFields(log.Fields{
+		"chain-reference-id": t.ChainReferenceID,
+	})
+
+	checkpoint, found, err := t.checkpoints.load()
+	if err != nil {
+		logger.WithError(err).Warn("couldn't load checkpoint")
+	}
+	if found && checkpoint.block <= confirmed+1 {
+		logger.WithFields(log.Fields{
+			"checkpoint-block": checkpoint.block,
+			"checkpoint-index": checkpoint.index,
+			"checkpoint-nonce": checkpoint.nonce,
+		}).Info("resuming scan from checkpoint")
+		n.resume(checkpoint)
+		return
+	}
+
+	// Paloma only keeps the last observed block across all orchestrators,
+	// which is why it comes second to the checkpoint.
+	observed, err := t.paloma.QueryGetLastObservedEthBlock(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("couldn't query last observed block")
+	}
+	if observed > 0 && observed <= confirmed {
+		logger.WithField("observed", observed).Info("resuming scan from the last block observed by Paloma")
+		n.scanned = observed
+		return
+	}
+
+	if confirmed > 0 {
+		n.scanned = confirmed - 1
+	}
+}
+
+// scanWindowEnd returns the last block of the next scan window after the
+// given block, so that catching up on a long way doesn't go in one call.
+func scanWindowEnd(lastObserved int64, confirmed *big.Int) *big.Int {
+	end := big.NewInt(lastObserved + maxGravityScanWindow)
+	if end.Cmp(confirmed) > 0 {
+		return new(big.Int).Set(confirmed)
+	}
+	return end
+}
+
+func (t *compass) saveCheckpoint(ctx context.Context, checkpoint eventCheckpoint) {
+	if err := t.checkpoints.save(checkpoint); err != nil {
+		liblog.WithContext(ctx).WithError(err).WithFields(log.Fields{
+			"chain-reference-id": t.ChainReferenceID,
+			"checkpoint-block":   checkpoint.block,
+			"checkpoint-index":   checkpoint.index,
+			"checkpoint-nonce":   checkpoint.nonce,
+		}).Warn("couldn't save checkpoint")
+	}
+}
+
+// canonicalLogs makes sure that the blocks of the logs are still part of the
+// chain. The logs of a block which was orphaned by a reorg are replaced by
+// the logs of the block which took its place.
+func (t *compass) canonicalLogs(ctx context.Context, query ethereum.FilterQuery, logs []etherumtypes.Log) ([]etherumtypes.Log, error) {
+	res := make([]etherumtypes.Log, 0, len(logs))
+	for i := 0; i < len(logs); {
+		j := i
+		for j < len(logs) && logs[j].BlockNumber == logs[i].BlockNumber {
+			j++
+		}
+		block := logs[i:j]
+		i = j
+
+		header, err := t.evm.GetEthClient().HeaderByNumber(ctx, new(big.Int).SetUint64(block[0].BlockNumber))
+		if err != nil {
+			return nil, err
+		}
+		canonical := header.Hash()
+
+		orphaned := false
+		for _, l := range block {
+			if l.Removed || l.BlockHash != canonical {
+				orphaned = true
+				break
+			}
+		}
+		if !orphaned {
+			res = append(res, block...)
+			continue
+		}
+
+		liblog.WithContext(ctx).WithFields(log.Fields{
+			"block-number":   block[0].BlockNumber,
+			"orphaned-block": block[0].BlockHash,
+			"canonical":      canonical,
+		}).Warn("block of observed logs was orphaned, rescanning it")
+
+		rescan := query
+		rescan.FromBlock, rescan.ToBlock, rescan.BlockHash = nil, nil, &canonical
+		rescanned, err := t.evm.GetQuorumEthClient().FilterLogs(ctx, rescan)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rescanned...)
+	}
+
+	return res, nil
+}
 
-	return events, err
+// gravityLogs returns the logs of the gravity events the query matches, in
+// the order they were emitted. They are read from the event index once it
+// covers the blocks of the query.
+func (t *compass) gravityLogs(ctx context.Context, query ethereum.FilterQuery) ([]etherumtypes.Log, error) {
+	if t.eventIndex == nil || query.FromBlock.Uint64() < t.eventIndex.from {
+		return t.evm.GetQuorumEthClient().FilterLogs(ctx, query)
+	}
+
+	synced, err := t.indexEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if synced < query.ToBlock.Uint64() {
+		return t.evm.GetQuorumEthClient().FilterLogs(ctx, query)
+	}
+
+	var res []etherumtypes.Log
+	for _, topic := range query.Topics[0] {
+		logs, err := t.eventIndex.logs(topic, query.FromBlock.Uint64(), query.ToBlock.Uint64())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, logs...)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].BlockNumber != res[j].BlockNumber {
+			return res[i].BlockNumber < res[j].BlockNumber
+		}
+		return res[i].Index < res[j].Index
+	})
+
+	return res, nil
+}
+
+// gravityEventQuery returns the query for the given gravity event emitted by
+// compass.
+func (t *compass) gravityEventQuery(topic common.Hash) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{t.smartContractAddr},
+		Topics:    [][]common.Hash{{topic}},
+	}
+}
+
+// gravityEventsQuery returns the query for all gravity events emitted by
+// compass.
+func (t *compass) gravityEventsQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{t.smartContractAddr},
+		Topics:    [][]common.Hash{gravityEventTopics},
+	}
 }
 
 // provideTxProof provides a very simple proof which is a transaction object
@@ -838,24 +1695,73 @@ func (c compass) callCompass(
 	return c.evm.ExecuteSmartContract(ctx, c.chainID, *c.compassAbi, c.smartContractAddr, useMevRelay, method, arguments)
 }
 
+// logBatchFailure logs why a gravity batch wasn't relayed. Unlike failed
+// logic calls, the failure can't be reported to Paloma: MsgSetErrorData only
+// takes the messages of the consensus queues, which gravity batches aren't
+// part of, and the gravity module has no message for it. Until it has one,
+// Paloma only drops a failed batch once it times out.
+func (t compass) logBatchFailure(ctx context.Context, batch chain.GravityBatchWithSignatures, errData errorData) {
+	liblog.WithContext(ctx).WithFields(log.Fields{
+		"chain-reference-id": t.ChainReferenceID,
+		"batch-nonce":        batch.BatchNonce,
+		"token-contract":     batch.TokenContract,
+		"error-message":      errData.Message,
+		"error-category":     errData.Category,
+	}).Warn("gravity batch wasn't relayed")
+}
+
+// deferUnprofitableBatch returns true if relaying the batch would lose more
+// than the chain's loss threshold. A batch whose cost can't be estimated is
+// relayed anyway, so that the reason is reported when it fails.
+func (t compass) deferUnprofitableBatch(ctx context.Context, batch chain.GravityBatchWithSignatures, args []any) (bool, error) {
+	logger := liblog.WithContext(ctx).WithFields(log.Fields{
+		"chain-reference-id": t.ChainReferenceID,
+		"batch-nonce":        batch.BatchNonce,
+		"token-contract":     batch.TokenContract,
+	})
+
+	cost, err := t.evm.EstimateContractCost(ctx, *t.compassAbi, t.smartContractAddr, "submit_batch", args)
+	if err != nil {
+		logger.WithError(err).Warn("couldn't estimate the cost of relaying the batch")
+		return false, nil
+	}
+
+	loss, unprofitable, err := t.profitability.loss(batch, cost)
+	if err != nil {
+		return false, err
+	}
+
+	logger = logger.WithFields(log.Fields{
+		"cost":     cost,
+		"loss":     loss,
+		"max-loss": t.profitability.maxLoss,
+	})
+	if unprofitable {
+		logger.Info("deferring batch, relaying it would lose more than the threshold")
+		return true, nil
+	}
+	logger.Debug("batch is worth relaying")
+
+	return false, nil
+}
+
 func (t compass) gravityRelayBatches(ctx context.Context, batches []chain.GravityBatchWithSignatures) error {
 	var gErr whoops.Group
 	logger := liblog.WithContext(ctx).WithField("chainReferenceID", t.ChainReferenceID)
+	state := &chainState{}
 	for _, batch := range batches {
-		logger = logger.WithField("batch-nonce", batch.BatchNonce)
-
 		if ctx.Err() != nil {
 			logger.Debug("exiting processing batch context")
 			break
 		}
 
-		var processingErr error
 		logger := logger.WithFields(log.Fields{
-			"batch-nonce": batch.BatchNonce,
+			"batch-nonce":    batch.BatchNonce,
+			"token-contract": batch.TokenContract,
 		})
 		logger.Debug("relaying")
 
-		_, processingErr = t.gravityRelayBatch(ctx, batch)
+		_, processingErr := t.gravityRelayBatch(ctx, batch, state)
 
 		processingErr = whoops.Enrich(
 			processingErr,
@@ -867,6 +1773,9 @@ func (t compass) gravityRelayBatches(ctx context.Context, batches []chain.Gravit
 			// do nothing.  claiming happens in a different goroutine
 		case goerrors.Is(processingErr, ErrNoConsensus):
 			// does nothing
+		case goerrors.Is(processingErr, ErrValsetBehind):
+			// the batches are relayed once compass caught up
+			logger.WithError(processingErr).Info("holding back the batch")
 		default:
 			logger.WithError(processingErr).Error("relay error")
 			gErr.Add(processingErr)
@@ -879,18 +1788,34 @@ func (t compass) gravityRelayBatches(ctx context.Context, batches []chain.Gravit
 func (t compass) gravityRelayBatch(
 	ctx context.Context,
 	batch chain.GravityBatchWithSignatures,
+	state *chainState,
 ) (*ethtypes.Transaction, error) {
 	return whoops.TryVal(func() *ethtypes.Transaction {
-		executed, err := t.gravityIsBatchAlreadyRelayed(ctx, batch.BatchNonce)
+		tokenContract := common.HexToAddress(batch.TokenContract)
+		executed, err := t.gravityIsBatchAlreadyRelayed(ctx, tokenContract, batch.BatchNonce)
 		whoops.Assert(err)
 		if executed {
 			return nil
 		}
 
-		valsetID, err := t.findLastValsetMessageID(ctx)
-		whoops.Assert(err)
+		// the batch timeout is the unix time after which compass rejects
+		// the batch
+		if deadline := int64(batch.GetBatchTimeout()); deadline > 0 {
+			blockTime, err := state.blockTime(ctx, t)
+			whoops.Assert(err)
+			if blockTime.Unix() >= deadline {
+				t.logBatchFailure(ctx, batch, errorData{
+					Category:  errorCategoryExpired,
+					Message:   ErrMessageExpired.Format(deadline, blockTime.Unix()).Error(),
+					Deadline:  deadline,
+					BlockTime: blockTime.Unix(),
+				})
+				return nil
+			}
+		}
 
-		valset, err := t.paloma.QueryGetEVMValsetByID(ctx, valsetID, t.ChainReferenceID)
+		whoops.Assert(state.catchUpValset(ctx, t))
+		valset, err := state.valset(ctx, t)
 		whoops.Assert(err)
 
 		consensusReached := isConsensusReached(ctx, valset, batch)
@@ -913,20 +1838,30 @@ func (t compass) gravityRelayBatch(
 			Amount:   amounts,
 		}
 
-		tx, err := t.callCompass(
-			ctx,
-			false,
-			"submit_batch",
-			[]any{
-				con,
-				common.HexToAddress(batch.TokenContract),
-				compassArgs,
-				new(big.Int).SetInt64(int64(batch.BatchNonce)),
-				new(big.Int).SetInt64(int64(batch.GetBatchTimeout())), // TODO : Deadline
-			},
-		)
+		args := []any{
+			con,
+			tokenContract,
+			compassArgs,
+			new(big.Int).SetUint64(batch.BatchNonce),
+			new(big.Int).SetUint64(batch.GetBatchTimeout()),
+		}
+
+		if t.profitability != nil && t.compassAbi != nil {
+			deferred, err := t.deferUnprofitableBatch(ctx, batch, args)
+			whoops.Assert(err)
+			if deferred {
+				return nil
+			}
+		}
+
+		tx, err := t.callCompass(ctx, false, "submit_batch", args)
 		if err != nil {
-			liblog.WithContext(ctx).WithError(err).Error("failed to relay batch")
+			errData, isSmartContractError := newErrorData(t.compassAbi, err)
+			t.logBatchFailure(ctx, batch, errData)
+			if isSmartContractError {
+				return nil
+			}
+			whoops.Assert(err)
 		}
 
 		return tx
@@ -3,22 +3,30 @@ package evm
 import (
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"math/big"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/VolumeFi/whoops"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	etherumtypes "github.com/ethereum/go-ethereum/core/types"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/palomachain/paloma/x/evm/types"
+	gravitytypes "github.com/palomachain/paloma/x/gravity/types"
 	"github.com/palomachain/pigeon/chain"
 	evmmocks "github.com/palomachain/pigeon/chain/evm/mocks"
 	"github.com/palomachain/pigeon/internal/queue"
+	"github.com/palomachain/pigeon/internal/store"
+	"github.com/palomachain/pigeon/util/slice"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -256,6 +264,8 @@ func TestMessageProcessing(t *testing.T) {
 					fn([]etherumtypes.Log{})
 				})
 
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+
 				currentValsetID := int64(55)
 
 				evm.On("LastValsetID", mock.Anything, mock.Anything).Return(
@@ -282,7 +292,10 @@ func TestMessageProcessing(t *testing.T) {
 					nil,
 				)
 
-				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(555), tx.Hash().Bytes()).Return(nil)
+				// the public access data is only set once the transaction is final
+				evm.On("TrackTransaction", tx, mock.MatchedBy(func(hooks txHooks) bool {
+					return hooks.ref == "queue-name/555"
+				})).Return()
 				return evm, paloma
 			},
 		},
@@ -320,6 +333,8 @@ func TestMessageProcessing(t *testing.T) {
 					fn([]etherumtypes.Log{})
 				})
 
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+
 				currentValsetID := int64(55)
 
 				evm.On("LastValsetID", mock.Anything, mock.Anything).Return(
@@ -347,6 +362,9 @@ func TestMessageProcessing(t *testing.T) {
 				)
 
 				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(555), tx.Hash().Bytes()).Return(nil)
+				evm.On("TrackTransaction", tx, mock.MatchedBy(func(hooks txHooks) bool {
+					return hooks.private && hooks.onFinal == nil
+				})).Return()
 				return evm, paloma
 			},
 		},
@@ -381,6 +399,8 @@ func TestMessageProcessing(t *testing.T) {
 					fn([]etherumtypes.Log{})
 				})
 
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+
 				currentValsetID := int64(55)
 
 				evm.On("LastValsetID", mock.Anything, mock.Anything).Return(
@@ -411,6 +431,110 @@ func TestMessageProcessing(t *testing.T) {
 				return evm, paloma
 			},
 		},
+		{
+			name: "submit_logic_call/an expired message is reported to Paloma instead of relayed",
+			msgs: []chain.MessageWithSignatures{
+				{
+					QueuedMessage: chain.QueuedMessage{
+						ID:          555,
+						BytesToSign: ethCompatibleBytesToSign,
+						Msg: &types.Message{
+							Action: &types.Message_SubmitLogicCall{
+								SubmitLogicCall: &types.SubmitLogicCall{
+									HexContractAddress: "0xABC",
+									Abi:                []byte("abi"),
+									Payload:            []byte("payload"),
+									Deadline:           123,
+								},
+							},
+						},
+					},
+					Signatures: []chain.ValidatorSignature{
+						addValidSignature(bobPK),
+					},
+				},
+			},
+			setup: func(t *testing.T) (*mockEvmClienter, *evmmocks.PalomaClienter) {
+				evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
+
+				evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Times(1).Return(false, nil)
+
+				evm.On("FindCurrentBlockNumber", mock.Anything).Return(
+					big.NewInt(0),
+					nil,
+				)
+
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(123, 0), nil)
+
+				paloma.On("SetErrorData", mock.Anything, "queue-name", uint64(555), mock.MatchedBy(func(data []byte) bool {
+					var errData errorData
+					if err := json.Unmarshal(data, &errData); err != nil {
+						return false
+					}
+					return errData.Category == errorCategoryExpired &&
+						errData.Deadline == 123 &&
+						errData.BlockTime == 123
+				})).Return(nil)
+
+				return evm, paloma
+			},
+		},
+		{
+			name: "submit_logic_call/a call which would revert is reported to Paloma instead of sent",
+			msgs: []chain.MessageWithSignatures{
+				{
+					QueuedMessage: chain.QueuedMessage{
+						ID:          555,
+						BytesToSign: ethCompatibleBytesToSign,
+						Msg: &types.Message{
+							Action: &types.Message_SubmitLogicCall{
+								SubmitLogicCall: &types.SubmitLogicCall{
+									HexContractAddress: "0xABC",
+									Abi:                []byte("abi"),
+									Payload:            []byte("payload"),
+									Deadline:           123,
+								},
+							},
+						},
+					},
+					Signatures: []chain.ValidatorSignature{
+						addValidSignature(bobPK),
+					},
+				},
+			},
+			setup: func(t *testing.T) (*mockEvmClienter, *evmmocks.PalomaClienter) {
+				evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
+
+				evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Times(1).Return(false, nil)
+				evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(0), nil)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+				evm.On("LastValsetID", mock.Anything, mock.Anything).Return(big.NewInt(55), nil)
+
+				paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(55), "internal-chain-id").Return(
+					&types.Valset{
+						Validators: []string{crypto.PubkeyToAddress(bobPK.PublicKey).Hex()},
+						Powers:     []uint64{testPowerThreshold + 1},
+						ValsetID:   55,
+					},
+					nil,
+				)
+
+				revertErr := &revertError{reason: revertReason{kind: revertKindError, message: "Deadline passed"}}
+				evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_logic_call", mock.Anything).Return(nil, revertErr)
+
+				paloma.On("SetErrorData", mock.Anything, "queue-name", uint64(555), mock.MatchedBy(func(data []byte) bool {
+					var errData errorData
+					if err := json.Unmarshal(data, &errData); err != nil {
+						return false
+					}
+					return errData.Category == errorCategoryExpired &&
+						errData.Revert != nil &&
+						errData.Revert.Reason == "Deadline passed"
+				})).Return(nil)
+
+				return evm, paloma
+			},
+		},
 		{
 			name: "update_valset/happy path",
 			msgs: []chain.MessageWithSignatures{
@@ -474,7 +598,10 @@ func TestMessageProcessing(t *testing.T) {
 
 				evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "update_valset", mock.Anything).Return(tx, nil)
 
-				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(555), tx.Hash().Bytes()).Return(nil)
+				// the public access data is only set once the transaction is final
+				evm.On("TrackTransaction", tx, mock.MatchedBy(func(hooks txHooks) bool {
+					return hooks.ref == "queue-name/555"
+				})).Return()
 				return evm, paloma
 			},
 		},
@@ -579,7 +706,10 @@ func TestMessageProcessing(t *testing.T) {
 
 				evm.On("DeployContract", mock.Anything, chainID, string(StoredContracts()["simple"].Source), []byte("bytecode"), []byte("constructor input")).Return(nil, tx, nil)
 
-				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(555), tx.Hash().Bytes()).Return(nil)
+				// the public access data is only set once the transaction is final
+				evm.On("TrackTransaction", tx, mock.MatchedBy(func(hooks txHooks) bool {
+					return hooks.ref == "queue-name/555"
+				})).Return()
 				return evm, paloma
 			},
 		},
@@ -731,6 +861,48 @@ func TestMessageProcessing(t *testing.T) {
 	}
 }
 
+func TestReportTxResult(t *testing.T) {
+	ctx := context.Background()
+	sent := etherumtypes.NewTx(&etherumtypes.LegacyTx{Nonce: 1, GasPrice: big.NewInt(10)})
+	replacement := etherumtypes.NewTx(&etherumtypes.LegacyTx{Nonce: 1, GasPrice: big.NewInt(12)})
+
+	for _, tt := range []struct {
+		name  string
+		res   txResult
+		setup func(paloma *evmmocks.PalomaClienter)
+	}{
+		{
+			name: "the public access data points at the transaction which made it into the block",
+			res:  txResult{status: txMined, tx: replacement},
+			setup: func(paloma *evmmocks.PalomaClienter) {
+				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(7), replacement.Hash().Bytes()).Return(nil).Once()
+			},
+		},
+		{
+			name: "reverted transactions are reported as errors",
+			res:  txResult{status: txReverted, tx: sent},
+			setup: func(paloma *evmmocks.PalomaClienter) {
+				paloma.On("SetPublicAccessData", mock.Anything, "queue-name", uint64(7), sent.Hash().Bytes()).Return(nil).Once()
+				paloma.On("SetErrorData", mock.Anything, "queue-name", uint64(7), mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name:  "nothing is reported if the nonce was used up by another transaction",
+			res:   txResult{status: txReplaced},
+			setup: func(paloma *evmmocks.PalomaClienter) {},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			paloma := evmmocks.NewPalomaClienter(t)
+			tt.setup(paloma)
+
+			comp := compass{paloma: paloma}
+			comp.reportTxResult("queue-name", 7, sent)(ctx, tt.res)
+		})
+	}
+}
+
 func TestProcessingvalidatorBalancesRequest(t *testing.T) {
 	ctx := context.Background()
 	evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
@@ -1030,3 +1202,664 @@ func TestIfTheConsensusHasBeenReached(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupIndependentMessages(t *testing.T) {
+	logicCall := func(id uint64) chain.MessageWithSignatures {
+		return chain.MessageWithSignatures{QueuedMessage: chain.QueuedMessage{
+			ID:  id,
+			Msg: &types.Message{Action: &types.Message_SubmitLogicCall{}},
+		}}
+	}
+	updateValset := func(id uint64) chain.MessageWithSignatures {
+		return chain.MessageWithSignatures{QueuedMessage: chain.QueuedMessage{
+			ID:  id,
+			Msg: &types.Message{Action: &types.Message_UpdateValset{}},
+		}}
+	}
+
+	groups := groupIndependentMessages([]chain.MessageWithSignatures{
+		logicCall(1),
+		logicCall(2),
+		updateValset(3),
+		updateValset(4),
+		logicCall(5),
+	})
+
+	require.Equal(t, [][]chain.MessageWithSignatures{
+		{logicCall(1), logicCall(2)},
+		{updateValset(3)},
+		{updateValset(4)},
+		{logicCall(5)},
+	}, groups)
+}
+
+func TestParallelLogicCallProcessing(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(5)
+	compassAbi := StoredContracts()["compass-evm"]
+
+	msgs := make([]chain.MessageWithSignatures, 0, 5)
+	for id := uint64(1); id <= 5; id++ {
+		msgs = append(msgs, chain.MessageWithSignatures{
+			QueuedMessage: chain.QueuedMessage{
+				ID:          id,
+				BytesToSign: ethCompatibleBytesToSign,
+				Msg: &types.Message{
+					Action: &types.Message_SubmitLogicCall{
+						SubmitLogicCall: &types.SubmitLogicCall{
+							HexContractAddress: "0xABC",
+							Payload:            []byte("payload"),
+							Deadline:           123,
+						},
+					},
+				},
+			},
+			Signatures: []chain.ValidatorSignature{
+				signMessage(ethCompatibleBytesToSign, bobPK),
+			},
+		})
+	}
+
+	evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
+	evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(0), nil)
+	evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil).Once()
+	// the valset is only fetched once for all of the logic calls
+	evm.On("LastValsetID", mock.Anything, mock.Anything).Return(big.NewInt(55), nil).Once()
+	paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(55), "internal-chain-id").Return(
+		&types.Valset{
+			Validators: []string{crypto.PubkeyToAddress(bobPK.PublicKey).Hex()},
+			Powers:     []uint64{testPowerThreshold + 1},
+			ValsetID:   55,
+		},
+		nil,
+	).Once()
+
+	var inFlight, maxInFlight int32
+	var mu sync.Mutex
+	for id := uint64(1); id <= 5; id++ {
+		id := id
+		tx := ethtypes.NewTransaction(id, common.HexToAddress("0x12"), big.NewInt(5), 55, big.NewInt(5), nil)
+		evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_logic_call", mock.MatchedBy(func(args []any) bool {
+			return args[2].(*big.Int).Uint64() == id
+		})).Return(tx, nil).Run(func(mock.Arguments) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		})
+		evm.On("TrackTransaction", tx, mock.Anything).Return()
+	}
+
+	comp := newCompassClient(
+		smartContractAddr.Hex(),
+		"id-123",
+		"internal-chain-id",
+		chainID,
+		&compassAbi.ABI,
+		paloma,
+		evm,
+	)
+	comp.parallelLogicCalls = 2
+
+	require.NoError(t, comp.processMessages(ctx, "queue-name", msgs))
+	require.Equal(t, int32(2), maxInFlight)
+}
+
+func TestCanonicalLogs(t *testing.T) {
+	ctx := context.Background()
+	evm := newMockEvmClienter(t)
+	conn := newMockEthClientConn(t)
+	evm.On("GetEthClient").Return(conn)
+	evm.On("GetQuorumEthClient").Return(conn)
+
+	comp := compass{evm: evm, smartContractAddr: smartContractAddr}
+	query := comp.gravityEventQuery(sendToPalomaEventTopic)
+
+	kept := &ethtypes.Header{Number: big.NewInt(5)}
+	replaced := &ethtypes.Header{Number: big.NewInt(6)}
+	conn.On("HeaderByNumber", mock.Anything, big.NewInt(5)).Return(kept, nil)
+	conn.On("HeaderByNumber", mock.Anything, big.NewInt(6)).Return(replaced, nil)
+
+	canonical := replaced.Hash()
+	rescan := query
+	rescan.BlockHash = &canonical
+	rescanned := ethtypes.Log{BlockNumber: 6, BlockHash: canonical, Index: 3}
+	conn.On("FilterLogs", mock.Anything, rescan).Return([]ethtypes.Log{rescanned}, nil)
+
+	logs, err := comp.canonicalLogs(ctx, query, []ethtypes.Log{
+		{BlockNumber: 5, BlockHash: kept.Hash(), Index: 1},
+		{BlockNumber: 6, BlockHash: common.HexToHash("0x0bad"), Index: 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []ethtypes.Log{
+		{BlockNumber: 5, BlockHash: kept.Hash(), Index: 1},
+		rescanned,
+	}, logs)
+}
+
+func TestResumingGravityScans(t *testing.T) {
+	ctx := context.Background()
+	orchestrator := "paloma1orchestrator"
+	header := func(block uint64) *ethtypes.Header {
+		return &ethtypes.Header{Number: new(big.Int).SetUint64(block)}
+	}
+	event := func(topic common.Hash, block uint64, index uint) ethtypes.Log {
+		return ethtypes.Log{
+			Address:     smartContractAddr,
+			Topics:      []common.Hash{topic},
+			BlockNumber: block,
+			BlockHash:   header(block).Hash(),
+			Index:       index,
+		}
+	}
+
+	setup := func(t *testing.T, st *store.Store) (*compass, *mockEthClientConn, *evmmocks.PalomaClienter) {
+		evm := newMockEvmClienter(t)
+		conn := newMockEthClientConn(t)
+		paloma := evmmocks.NewPalomaClienter(t)
+		evm.On("FindConfirmedBlockNumber", mock.Anything).Return(big.NewInt(20000), nil)
+		evm.On("GetQuorumEthClient").Return(conn)
+		evm.On("GetEthClient").Return(conn).Maybe()
+
+		return &compass{
+			ChainReferenceID:  "eth-main",
+			evm:               evm,
+			paloma:            paloma,
+			smartContractAddr: smartContractAddr,
+			checkpoints:       newCheckpoints(st, "eth-main"),
+			eventNonces:       &eventNonces{},
+		}, conn, paloma
+	}
+	openStore := func(t *testing.T) *store.Store {
+		st, err := store.Open(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { st.Close() })
+		return st
+	}
+
+	scanned := func(comp *compass, from, to int64) ethereum.FilterQuery {
+		query := comp.gravityEventsQuery()
+		query.FromBlock, query.ToBlock = big.NewInt(from), big.NewInt(to)
+		return query
+	}
+
+	t.Run("the scan resumes from the checkpoint in bounded windows", func(t *testing.T) {
+		st := openStore(t)
+		comp, conn, paloma := setup(t, st)
+		paloma.On("QueryGetLastEventNonce", mock.Anything, orchestrator).Return(uint64(5), nil)
+		require.NoError(t, comp.checkpoints.save(eventCheckpoint{block: 101, nonce: 5}))
+
+		conn.On("FilterLogs", mock.Anything, scanned(comp, 101, 5100)).Return(nil, nil).Once()
+		conn.On("FilterLogs", mock.Anything, scanned(comp, 5101, 10100)).Return(nil, nil).Once()
+
+		_, err := comp.GetSendToPalomaEvents(ctx, orchestrator, nil)
+		require.NoError(t, err)
+		_, err = comp.GetBatchSendEvents(ctx, orchestrator, nil)
+		require.NoError(t, err)
+
+		checkpoint, found, err := newCheckpoints(st, "eth-main").load()
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, eventCheckpoint{block: 10101, nonce: 5}, checkpoint)
+	})
+
+	t.Run("without a checkpoint the scan resumes from Paloma's last observed block", func(t *testing.T) {
+		comp, conn, paloma := setup(t, openStore(t))
+		paloma.On("QueryGetLastObservedEthBlock", mock.Anything).Return(uint64(17000), nil)
+		paloma.On("QueryGetLastEventNonce", mock.Anything, orchestrator).Return(uint64(3), nil)
+
+		conn.On("FilterLogs", mock.Anything, scanned(comp, 17001, 20000)).Return(nil, nil).Once()
+
+		_, err := comp.GetBatchSendEvents(ctx, orchestrator, nil)
+		require.NoError(t, err)
+
+		checkpoint, _, err := comp.checkpoints.load()
+		require.NoError(t, err)
+		require.Equal(t, eventCheckpoint{block: 20001, nonce: 3}, checkpoint)
+	})
+
+	t.Run("the checkpoint stays at the first event Paloma didn't acknowledge", func(t *testing.T) {
+		st := openStore(t)
+		logs := []ethtypes.Log{
+			event(batchSendEventTopic, 200, 1),
+			event(sendToPalomaEventTopic, 200, 3),
+			event(batchSendEventTopic, 300, 0),
+		}
+
+		comp, conn, paloma := setup(t, st)
+		require.NoError(t, comp.checkpoints.save(eventCheckpoint{block: 101, nonce: 5}))
+		paloma.On("QueryGetLastEventNonce", mock.Anything, orchestrator).Return(uint64(6), nil)
+		conn.On("FilterLogs", mock.Anything, scanned(comp, 101, 5100)).Return(logs, nil).Once()
+		conn.On("HeaderByNumber", mock.Anything, big.NewInt(200)).Return(header(200), nil)
+		conn.On("HeaderByNumber", mock.Anything, big.NewInt(300)).Return(header(300), nil)
+
+		claims, err := comp.gravityClaims(ctx, orchestrator, nil, batchSendEventTopic)
+		require.NoError(t, err)
+		require.Len(t, claims, 1)
+		require.Equal(t, uint64(8), claims[0].nonce)
+
+		checkpoint, _, err := comp.checkpoints.load()
+		require.NoError(t, err)
+		require.Equal(t, eventCheckpoint{block: 200, index: 3, nonce: 6}, checkpoint)
+
+		// after a restart, the events which weren't acknowledged get the
+		// same nonces again
+		restarted, conn, paloma := setup(t, st)
+		paloma.On("QueryGetLastEventNonce", mock.Anything, orchestrator).Return(uint64(7), nil)
+		conn.On("FilterLogs", mock.Anything, scanned(restarted, 200, 5199)).Return(logs, nil).Once()
+		conn.On("HeaderByNumber", mock.Anything, big.NewInt(200)).Return(header(200), nil)
+		conn.On("HeaderByNumber", mock.Anything, big.NewInt(300)).Return(header(300), nil)
+
+		claims, err = restarted.gravityClaims(ctx, orchestrator, nil, batchSendEventTopic)
+		require.NoError(t, err)
+		require.Len(t, claims, 1)
+		require.Equal(t, uint64(8), claims[0].nonce)
+		require.Equal(t, logs[2], claims[0].log)
+		require.Empty(t, restarted.eventNonces.pending(sendToPalomaEventTopic))
+
+		checkpoint, _, err = restarted.checkpoints.load()
+		require.NoError(t, err)
+		require.Equal(t, eventCheckpoint{block: 300, nonce: 7}, checkpoint)
+	})
+
+	t.Run("the checkpoints of other chains are left alone", func(t *testing.T) {
+		st := openStore(t)
+		require.NoError(t, newCheckpoints(st, "bnb-main").save(eventCheckpoint{block: 100, nonce: 1}))
+
+		_, found, err := newCheckpoints(st, "eth-main").load()
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+}
+
+func TestScanningGravityEvents(t *testing.T) {
+	ctx := context.Background()
+	orchestrator := "paloma1orchestrator"
+	previousAddr := common.HexToAddress("0x0456")
+	tokenContract := common.HexToAddress("0x0789")
+	compassAbi := whoops.Must(abi.JSON(strings.NewReader(`[
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"token","type":"address"},{"indexed":false,"name":"batch_id","type":"uint256"}],"name":"BatchSendEvent","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"token","type":"address"},{"indexed":false,"name":"sender","type":"address"},{"indexed":false,"name":"receiver","type":"string"},{"indexed":false,"name":"amount","type":"uint256"}],"name":"SendToPalomaEvent","type":"event"}
+	]`)))
+	header := func(block uint64) *ethtypes.Header {
+		return &ethtypes.Header{Number: new(big.Int).SetUint64(block)}
+	}
+	batchSend := func(addr common.Address, block uint64, index uint, batchNonce int64) ethtypes.Log {
+		return ethtypes.Log{
+			Address:     addr,
+			Topics:      []common.Hash{batchSendEventTopic},
+			Data:        whoops.Must(compassAbi.Events["BatchSendEvent"].Inputs.Pack(tokenContract, big.NewInt(batchNonce))),
+			BlockNumber: block,
+			BlockHash:   header(block).Hash(),
+			Index:       index,
+		}
+	}
+	sendToPaloma := func(addr common.Address, block uint64, index uint, amount int64) ethtypes.Log {
+		return ethtypes.Log{
+			Address:     addr,
+			Topics:      []common.Hash{sendToPalomaEventTopic},
+			Data:        whoops.Must(compassAbi.Events["SendToPalomaEvent"].Inputs.Pack(tokenContract, tokenContract, "paloma1receiver", big.NewInt(amount))),
+			BlockNumber: block,
+			BlockHash:   header(block).Hash(),
+			Index:       index,
+		}
+	}
+
+	evm := newMockEvmClienter(t)
+	conn := newMockEthClientConn(t)
+	paloma := evmmocks.NewPalomaClienter(t)
+	evm.On("FindConfirmedBlockNumber", mock.Anything).Return(big.NewInt(200), nil)
+	evm.On("GetQuorumEthClient").Return(conn)
+	evm.On("GetEthClient").Return(conn)
+	for _, block := range []uint64{150, 160} {
+		conn.On("HeaderByNumber", mock.Anything, new(big.Int).SetUint64(block)).Return(header(block), nil)
+	}
+	paloma.On("QueryGetLastEventNonce", mock.Anything, orchestrator).Return(uint64(10), nil)
+
+	nonces := &eventNonces{scanned: 100}
+	current := &compass{evm: evm, paloma: paloma, compassAbi: &compassAbi, smartContractAddr: smartContractAddr, eventNonces: nonces}
+	previous := &compass{evm: evm, paloma: paloma, compassAbi: &compassAbi, smartContractAddr: previousAddr, eventNonces: nonces}
+	window := func(comp *compass) ethereum.FilterQuery {
+		query := comp.gravityEventsQuery()
+		query.FromBlock, query.ToBlock = big.NewInt(101), big.NewInt(200)
+		return query
+	}
+	conn.On("FilterLogs", mock.Anything, window(current)).Return([]ethtypes.Log{
+		sendToPaloma(smartContractAddr, 150, 2, 5),
+		batchSend(smartContractAddr, 160, 0, 3),
+	}, nil).Once()
+	conn.On("FilterLogs", mock.Anything, window(previous)).Return([]ethtypes.Log{
+		batchSend(previousAddr, 150, 1, 2),
+		sendToPaloma(previousAddr, 160, 4, 7),
+	}, nil).Once()
+
+	// whichever kind of event is asked for first, the events of both
+	// contracts get their nonces in the order they were emitted in
+	sendToPalomaEvents, err := current.GetSendToPalomaEvents(ctx, orchestrator, previous)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{12, 14}, slice.Map(sendToPalomaEvents, func(e chain.SendToPalomaEvent) uint64 { return e.EventNonce }))
+	require.Equal(t, uint64(5), sendToPalomaEvents[0].Amount)
+
+	batchSendEvents, err := current.GetBatchSendEvents(ctx, orchestrator, previous)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{11, 13}, slice.Map(batchSendEvents, func(e chain.BatchSendEvent) uint64 { return e.EventNonce }))
+	require.Equal(t, []uint64{2, 3}, slice.Map(batchSendEvents, func(e chain.BatchSendEvent) uint64 { return e.BatchNonce }))
+}
+
+func TestGravityRelayBatch(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(5)
+	tokenContract := common.HexToAddress("0x0123")
+	// Paloma only takes error data for the messages of its consensus
+	// queues, so nothing may be reported under any other queue name
+	consensusQueue := mock.MatchedBy(func(name string) bool {
+		q := queue.FromString(name)
+		return q.IsTurnstoneQueue() || q.IsValidatorsValancesQueue()
+	})
+
+	withLastBatchID := whoops.Must(abi.JSON(strings.NewReader(`[
+		{"inputs":[{"name":"arg0","type":"address"}],"name":"last_batch_id","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"token","type":"address"},{"indexed":false,"name":"batch_id","type":"uint256"}],"name":"BatchSendEvent","type":"event"}
+	]`)))
+	withoutLastBatchID := whoops.Must(abi.JSON(strings.NewReader(`[
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"token","type":"address"},{"indexed":false,"name":"batch_id","type":"uint256"}],"name":"BatchSendEvent","type":"event"}
+	]`)))
+
+	newBatch := func(timeout uint64) chain.GravityBatchWithSignatures {
+		batch := chain.GravityBatchWithSignatures{
+			OutgoingTxBatch: gravitytypes.OutgoingTxBatch{
+				BatchNonce:    7,
+				BatchTimeout:  timeout,
+				TokenContract: tokenContract.Hex(),
+			},
+		}
+		batch.Signatures = []chain.ValidatorSignature{signMessage(batch.GetBytesToSign(), bobPK)}
+		return batch
+	}
+	lastBatchID := func(conn *mockEthClientConn, id int64) {
+		conn.On("CallContract", mock.Anything, mock.Anything, (*big.Int)(nil)).Return(
+			whoops.Must(withLastBatchID.Methods["last_batch_id"].Outputs.Pack(big.NewInt(id))), nil,
+		)
+	}
+	validValset := func(evm *mockEvmClienter, paloma *evmmocks.PalomaClienter) {
+		valset := &types.Valset{
+			Validators: []string{crypto.PubkeyToAddress(bobPK.PublicKey).Hex()},
+			Powers:     []uint64{testPowerThreshold + 1},
+			ValsetID:   55,
+		}
+		evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(55), nil)
+		paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(0), "internal-chain-id").Return(valset, nil)
+		paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(55), "internal-chain-id").Return(valset, nil)
+	}
+
+	profitability := &batchProfitability{prices: fixedPriceSource{}, maxLoss: big.NewInt(1000)}
+
+	for _, tt := range []struct {
+		name          string
+		abi           abi.ABI
+		batch         chain.GravityBatchWithSignatures
+		profitability *batchProfitability
+		setup         func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter)
+	}{
+		{
+			name:  "a batch compass accepted already is skipped",
+			abi:   withLastBatchID,
+			batch: newBatch(200),
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 7)
+			},
+		},
+		{
+			name:  "without the last batch ID the batch send events are looked through",
+			abi:   withoutLastBatchID,
+			batch: newBatch(200),
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(20000), nil)
+				evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+					fn := args.Get(3).(func([]etherumtypes.Log) bool)
+					event := withoutLastBatchID.Events["BatchSendEvent"]
+					fn([]etherumtypes.Log{
+						{Data: []byte("garbage")},
+						{Data: whoops.Must(event.Inputs.Pack(common.HexToAddress("0x0456"), big.NewInt(9)))},
+						{Data: whoops.Must(event.Inputs.Pack(tokenContract, big.NewInt(8)))},
+					})
+				}).Return(true, nil)
+			},
+		},
+		{
+			name:  "an expired batch is skipped",
+			abi:   withLastBatchID,
+			batch: newBatch(100),
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 6)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+			},
+		},
+		{
+			name:  "the batch is sent with its timeout as the deadline",
+			abi:   withLastBatchID,
+			batch: newBatch(200),
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 6)
+				validValset(evm, paloma)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+				evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_batch", mock.MatchedBy(func(args []any) bool {
+					return args[1] == tokenContract &&
+						args[3].(*big.Int).Cmp(big.NewInt(7)) == 0 &&
+						args[4].(*big.Int).Cmp(big.NewInt(200)) == 0
+				})).Return(sampleTx1, nil)
+			},
+		},
+		{
+			name:          "a batch which would lose more than the threshold is deferred",
+			abi:           withLastBatchID,
+			batch:         newBatch(200),
+			profitability: profitability,
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 6)
+				validValset(evm, paloma)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+				evm.On("EstimateContractCost", mock.Anything, mock.Anything, smartContractAddr, "submit_batch", mock.Anything).Return(big.NewInt(1001), nil)
+			},
+		},
+		{
+			name:          "a batch within the loss threshold is sent",
+			abi:           withLastBatchID,
+			batch:         newBatch(200),
+			profitability: profitability,
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 6)
+				validValset(evm, paloma)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+				evm.On("EstimateContractCost", mock.Anything, mock.Anything, smartContractAddr, "submit_batch", mock.Anything).Return(big.NewInt(1000), nil)
+				evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_batch", mock.Anything).Return(sampleTx1, nil)
+			},
+		},
+		{
+			name:  "a batch which would revert is skipped",
+			abi:   withLastBatchID,
+			batch: newBatch(200),
+			setup: func(t *testing.T, evm *mockEvmClienter, conn *mockEthClientConn, paloma *evmmocks.PalomaClienter) {
+				lastBatchID(conn, 6)
+				validValset(evm, paloma)
+				evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+				revertErr := &revertError{reason: revertReason{kind: revertKindError, message: "Invalid Signature"}}
+				evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_batch", mock.Anything).Return(nil, revertErr)
+			},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			evm, conn, paloma := newMockEvmClienter(t), newMockEthClientConn(t), evmmocks.NewPalomaClienter(t)
+			evm.On("GetQuorumEthClient").Return(conn).Maybe()
+			paloma.On("SetErrorData", mock.Anything, consensusQueue, mock.Anything, mock.Anything).Return(nil).Maybe()
+			tt.setup(t, evm, conn, paloma)
+
+			comp := newCompassClient(smartContractAddr.Hex(), "id-123", "internal-chain-id", chainID, &tt.abi, paloma, evm)
+			comp.profitability = tt.profitability
+			err := comp.gravityRelayBatches(ctx, []chain.GravityBatchWithSignatures{tt.batch})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValsetUpdatePreemption(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(5)
+	defer func(interval time.Duration) { valsetUpdatePollInterval = interval }(valsetUpdatePollInterval)
+	valsetUpdatePollInterval = time.Millisecond
+	oldValset := &types.Valset{
+		Validators: []string{crypto.PubkeyToAddress(bobPK.PublicKey).Hex()},
+		Powers:     []uint64{testPowerThreshold + 1},
+		ValsetID:   55,
+	}
+	newValset := &types.Valset{
+		Validators: []string{crypto.PubkeyToAddress(alicePK.PublicKey).Hex()},
+		Powers:     []uint64{testPowerThreshold + 1},
+		ValsetID:   56,
+	}
+	logicCall := chain.MessageWithSignatures{
+		QueuedMessage: chain.QueuedMessage{
+			ID:          1,
+			BytesToSign: ethCompatibleBytesToSign,
+			Msg: &types.Message{
+				Action: &types.Message_SubmitLogicCall{
+					SubmitLogicCall: &types.SubmitLogicCall{HexContractAddress: "0xABC"},
+				},
+			},
+		},
+		Signatures: []chain.ValidatorSignature{signMessage(ethCompatibleBytesToSign, alicePK)},
+	}
+	update := chain.MessageWithSignatures{
+		QueuedMessage: chain.QueuedMessage{
+			ID:          2,
+			BytesToSign: ethCompatibleBytesToSign,
+			Msg: &types.Message{
+				Action: &types.Message_UpdateValset{
+					UpdateValset: &types.UpdateValset{Valset: newValset},
+				},
+			},
+		},
+		Signatures: []chain.ValidatorSignature{signMessage(ethCompatibleBytesToSign, bobPK)},
+	}
+	updateTx := etherumtypes.NewTransaction(1, common.HexToAddress("0x12"), big.NewInt(1), 21000, big.NewInt(1), nil)
+	logicCallTx := etherumtypes.NewTransaction(2, common.HexToAddress("0x12"), big.NewInt(1), 21000, big.NewInt(1), nil)
+
+	evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
+	paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(0), "internal-chain-id").Return(newValset, nil)
+	paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(55), "internal-chain-id").Return(oldValset, nil)
+	paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(56), "internal-chain-id").Return(newValset, nil)
+
+	// compass runs with the old valset until the update made it into a block
+	evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(55), nil).Times(3)
+	evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(56), nil)
+
+	var relayed []string
+	evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "update_valset", mock.Anything).Run(func(mock.Arguments) {
+		relayed = append(relayed, "update_valset")
+	}).Return(updateTx, nil)
+	evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_logic_call", mock.Anything).Run(func(mock.Arguments) {
+		relayed = append(relayed, "submit_logic_call")
+	}).Return(logicCallTx, nil)
+
+	evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(0), nil)
+	evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	evm.On("TrackTransaction", mock.Anything, mock.Anything).Return()
+
+	compassAbi := StoredContracts()["compass-evm"]
+	comp := newCompassClient(smartContractAddr.Hex(), "id-123", "internal-chain-id", chainID, &compassAbi.ABI, paloma, evm)
+
+	require.NoError(t, comp.processMessages(ctx, "queue-name", []chain.MessageWithSignatures{logicCall, update}))
+	require.Equal(t, []string{"update_valset", "submit_logic_call"}, relayed)
+}
+
+func TestGravityBatchValsetCatchUp(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(5)
+	defer func(interval time.Duration) { valsetUpdatePollInterval = interval }(valsetUpdatePollInterval)
+	valsetUpdatePollInterval = time.Millisecond
+	turnstoneQueue := "evm/internal-chain-id/evm-turnstone-message"
+	oldValset := &types.Valset{
+		Validators: []string{crypto.PubkeyToAddress(alicePK.PublicKey).Hex()},
+		Powers:     []uint64{testPowerThreshold + 1},
+		ValsetID:   55,
+	}
+	newValset := &types.Valset{
+		Validators: []string{crypto.PubkeyToAddress(bobPK.PublicKey).Hex()},
+		Powers:     []uint64{testPowerThreshold + 1},
+		ValsetID:   56,
+	}
+	update := chain.MessageWithSignatures{
+		QueuedMessage: chain.QueuedMessage{
+			ID:          2,
+			BytesToSign: ethCompatibleBytesToSign,
+			Msg: &types.Message{
+				Action: &types.Message_UpdateValset{
+					UpdateValset: &types.UpdateValset{Valset: newValset},
+				},
+			},
+		},
+		Signatures: []chain.ValidatorSignature{signMessage(ethCompatibleBytesToSign, alicePK)},
+	}
+	// the batch is signed by the new valset only
+	batch := chain.GravityBatchWithSignatures{
+		OutgoingTxBatch: gravitytypes.OutgoingTxBatch{
+			BatchNonce:    7,
+			BatchTimeout:  200,
+			TokenContract: common.HexToAddress("0x0123").Hex(),
+		},
+	}
+	batch.Signatures = []chain.ValidatorSignature{signMessage(batch.GetBytesToSign(), bobPK)}
+	compassAbi := StoredContracts()["compass-evm"]
+
+	setup := func(t *testing.T) (*mockEvmClienter, *evmmocks.PalomaClienter) {
+		evm, paloma := newMockEvmClienter(t), evmmocks.NewPalomaClienter(t)
+		paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(0), "internal-chain-id").Return(newValset, nil)
+		paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(55), "internal-chain-id").Return(oldValset, nil).Maybe()
+		paloma.On("QueryGetEVMValsetByID", mock.Anything, uint64(56), "internal-chain-id").Return(newValset, nil).Maybe()
+		evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(20000), nil)
+		evm.On("FilterLogs", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+		evm.On("FindCurrentBlockTime", mock.Anything).Return(time.Unix(100, 0), nil)
+		return evm, paloma
+	}
+
+	t.Run("the queued valset update is relayed before the batch", func(t *testing.T) {
+		evm, paloma := setup(t)
+		paloma.On("QueryMessagesForRelaying", mock.Anything, turnstoneQueue).Return([]chain.MessageWithSignatures{update}, nil)
+
+		// compass runs with the old valset until the update made it into a
+		// block
+		evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(55), nil).Times(3)
+		evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(56), nil)
+
+		var relayed []string
+		evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "update_valset", mock.Anything).Run(func(mock.Arguments) {
+			relayed = append(relayed, "update_valset")
+		}).Return(sampleTx1, nil)
+		evm.On("ExecuteSmartContract", mock.Anything, chainID, mock.Anything, smartContractAddr, false, "submit_batch", mock.Anything).Run(func(mock.Arguments) {
+			relayed = append(relayed, "submit_batch")
+		}).Return(sampleTx1, nil)
+		evm.On("TrackTransaction", mock.Anything, mock.Anything).Return()
+
+		comp := newCompassClient(smartContractAddr.Hex(), "id-123", "internal-chain-id", chainID, &compassAbi.ABI, paloma, evm)
+		require.NoError(t, comp.gravityRelayBatches(ctx, []chain.GravityBatchWithSignatures{batch}))
+		require.Equal(t, []string{"update_valset", "submit_batch"}, relayed)
+	})
+
+	t.Run("the batch is held back while compass is behind", func(t *testing.T) {
+		evm, paloma := setup(t)
+		paloma.On("QueryMessagesForRelaying", mock.Anything, turnstoneQueue).Return(nil, nil)
+		evm.On("LastValsetID", mock.Anything, smartContractAddr).Return(big.NewInt(55), nil)
+
+		comp := newCompassClient(smartContractAddr.Hex(), "id-123", "internal-chain-id", chainID, &compassAbi.ABI, paloma, evm)
+		require.NoError(t, comp.gravityRelayBatches(ctx, []chain.GravityBatchWithSignatures{batch}))
+		evm.AssertNotCalled(t, "ExecuteSmartContract", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,188 @@
+package evm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/palomachain/pigeon/chain"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultCompassHandoverWindow is how long the previous compass of a chain is
+// still relayed to and watched after an upgrade.
+const defaultCompassHandoverWindow = time.Hour
+
+// compassHandover is a compass which was replaced by an upgrade, but which
+// still gets the messages created for it until the window closes.
+type compassHandover struct {
+	compass *compass
+	until   time.Time
+}
+
+func (h *compassHandover) active(now time.Time) bool {
+	return h != nil && now.Before(h.until)
+}
+
+// compassVersions remembers the compass each chain's processor was last
+// built with, so that a processor built after an upgrade can hand over from
+// the compass it replaces. The compasses are persisted in the store, if there
+// is one, so that a restarted pigeon keeps handing over, and notices the
+// upgrades which happened while it was down.
+type compassVersions struct {
+	mu       sync.Mutex
+	store    CheckpointStore
+	active   map[string]*compass
+	previous map[string]compassHandover
+}
+
+// activate makes comp the active compass of its chain, and returns the
+// compass it is handing over from, or nil if there is none. The event nonces
+// of a chain are carried over from the compass comp replaces, as their
+// sequence doesn't restart with an upgrade.
+func (v *compassVersions) activate(comp *compass, window time.Duration, now time.Time) *compassHandover {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.active == nil {
+		v.active = make(map[string]*compass)
+		v.previous = make(map[string]compassHandover)
+	}
+
+	chainReferenceID := comp.ChainReferenceID
+	last, ok := v.active[chainReferenceID]
+	if !ok {
+		last, ok = v.restore(comp)
+	}
+	v.active[chainReferenceID] = comp
+	if ok {
+		comp.eventNonces = last.eventNonces
+		if last.smartContractAddr != comp.smartContractAddr {
+			log.WithFields(log.Fields{
+				"chain-reference-id": chainReferenceID,
+				"previous-compass":   last.smartContractAddr.Hex(),
+				"compass":            comp.smartContractAddr.Hex(),
+				"handover-until":     now.Add(window),
+			}).Info("compass was upgraded, handing over from the previous one")
+			v.previous[chainReferenceID] = compassHandover{compass: last, until: now.Add(window)}
+		}
+	}
+
+	h, ok := v.previous[chainReferenceID]
+	if !ok || !h.active(now) || h.compass.smartContractAddr == comp.smartContractAddr {
+		delete(v.previous, chainReferenceID)
+		v.save(comp, nil)
+		return nil
+	}
+
+	v.save(comp, &h)
+	return &h
+}
+
+// storedCompass is a compass contract as it is persisted.
+type storedCompass struct {
+	ID          string `json:"id"`
+	Address     string `json:"address"`
+	BlockHeight int64  `json:"block-height"`
+}
+
+// storedCompasses are the compasses of a chain as they are persisted.
+type storedCompasses struct {
+	Active   storedCompass  `json:"active"`
+	Previous *storedCompass `json:"previous,omitempty"`
+	Until    time.Time      `json:"until,omitempty"`
+}
+
+func newStoredCompass(comp *compass) storedCompass {
+	return storedCompass{
+		ID:          comp.CompassID,
+		Address:     comp.smartContractAddr.Hex(),
+		BlockHeight: comp.startingBlockHeight,
+	}
+}
+
+func compassesKey(chainReferenceID string) []byte {
+	return []byte(fmt.Sprintf("checkpoint/%s/compasses", chainReferenceID))
+}
+
+// restore returns the compass which was active before pigeon restarted, if
+// it isn't comp, and restores the handover from the compass before it.
+func (v *compassVersions) restore(comp *compass) (*compass, bool) {
+	if v.store == nil {
+		return nil, false
+	}
+
+	logger := log.WithField("chain-reference-id", comp.ChainReferenceID)
+	value, found, err := v.store.Get(compassesKey(comp.ChainReferenceID))
+	if err != nil {
+		logger.WithError(err).Warn("failed to load the compass versions")
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+
+	var stored storedCompasses
+	if err := json.Unmarshal(value, &stored); err != nil {
+		logger.WithError(err).Warn("failed to load the compass versions")
+		return nil, false
+	}
+
+	if common.HexToAddress(stored.Active.Address) != comp.smartContractAddr {
+		return comp.replaced(stored.Active), true
+	}
+	if stored.Previous != nil {
+		v.previous[comp.ChainReferenceID] = compassHandover{compass: comp.replaced(*stored.Previous), until: stored.Until}
+	}
+
+	return nil, false
+}
+
+// save persists comp as the active compass of its chain, handing over as
+// given.
+func (v *compassVersions) save(comp *compass, h *compassHandover) {
+	if v.store == nil {
+		return
+	}
+
+	stored := storedCompasses{Active: newStoredCompass(comp)}
+	if h != nil {
+		prev := newStoredCompass(h.compass)
+		stored.Previous, stored.Until = &prev, h.until
+	}
+
+	value, err := json.Marshal(stored)
+	if err == nil {
+		err = v.store.Put(compassesKey(comp.ChainReferenceID), value)
+	}
+	if err != nil {
+		log.WithError(err).WithField("chain-reference-id", comp.ChainReferenceID).Warn("failed to save the compass versions")
+	}
+}
+
+// replaced returns the compass which comp replaced, as it was stored. It
+// talks to the chain like comp does.
+func (t *compass) replaced(stored storedCompass) *compass {
+	addr := common.HexToAddress(stored.Address)
+	prev := *t
+	prev.CompassID = stored.ID
+	prev.smartContractAddr = addr
+	prev.startingBlockHeight = stored.BlockHeight
+	prev.gravityWatcher = nil
+	if t.eventIndex != nil {
+		prev.eventIndex = newEventIndex(t.eventIndex.store, t.ChainReferenceID, addr, t.compassAbi, uint64(stored.BlockHeight))
+	}
+	return &prev
+}
+
+func compassVersion(comp *compass) chain.CompassVersion {
+	return chain.CompassVersion{
+		// the unique IDs are the block heights at which the contracts were
+		// uploaded, padded with zeros
+		SmartContractID: strings.TrimRight(comp.CompassID, "\x00"),
+		Address:         comp.smartContractAddr.Hex(),
+	}
+}
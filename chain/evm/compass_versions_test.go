@@ -0,0 +1,131 @@
+package evm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	evmtypes "github.com/palomachain/paloma/x/evm/types"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/internal/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompassHandover(t *testing.T) {
+	oldAddr := common.HexToAddress("0x0123")
+	newAddr := common.HexToAddress("0x0456")
+	now := time.Now()
+	newCompass := func(id string, addr common.Address) *compass {
+		return &compass{
+			CompassID:         id + "\x00\x00",
+			ChainReferenceID:  "eth-main",
+			smartContractAddr: addr,
+			eventNonces:       &eventNonces{},
+		}
+	}
+
+	var versions compassVersions
+	old := newCompass("100", oldAddr)
+
+	t.Run("the first compass of a chain has nothing to hand over from", func(t *testing.T) {
+		require.Nil(t, versions.activate(old, time.Hour, now))
+	})
+
+	t.Run("rebuilding with the same compass keeps the event nonces", func(t *testing.T) {
+		rebuilt := newCompass("100", oldAddr)
+		require.Nil(t, versions.activate(rebuilt, time.Hour, now))
+		require.Same(t, old.eventNonces, rebuilt.eventNonces)
+		old = rebuilt
+	})
+
+	upgraded := newCompass("200", newAddr)
+
+	t.Run("an upgrade hands over from the previous compass", func(t *testing.T) {
+		h := versions.activate(upgraded, time.Hour, now)
+		require.NotNil(t, h)
+		require.Same(t, old, h.compass)
+		require.Equal(t, now.Add(time.Hour), h.until)
+		require.Same(t, old.eventNonces, upgraded.eventNonces)
+	})
+
+	t.Run("the handover survives rebuilds of the processor", func(t *testing.T) {
+		h := versions.activate(newCompass("200", newAddr), time.Hour, now.Add(time.Minute))
+		require.NotNil(t, h)
+		require.Same(t, old, h.compass)
+	})
+
+	t.Run("the handover ends with its window", func(t *testing.T) {
+		require.Nil(t, versions.activate(newCompass("200", newAddr), time.Hour, now.Add(time.Hour)))
+		require.Nil(t, versions.activate(newCompass("200", newAddr), time.Hour, now))
+	})
+
+	t.Run("messages are routed to the compass they were created for", func(t *testing.T) {
+		p := Processor{
+			compass:         upgraded,
+			previousCompass: &compassHandover{compass: old, until: time.Now().Add(time.Hour)},
+		}
+		msg := func(id uint64, compassAddr string) chain.MessageWithSignatures {
+			return chain.MessageWithSignatures{QueuedMessage: chain.QueuedMessage{
+				ID:  id,
+				Msg: &evmtypes.Message{CompassAddr: compassAddr},
+			}}
+		}
+		msgs := []chain.MessageWithSignatures{
+			msg(1, oldAddr.Hex()),
+			msg(2, newAddr.Hex()),
+			msg(3, ""),
+		}
+
+		current, previous := p.routeMessages(msgs)
+		require.Equal(t, []chain.MessageWithSignatures{msgs[1], msgs[2]}, current)
+		require.Equal(t, []chain.MessageWithSignatures{msgs[0]}, previous)
+
+		require.Equal(t, chain.CompassVersions{
+			Active:        chain.CompassVersion{SmartContractID: "200", Address: newAddr.Hex()},
+			Previous:      &chain.CompassVersion{SmartContractID: "100", Address: oldAddr.Hex()},
+			HandoverUntil: p.previousCompass.until,
+		}, p.CompassVersions())
+
+		// once the window closed, everything goes to the active compass
+		p.previousCompass.until = time.Now().Add(-time.Second)
+		current, previous = p.routeMessages(msgs)
+		require.Equal(t, msgs, current)
+		require.Empty(t, previous)
+		require.Nil(t, p.CompassVersions().Previous)
+	})
+
+	t.Run("a restarted pigeon keeps handing over", func(t *testing.T) {
+		st, err := store.Open(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { st.Close() })
+
+		before := compassVersions{store: st}
+		require.Nil(t, before.activate(newCompass("100", oldAddr), time.Hour, now))
+		require.NotNil(t, before.activate(newCompass("200", newAddr), time.Hour, now))
+
+		restarted := compassVersions{store: st}
+		h := restarted.activate(newCompass("200", newAddr), time.Hour, now.Add(time.Minute))
+		require.NotNil(t, h)
+		require.Equal(t, oldAddr, h.compass.smartContractAddr)
+		require.Equal(t, "100\x00\x00", h.compass.CompassID)
+		require.True(t, h.until.Equal(now.Add(time.Hour)))
+
+		restarted = compassVersions{store: st}
+		require.Nil(t, restarted.activate(newCompass("200", newAddr), time.Hour, now.Add(time.Hour)))
+	})
+
+	t.Run("an upgrade while pigeon was down is handed over from", func(t *testing.T) {
+		st, err := store.Open(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { st.Close() })
+
+		before := compassVersions{store: st}
+		require.Nil(t, before.activate(newCompass("100", oldAddr), time.Hour, now))
+
+		restarted := compassVersions{store: st}
+		h := restarted.activate(newCompass("200", newAddr), time.Hour, now.Add(time.Minute))
+		require.NotNil(t, h)
+		require.Equal(t, oldAddr, h.compass.smartContractAddr)
+		require.Equal(t, now.Add(time.Minute+time.Hour), h.until)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/VolumeFi/whoops"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -22,6 +23,7 @@ import (
 	arbkeystore "github.com/roodeag/arbitrum/accounts/keystore"
 	arbcommon "github.com/roodeag/arbitrum/common"
 	arbtypes "github.com/roodeag/arbitrum/core/types"
+	arbclient "github.com/roodeag/arbitrum/ethclient"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -43,34 +45,40 @@ func (c *Client) DeployContract(
 		c.conn,
 		c.keystore,
 		c.addr,
+		c.nonces,
 		chainID,
 		rawABI,
 		bytecode,
 		constructorInput,
-		c.config.GasAdjustment,
-		c.config.TxType,
+		c.gas,
 	)
 }
 
 func deployContract(
 	ctx context.Context,
-	ethClient bind.ContractBackend,
+	ethClient ethClienter,
 	ks *keystore.KeyStore,
 	signingAddr common.Address,
+	nonces *nonceManager,
 	chainID *big.Int,
 	rawABI string,
 	bytecode []byte,
 	constructorInput []byte,
-	gasAdjustment float64,
-	txType uint8,
+	gas gasStrategy,
 ) (contractAddr common.Address, tx *ethtypes.Transaction, err error) {
 	logger := liblog.WithContext(ctx).WithField("chainID", chainID)
 	err = whoops.Try(func() {
-		nonce, err := ethClient.PendingNonceAt(ctx, signingAddr)
+		nonce, err := nonces.allocate(ctx, func(ctx context.Context) (uint64, error) {
+			return ethClient.PendingNonceAt(ctx, signingAddr)
+		})
 		whoops.Assert(err)
 
-		gasPrice, err := ethClient.SuggestGasPrice(ctx)
-		whoops.Assert(err)
+		sent := false
+		defer func() {
+			if !sent {
+				nonces.release(nonce)
+			}
+		}()
 
 		txOpts, err := bind.NewKeyStoreTransactorWithChainID(
 			ks,
@@ -81,26 +89,12 @@ func deployContract(
 
 		txOpts.Nonce = big.NewInt(int64(nonce))
 		txOpts.From = signingAddr
-		// adjusting the gas price
-		if txType != 2 && gasAdjustment > 1.0 {
-			gasAdj := big.NewFloat(gasAdjustment)
-			gasAdj = gasAdj.Mul(gasAdj, new(big.Float).SetInt(gasPrice))
-			gasPrice, _ = gasAdj.Int(big.NewInt(0))
-		}
-
-		var gasTipCap *big.Int
+		price, err := gas.price(ctx, ethClient)
+		whoops.Assert(err)
 
-		if txType == 2 {
-			gasPrice = gasPrice.Mul(gasPrice, big.NewInt(2)) // double gas price for EIP-1559 tx
-			gasTipCap, err = ethClient.SuggestGasTipCap(ctx)
-			whoops.Assert(err)
-			gasPrice = gasPrice.Add(gasPrice, gasTipCap)
-			logger.WithFields(log.Fields{
-				"gas-max-price": gasPrice,
-				"gas-max-tip":   gasTipCap,
-			}).Info("adjusted eip-1559 gas price")
-			txOpts.GasFeeCap = gasPrice
-			txOpts.GasTipCap = gasTipCap
+		if price.isDynamic() {
+			txOpts.GasFeeCap = price.gasFeeCap
+			txOpts.GasTipCap = price.gasTipCap
 			logger = logger.WithFields(log.Fields{
 				"gas-limit":     txOpts.GasLimit,
 				"gas-max-price": txOpts.GasFeeCap,
@@ -110,10 +104,7 @@ func deployContract(
 				"tx-type":       2,
 			})
 		} else {
-			logger.WithFields(log.Fields{
-				"gas-price": gasPrice,
-			}).Info("adjusted legacy gas price")
-			txOpts.GasPrice = gasPrice
+			txOpts.GasPrice = price.gasPrice
 			logger = logger.WithFields(log.Fields{
 				"gas-limit": txOpts.GasLimit,
 				"gas-price": txOpts.GasPrice,
@@ -149,7 +140,11 @@ func deployContract(
 		)
 		constructorArgs, _ = contractABI.Constructor.Inputs.Unpack(constructorInput)
 
+		if isNonceError(err) {
+			nonces.resync()
+		}
 		whoops.Assert(err)
+		sent = true
 		if tx.Type() == 2 {
 			logger.WithFields(log.Fields{
 				"tx-hash":          tx.Hash(),
@@ -175,22 +170,29 @@ func deployContract(
 func deployContractArbitrum(
 	ctx context.Context,
 	ethClient arbbind.ContractBackend,
+	gasBackend gasPricingBackend,
 	ks *arbkeystore.KeyStore,
 	signingAddr arbcommon.Address,
+	nonces *nonceManager,
 	chainID *big.Int,
 	contractAbi arbabi.ABI,
 	bytecode []byte,
 	constructorInput []byte,
-	gasAdjustment float64,
-	txType uint8,
+	gas gasStrategy,
 ) (contractAddr arbcommon.Address, tx *arbtypes.Transaction, err error) {
 	logger := log.WithField("chainID", chainID)
 	err = whoops.Try(func() {
-		nonce, err := ethClient.PendingNonceAt(ctx, signingAddr)
+		nonce, err := nonces.allocate(ctx, func(ctx context.Context) (uint64, error) {
+			return ethClient.PendingNonceAt(ctx, signingAddr)
+		})
 		whoops.Assert(err)
 
-		gasPrice, err := ethClient.SuggestGasPrice(ctx)
-		whoops.Assert(err)
+		sent := false
+		defer func() {
+			if !sent {
+				nonces.release(nonce)
+			}
+		}()
 
 		txOpts, err := arbbind.NewKeyStoreTransactorWithChainID(
 			ks,
@@ -201,26 +203,12 @@ func deployContractArbitrum(
 
 		txOpts.Nonce = big.NewInt(int64(nonce))
 		txOpts.From = signingAddr
-		// adjusting the gas price
-		if txType != 2 && gasAdjustment > 1.0 {
-			gasAdj := big.NewFloat(gasAdjustment)
-			gasAdj = gasAdj.Mul(gasAdj, new(big.Float).SetInt(gasPrice))
-			gasPrice, _ = gasAdj.Int(big.NewInt(0))
-		}
-
-		var gasTipCap *big.Int
+		price, err := gas.price(ctx, gasBackend)
+		whoops.Assert(err)
 
-		if txType == 2 {
-			gasPrice = gasPrice.Mul(gasPrice, big.NewInt(2)) // double gas price for EIP-1559 tx
-			gasTipCap, err = ethClient.SuggestGasTipCap(ctx)
-			whoops.Assert(err)
-			gasPrice = gasPrice.Add(gasPrice, gasTipCap)
-			logger.WithFields(log.Fields{
-				"gas-max-price": gasPrice,
-				"gas-max-tip":   gasTipCap,
-			}).Info("adjusted eip-1559 gas price")
-			txOpts.GasFeeCap = gasPrice
-			txOpts.GasTipCap = gasTipCap
+		if price.isDynamic() {
+			txOpts.GasFeeCap = price.gasFeeCap
+			txOpts.GasTipCap = price.gasTipCap
 			logger = logger.WithFields(log.Fields{
 				"gas-limit":     txOpts.GasLimit,
 				"gas-max-price": txOpts.GasFeeCap,
@@ -230,10 +218,7 @@ func deployContractArbitrum(
 				"tx-type":       2,
 			})
 		} else {
-			logger.WithFields(log.Fields{
-				"gas-price": gasPrice,
-			}).Info("adjusted legacy gas price")
-			txOpts.GasPrice = gasPrice
+			txOpts.GasPrice = price.gasPrice
 			logger = logger.WithFields(log.Fields{
 				"gas-limit": txOpts.GasLimit,
 				"gas-price": txOpts.GasPrice,
@@ -262,7 +247,11 @@ func deployContractArbitrum(
 		)
 		constructorArgs, _ = contractAbi.Constructor.Inputs.Unpack(constructorInput)
 
+		if isNonceError(err) {
+			nonces.resync()
+		}
 		whoops.Assert(err)
+		sent = true
 		if tx.Type() == 2 {
 			logger.WithFields(log.Fields{
 				"tx-hash":          tx.Hash(),
@@ -320,14 +309,15 @@ func (c *Client) wrapArbitrumDeployment(
 	_, atx, err = deployContractArbitrum(
 		ctx,
 		c.arbcon,
+		arbGasPricingBackend{c.arbcon},
 		keystore,
 		*addr,
+		c.nonces,
 		chainID,
 		arbContractABI,
 		bytecode,
 		constructorInput,
-		c.config.GasAdjustment,
-		c.config.TxType,
+		c.gas,
 	)
 
 	if err != nil {
@@ -351,3 +341,23 @@ func (c *Client) wrapArbitrumDeployment(
 	})
 	return
 }
+
+// arbGasPricingBackend makes the Arbitrum client usable by the gas
+// strategies, which expect the go-ethereum types.
+type arbGasPricingBackend struct {
+	*arbclient.Client
+}
+
+func (b arbGasPricingBackend) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	history, err := b.Client.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ethereum.FeeHistory{
+		OldestBlock:  history.OldestBlock,
+		Reward:       history.Reward,
+		BaseFee:      history.BaseFee,
+		GasUsedRatio: history.GasUsedRatio,
+	}, nil
+}
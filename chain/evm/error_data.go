@@ -0,0 +1,129 @@
+package evm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	errorCategoryExpired           = "expired"
+	errorCategoryReverted          = "reverted"
+	errorCategoryConsensusFailure  = "consensus-failure"
+	errorCategoryAlreadyExecuted   = "already-executed"
+	errorCategoryInsufficientFunds = "insufficient-funds"
+	errorCategoryUnknown           = "unknown"
+)
+
+// revertCategories map the revert reasons of compass, and of the contracts
+// it calls, to error categories. The reasons are matched in order and
+// case-insensitively, so "Insufficient Power" is a consensus failure and not
+// a lack of funds.
+var revertCategories = []struct {
+	category string
+	keywords []string
+}{
+	{errorCategoryConsensusFailure, []string{"signature", "power", "consensus", "checkpoint", "valset"}},
+	{errorCategoryExpired, []string{"timeout", "deadline", "expired"}},
+	{errorCategoryAlreadyExecuted, []string{"used message", "message_id", "messageid", "already"}},
+	{errorCategoryInsufficientFunds, []string{"insufficient funds", "insufficient balance"}},
+}
+
+// errorData is reported to Paloma for messages which pigeon decided not to
+// relay, or which failed on the target chain.
+type errorData struct {
+	Category  string      `json:"category"`
+	Message   string      `json:"message"`
+	Deadline  int64       `json:"deadline,omitempty"`
+	BlockTime int64       `json:"block-time,omitempty"`
+	TxHash    string      `json:"tx-hash,omitempty"`
+	Revert    *revertData `json:"revert,omitempty"`
+}
+
+// revertData is the decoded revert reason of a failed contract call.
+type revertData struct {
+	Kind      string   `json:"kind"`
+	Reason    string   `json:"reason,omitempty"`
+	PanicCode string   `json:"panic-code,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Args      []string `json:"args,omitempty"`
+	Data      string   `json:"data,omitempty"`
+}
+
+func newRevertData(reason revertReason) *revertData {
+	res := &revertData{Kind: reason.kind}
+	switch reason.kind {
+	case revertKindError:
+		res.Reason = reason.message
+	case revertKindPanic:
+		res.PanicCode = fmt.Sprintf("0x%x", reason.panicCode)
+	case revertKindCustom:
+		res.Error = reason.message
+		for _, arg := range reason.args {
+			res.Args = append(res.Args, fmt.Sprintf("%v", arg))
+		}
+	}
+	if len(reason.data) > 0 {
+		res.Data = hexutil.Encode(reason.data)
+	}
+	return res
+}
+
+// newErrorData builds the error data for an error returned while relaying a
+// message. The second return value tells whether the error was caused by the
+// contract reverting, which retrying won't fix.
+func newErrorData(contractABI *abi.ABI, err error) (errorData, bool) {
+	res := errorData{
+		Category: errorCategoryUnknown,
+		Message:  err.Error(),
+	}
+
+	var reason revertReason
+	var reverted bool
+	var revertErr *revertError
+	if errors.As(err, &revertErr) {
+		reason, reverted = revertErr.reason, true
+	} else {
+		reason, reverted = revertFromError(contractABI, err)
+	}
+
+	if reverted {
+		res.Category = errorCategoryReverted
+		res.Revert = newRevertData(reason)
+	}
+
+	// the decoded reason is the most precise thing to go by, but reverts
+	// without one may still carry it in the message of the node
+	text := err.Error()
+	if reason.kind == revertKindError || reason.kind == revertKindCustom {
+		text = reason.message
+	}
+
+	switch {
+	case errors.Is(err, ErrNoConsensus):
+		res.Category = errorCategoryConsensusFailure
+	case !reverted && strings.Contains(strings.ToLower(text), "insufficient funds"):
+		res.Category = errorCategoryInsufficientFunds
+	case reverted:
+		if category, ok := categorizeRevert(text); ok {
+			res.Category = category
+		}
+	}
+
+	return res, reverted
+}
+
+func categorizeRevert(reason string) (string, bool) {
+	reason = strings.ToLower(reason)
+	for _, c := range revertCategories {
+		for _, keyword := range c.keywords {
+			if strings.Contains(reason, keyword) {
+				return c.category, true
+			}
+		}
+	}
+	return "", false
+}
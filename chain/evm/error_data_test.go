@@ -0,0 +1,118 @@
+package evm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewErrorData(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"InvalidSignature","inputs":[{"name":"index","type":"uint256"}]}]`))
+	require.NoError(t, err)
+	uint256Type, err := abi.NewType("uint256", "", nil)
+	require.NoError(t, err)
+	stringType, err := abi.NewType("string", "", nil)
+	require.NoError(t, err)
+
+	nodeError := func(selector []byte, typ abi.Type, value any) error {
+		packed, err := abi.Arguments{{Type: typ}}.Pack(value)
+		require.NoError(t, err)
+		return fakeJsonRpcError(hexutil.Encode(append(append([]byte{}, selector...), packed...)))
+	}
+	invalidSignature := contractABI.Errors["InvalidSignature"].ID
+
+	for _, tt := range []struct {
+		name        string
+		err         error
+		expReverted bool
+		expCategory string
+		checkRevert func(*testing.T, *revertData)
+	}{
+		{
+			name:        "deadline reverts",
+			err:         nodeError(errorStringSelector, stringType, "Timeout"),
+			expReverted: true,
+			expCategory: errorCategoryExpired,
+			checkRevert: func(t *testing.T, data *revertData) {
+				require.Equal(t, revertKindError, data.Kind)
+				require.Equal(t, "Timeout", data.Reason)
+			},
+		},
+		{
+			name:        "invalid signatures",
+			err:         nodeError(errorStringSelector, stringType, "Invalid Signature"),
+			expReverted: true,
+			expCategory: errorCategoryConsensusFailure,
+		},
+		{
+			name:        "not enough power is not a lack of funds",
+			err:         nodeError(errorStringSelector, stringType, "Insufficient Power"),
+			expReverted: true,
+			expCategory: errorCategoryConsensusFailure,
+		},
+		{
+			name:        "messages which were already executed",
+			err:         nodeError(errorStringSelector, stringType, "Used Message_ID"),
+			expReverted: true,
+			expCategory: errorCategoryAlreadyExecuted,
+		},
+		{
+			name:        "custom errors",
+			err:         nodeError(invalidSignature[:4], uint256Type, big.NewInt(3)),
+			expReverted: true,
+			expCategory: errorCategoryConsensusFailure,
+			checkRevert: func(t *testing.T, data *revertData) {
+				require.Equal(t, revertKindCustom, data.Kind)
+				require.Equal(t, "InvalidSignature", data.Error)
+				require.Equal(t, []string{"3"}, data.Args)
+			},
+		},
+		{
+			name:        "panics",
+			err:         nodeError(panicSelector, uint256Type, big.NewInt(0x11)),
+			expReverted: true,
+			expCategory: errorCategoryReverted,
+			checkRevert: func(t *testing.T, data *revertData) {
+				require.Equal(t, revertKindPanic, data.Kind)
+				require.Equal(t, "0x11", data.PanicCode)
+			},
+		},
+		{
+			name:        "simulated reverts",
+			err:         &revertError{reason: revertReason{kind: revertKindError, message: "Deadline passed"}},
+			expReverted: true,
+			expCategory: errorCategoryExpired,
+		},
+		{
+			name:        "insufficient funds",
+			err:         errors.New("insufficient funds for gas * price + value"),
+			expCategory: errorCategoryInsufficientFunds,
+		},
+		{
+			name:        "no consensus",
+			err:         ErrNoConsensus,
+			expCategory: errorCategoryConsensusFailure,
+		},
+		{
+			name:        "anything else",
+			err:         errors.New("connection refused"),
+			expCategory: errorCategoryUnknown,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, reverted := newErrorData(&contractABI, tt.err)
+			require.Equal(t, tt.expReverted, reverted)
+			require.Equal(t, tt.expCategory, data.Category)
+			require.Equal(t, tt.err.Error(), data.Message)
+			require.Equal(t, tt.expReverted, data.Revert != nil)
+			if tt.checkRevert != nil {
+				tt.checkRevert(t, data.Revert)
+			}
+		})
+	}
+}
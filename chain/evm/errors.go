@@ -15,7 +15,34 @@ const (
 
 	ErrNoConsensus = whoops.String("no consensus reached")
 
+	ErrValsetBehind = whoops.Errorf("compass runs with valset %d, which is behind Paloma's valset %d")
+
 	ErrCouldntFindBlockWithTime = whoops.String("couldn't find block")
+
+	ErrMessageExpired = whoops.Errorf("message expired: deadline %d is not after the latest block time %d")
+	ErrTxReverted     = whoops.Errorf("transaction %s reverted")
+	ErrTxWouldRevert  = whoops.String("transaction would revert")
+
+	ErrInvalidGasPricing    = whoops.Errorf("invalid gas pricing: %s")
+	ErrGasPriceAboveCeiling = whoops.Errorf("gas price %s is above the ceiling of %s")
+
+	ErrNoRPCEndpoints   = whoops.String("no RPC endpoints configured")
+	ErrInvalidRPCQuorum = whoops.Errorf("RPC quorum of %d is more than the %d configured endpoints")
+	ErrNoRPCQuorum      = whoops.Errorf("fewer than %d of %d RPC endpoints agree on the result")
+
+	ErrInvalidWebsocketURL   = whoops.Errorf("websocket url %s must start with ws:// or wss://")
+	ErrLogSubscriptionClosed = whoops.String("log subscription was closed")
+
+	ErrInvalidConfirmationTag = whoops.Errorf("invalid confirmation block tag: %s")
+
+	ErrInvalidCheckpoint = whoops.Errorf("invalid checkpoint stored under %s")
+
+	ErrInvalidEventIndex = whoops.Errorf("invalid event index height stored under %s")
+
+	ErrInvalidLastBatchNonce = whoops.Errorf("invalid last batch nonce: %v")
+
+	ErrInvalidTokenPrice = whoops.Errorf("invalid price of token %s: %s")
+	ErrNoTokenPrice      = whoops.Errorf("no price set for token %s")
 )
 
 var (
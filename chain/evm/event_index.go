@@ -0,0 +1,241 @@
+package evm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/VolumeFi/whoops"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxIndexWindowsPerSync is how many scan windows the event index catches up
+// on at most in one go, so that backfilling a long way doesn't hold up the
+// caller. Until the index caught up, the blocks which are not indexed yet
+// are read from the chain.
+const maxIndexWindowsPerSync = 10
+
+// indexedEventTopics are the compass events which are indexed.
+var indexedEventTopics = []common.Hash{
+	logicCallEventTopic,
+	valsetUpdatedEventTopic,
+	batchSendEventTopic,
+	sendToPalomaEventTopic,
+}
+
+// errIndexHit stops iterating over the index once a key was found.
+const errIndexHit = whoops.String("found in the event index")
+
+// EventStore keeps the checkpoints of the gravity event scans and the index
+// of the events emitted by compass.
+type EventStore interface {
+	CheckpointStore
+	Iterate(start, limit []byte, fn func(key, value []byte) error) error
+}
+
+// eventIndex keeps the events a compass contract emitted, so that compass
+// doesn't have to be asked for its logs over and over again. Only confirmed
+// blocks are indexed, which a reorg can't take back anymore.
+//
+// Next to the logs, which are keyed by their position in the chain, the logic
+// calls are keyed by their message ID and the batch sends by their token and
+// batch nonce, so that finding out whether one was relayed is a point read.
+type eventIndex struct {
+	// mu serializes catching up, which parallel logic calls would
+	// otherwise do at the same time.
+	mu sync.Mutex
+
+	store            EventStore
+	chainReferenceID string
+	contract         common.Address
+	// compassAbi unpacks the events which are keyed by their content. When
+	// it is nil, only the logs are indexed.
+	compassAbi *abi.ABI
+	// from is the block from which on the events are indexed.
+	from uint64
+}
+
+func newEventIndex(store EventStore, chainReferenceID string, contract common.Address, compassAbi *abi.ABI, from uint64) *eventIndex {
+	if store == nil {
+		return nil
+	}
+	return &eventIndex{store: store, chainReferenceID: chainReferenceID, contract: contract, compassAbi: compassAbi, from: from}
+}
+
+func (idx *eventIndex) prefix() string {
+	return fmt.Sprintf("events/%s/%s/", idx.chainReferenceID, idx.contract.Hex())
+}
+
+func (idx *eventIndex) syncedKey() []byte {
+	return []byte(idx.prefix() + "synced")
+}
+
+// logKey orders the logs of an event by their position in the chain.
+func (idx *eventIndex) logKey(topic common.Hash, blockNumber uint64, index uint) []byte {
+	key := []byte(idx.prefix() + topic.Hex() + "/")
+	key = binary.BigEndian.AppendUint64(key, blockNumber)
+	return binary.BigEndian.AppendUint32(key, uint32(index))
+}
+
+// logicCallKey keys the logic call with the given message ID.
+func (idx *eventIndex) logicCallKey(messageID uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(idx.prefix()+"logic-calls/"), messageID)
+}
+
+// batchKey orders the batch sends of a token by their batch nonce.
+func (idx *eventIndex) batchKey(tokenContract common.Address, batchNonce uint64) []byte {
+	key := []byte(idx.prefix() + "batches/" + tokenContract.Hex() + "/")
+	return binary.BigEndian.AppendUint64(key, batchNonce)
+}
+
+// query returns the query for all indexed events of the contract.
+func (idx *eventIndex) query() ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		Addresses: []common.Address{idx.contract},
+		Topics:    [][]common.Hash{indexedEventTopics},
+	}
+}
+
+// synced returns the block up to which the events are indexed. Nothing is
+// indexed yet if it is the block before the one the index starts at.
+func (idx *eventIndex) synced() (uint64, error) {
+	value, found, err := idx.store.Get(idx.syncedKey())
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		if idx.from == 0 {
+			return 0, nil
+		}
+		return idx.from - 1, nil
+	}
+	if len(value) != 8 {
+		return 0, ErrInvalidEventIndex.Format(idx.syncedKey())
+	}
+
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// add indexes the logs of the blocks up to the given one. Adding logs again
+// overwrites them, so a window which was only partly indexed can be scanned
+// again.
+func (idx *eventIndex) add(logs []ethtypes.Log, upTo uint64) error {
+	for _, l := range logs {
+		if len(l.Topics) == 0 || l.Removed {
+			continue
+		}
+		value, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		logKey := idx.logKey(l.Topics[0], l.BlockNumber, l.Index)
+		if err := idx.store.Put(logKey, value); err != nil {
+			return err
+		}
+		if key := idx.contentKey(l); key != nil {
+			if err := idx.store.Put(key, logKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	return idx.store.Put(idx.syncedKey(), binary.BigEndian.AppendUint64(nil, upTo))
+}
+
+// logs returns the indexed logs of the event between the given blocks, both
+// included, in the order they were emitted.
+func (idx *eventIndex) logs(topic common.Hash, from, to uint64) ([]ethtypes.Log, error) {
+	var res []ethtypes.Log
+	err := idx.store.Iterate(
+		idx.logKey(topic, from, 0),
+		idx.logKey(topic, to+1, 0),
+		func(_, value []byte) error {
+			var l ethtypes.Log
+			if err := json.Unmarshal(value, &l); err != nil {
+				return err
+			}
+			res = append(res, l)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// contentKey returns the key of the log by its content, or nil if the event
+// isn't keyed by it.
+func (idx *eventIndex) contentKey(l ethtypes.Log) []byte {
+	if idx.compassAbi == nil {
+		return nil
+	}
+
+	logger := log.WithFields(log.Fields{
+		"chain-reference-id": idx.chainReferenceID,
+		"tx-hash":            l.TxHash,
+	})
+	switch l.Topics[0] {
+	case logicCallEventTopic:
+		event, err := idx.compassAbi.Unpack("LogicCallEvent", l.Data)
+		if err != nil {
+			logger.WithError(err).Warn("couldn't unpack logic call event")
+			return nil
+		}
+		messageID, ok := event[2].(*big.Int)
+		if !ok || !messageID.IsUint64() {
+			return nil
+		}
+		return idx.logicCallKey(messageID.Uint64())
+	case batchSendEventTopic:
+		event, err := idx.compassAbi.Unpack("BatchSendEvent", l.Data)
+		if err != nil {
+			logger.WithError(err).Warn("couldn't unpack batch send event")
+			return nil
+		}
+		tokenContract, ok := event[0].(common.Address)
+		if !ok {
+			return nil
+		}
+		batchNonce, ok := event[1].(*big.Int)
+		if !ok || !batchNonce.IsUint64() {
+			return nil
+		}
+		return idx.batchKey(tokenContract, batchNonce.Uint64())
+	}
+
+	return nil
+}
+
+// hasLogicCall returns true if the logic call with the given message ID was
+// indexed.
+func (idx *eventIndex) hasLogicCall(messageID uint64) (bool, error) {
+	_, found, err := idx.store.Get(idx.logicCallKey(messageID))
+	return found, err
+}
+
+// hasBatch returns true if the batch of the token with the given nonce, or a
+// later one which makes it obsolete, was indexed.
+func (idx *eventIndex) hasBatch(tokenContract common.Address, batchNonce uint64) (bool, error) {
+	err := idx.store.Iterate(
+		idx.batchKey(tokenContract, batchNonce),
+		append(idx.batchKey(tokenContract, math.MaxUint64), 0),
+		func(_, _ []byte) error {
+			return errIndexHit
+		},
+	)
+	if goerrors.Is(err, errIndexHit) {
+		return true, nil
+	}
+
+	return false, err
+}
@@ -0,0 +1,176 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/VolumeFi/whoops"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/palomachain/pigeon/internal/store"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventIndex(t *testing.T) {
+	ctx := context.Background()
+	tokenContract := common.HexToAddress("0x0789")
+	compassAbi := whoops.Must(abi.JSON(strings.NewReader(`[
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"logic_contract_address","type":"address"},{"indexed":false,"name":"payload","type":"bytes"},{"indexed":false,"name":"message_id","type":"uint256"}],"name":"LogicCallEvent","type":"event"},
+		{"anonymous":false,"inputs":[{"indexed":false,"name":"token","type":"address"},{"indexed":false,"name":"batch_id","type":"uint256"}],"name":"BatchSendEvent","type":"event"}
+	]`)))
+
+	logicCall := func(block uint64, messageID int64) ethtypes.Log {
+		return ethtypes.Log{
+			Address:     smartContractAddr,
+			Topics:      []common.Hash{logicCallEventTopic},
+			Data:        whoops.Must(compassAbi.Events["LogicCallEvent"].Inputs.Pack(common.Address{}, []byte{}, big.NewInt(messageID))),
+			BlockNumber: block,
+			TxHash:      common.BigToHash(big.NewInt(int64(block))),
+		}
+	}
+	batchSend := func(block uint64, batchNonce int64) ethtypes.Log {
+		return ethtypes.Log{
+			Address:     smartContractAddr,
+			Topics:      []common.Hash{batchSendEventTopic},
+			Data:        whoops.Must(compassAbi.Events["BatchSendEvent"].Inputs.Pack(tokenContract, big.NewInt(batchNonce))),
+			BlockNumber: block,
+			TxHash:      common.BigToHash(big.NewInt(int64(block))),
+		}
+	}
+	window := func(idx *eventIndex, from, to int64) ethereum.FilterQuery {
+		query := idx.query()
+		query.FromBlock, query.ToBlock = big.NewInt(from), big.NewInt(to)
+		return query
+	}
+
+	setup := func(t *testing.T, confirmed int64) (*compass, *mockEvmClienter, *mockEthClientConn) {
+		st, err := store.Open(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { st.Close() })
+
+		evm := newMockEvmClienter(t)
+		conn := newMockEthClientConn(t)
+		evm.On("FindConfirmedBlockNumber", mock.Anything).Return(big.NewInt(confirmed), nil)
+		evm.On("GetQuorumEthClient").Return(conn).Maybe()
+
+		return &compass{
+			ChainReferenceID:  "eth-main",
+			smartContractAddr: smartContractAddr,
+			compassAbi:        &compassAbi,
+			evm:               evm,
+			eventIndex:        newEventIndex(st, "eth-main", smartContractAddr, &compassAbi, 1000),
+		}, evm, conn
+	}
+
+	t.Run("the index backfills from the reference block in bounded steps", func(t *testing.T) {
+		comp, _, conn := setup(t, 100000)
+		for i := int64(0); i < maxIndexWindowsPerSync; i++ {
+			from := 1000 + i*maxGravityScanWindow
+			conn.On("FilterLogs", mock.Anything, window(comp.eventIndex, from, from+maxGravityScanWindow-1)).Return(nil, nil).Once()
+		}
+
+		synced, err := comp.indexEvents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1000+maxIndexWindowsPerSync*maxGravityScanWindow-1), synced)
+	})
+
+	t.Run("executed logic calls are found in the index", func(t *testing.T) {
+		comp, evm, conn := setup(t, 2000)
+		conn.On("FilterLogs", mock.Anything, window(comp.eventIndex, 1000, 2000)).Return(
+			[]ethtypes.Log{logicCall(1500, 7), batchSend(1600, 3)}, nil,
+		).Once()
+
+		executed, err := comp.isArbitraryCallAlreadyExecuted(ctx, 7)
+		require.NoError(t, err)
+		require.True(t, executed)
+
+		// only the blocks which are not confirmed yet are asked for
+		evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(2010), nil)
+		evm.On("FilterLogs", mock.Anything, mock.MatchedBy(func(q ethereum.FilterQuery) bool {
+			return q.FromBlock.Int64() == 2001 && q.Topics[0][0] == logicCallEventTopic
+		}), (*big.Int)(nil), mock.Anything).Return(false, nil).Once()
+
+		executed, err = comp.isArbitraryCallAlreadyExecuted(ctx, 8)
+		require.NoError(t, err)
+		require.False(t, executed)
+	})
+
+	t.Run("relayed batches are found in the index", func(t *testing.T) {
+		comp, evm, conn := setup(t, 2000)
+		conn.On("FilterLogs", mock.Anything, window(comp.eventIndex, 1000, 2000)).Return(
+			[]ethtypes.Log{logicCall(1500, 7), batchSend(1600, 3)}, nil,
+		).Once()
+
+		relayed, err := comp.gravityIsBatchAlreadyRelayed(ctx, tokenContract, 3)
+		require.NoError(t, err)
+		require.True(t, relayed)
+
+		// a later batch of the token makes the earlier ones obsolete
+		relayed, err = comp.gravityIsBatchAlreadyRelayed(ctx, tokenContract, 2)
+		require.NoError(t, err)
+		require.True(t, relayed)
+
+		evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(2000), nil)
+		relayed, err = comp.gravityIsBatchAlreadyRelayed(ctx, tokenContract, 4)
+		require.NoError(t, err)
+		require.False(t, relayed)
+	})
+
+	t.Run("gravity scans read the indexed blocks from the index", func(t *testing.T) {
+		comp, _, conn := setup(t, 2000)
+		conn.On("FilterLogs", mock.Anything, window(comp.eventIndex, 1000, 2000)).Return(
+			[]ethtypes.Log{batchSend(1200, 1), logicCall(1500, 7), batchSend(1600, 3)}, nil,
+		).Once()
+
+		query := comp.gravityEventsQuery()
+		query.FromBlock, query.ToBlock = big.NewInt(1300), big.NewInt(2000)
+		logs, err := comp.gravityLogs(ctx, query)
+		require.NoError(t, err)
+		require.Equal(t, []ethtypes.Log{batchSend(1600, 3)}, logs)
+	})
+
+	t.Run("lookups are point reads instead of scans through the logs", func(t *testing.T) {
+		comp, evm, conn := setup(t, 2000)
+		st := &iterationCountingStore{EventStore: comp.eventIndex.store}
+		comp.eventIndex.store = st
+		conn.On("FilterLogs", mock.Anything, window(comp.eventIndex, 1000, 2000)).Return(
+			[]ethtypes.Log{logicCall(1500, 7), batchSend(1600, 3)}, nil,
+		).Once()
+		evm.On("FindCurrentBlockNumber", mock.Anything).Return(big.NewInt(2000), nil)
+
+		executed, err := comp.isArbitraryCallAlreadyExecuted(ctx, 7)
+		require.NoError(t, err)
+		require.True(t, executed)
+		executed, err = comp.isArbitraryCallAlreadyExecuted(ctx, 8)
+		require.NoError(t, err)
+		require.False(t, executed)
+		require.Zero(t, st.iterations)
+
+		relayed, err := comp.gravityIsBatchAlreadyRelayed(ctx, common.HexToAddress("0x0abc"), 1)
+		require.NoError(t, err)
+		require.False(t, relayed)
+		require.Equal(t, 1, st.iterations)
+		require.Empty(t, st.visited)
+	})
+}
+
+// iterationCountingStore counts the iterations over the store, and the keys
+// they visited.
+type iterationCountingStore struct {
+	EventStore
+	iterations int
+	visited    [][]byte
+}
+
+func (s *iterationCountingStore) Iterate(start, limit []byte, fn func(key, value []byte) error) error {
+	s.iterations++
+	return s.EventStore.Iterate(start, limit, func(key, value []byte) error {
+		s.visited = append(s.visited, key)
+		return fn(key, value)
+	})
+}
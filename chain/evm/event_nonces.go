@@ -0,0 +1,134 @@
+package evm
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// gravityEventTopics are the gravity events compass emits, which are claimed
+// on Paloma.
+var gravityEventTopics = []common.Hash{
+	batchSendEventTopic,
+	sendToPalomaEventTopic,
+}
+
+type eventClaim struct {
+	nonce    uint64
+	contract common.Address
+	topic    common.Hash
+	log      ethtypes.Log
+}
+
+// eventNonces hands out the nonces of the gravity events emitted by the
+// compass contracts of a chain. Compass doesn't emit event nonces, so they
+// are assigned in the order the events were emitted in, by block and log
+// index, on from the last nonce Paloma acknowledged for the orchestrator.
+// Batch send and send to Paloma events share the same sequence, and so do
+// the compass contracts of a chain while one hands over to the next, which
+// is why their events are scanned for together.
+//
+// Every block is handed nonces once, so an event keeps its nonce until
+// Paloma acknowledged it, and a claim which failed is retried with the same
+// nonce. After a restart, the sequence resumes from the checkpoint of the
+// last acknowledged event, so that the events after it get the same nonces
+// again.
+type eventNonces struct {
+	mu sync.Mutex
+	// scanned is the block up to which the events have been handed nonces.
+	scanned uint64
+	// skip is the index of the first log in the block after the scanned one
+	// which wasn't acknowledged when the scan resumed from a checkpoint.
+	skip uint
+	// last is the nonce which was handed out last. Until the sequence
+	// started, from a checkpoint or from Paloma, it isn't known.
+	last    uint64
+	started bool
+	// claims are the events which Paloma didn't acknowledge yet, ordered
+	// by nonce.
+	claims []eventClaim
+}
+
+// resume continues the sequence from the checkpoint.
+func (n *eventNonces) resume(cp eventCheckpoint) {
+	n.scanned, n.skip, n.last, n.started = cp.block-1, cp.index, cp.nonce, true
+}
+
+// checkpoint returns how far the events have been acknowledged.
+func (n *eventNonces) checkpoint() eventCheckpoint {
+	if len(n.claims) > 0 {
+		first := n.claims[0]
+		return eventCheckpoint{block: first.log.BlockNumber, index: first.log.Index, nonce: first.nonce - 1}
+	}
+	return eventCheckpoint{block: n.scanned + 1, nonce: n.last}
+}
+
+// assign hands out nonces to the given events which were emitted after the
+// scanned block, up to the given one. Claims which Paloma acknowledged are
+// dropped. Without a checkpoint, the sequence starts from the last nonce
+// Paloma acknowledged.
+func (n *eventNonces) assign(lastEventNonce uint64, logs []ethtypes.Log, upTo uint64) {
+	i := 0
+	for i < len(n.claims) && n.claims[i].nonce <= lastEventNonce {
+		i++
+	}
+	n.claims = n.claims[i:]
+	if !n.started {
+		n.last, n.started = lastEventNonce, true
+	}
+
+	if upTo <= n.scanned {
+		return
+	}
+
+	var fresh []ethtypes.Log
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) == 0 || l.BlockNumber <= n.scanned || l.BlockNumber > upTo {
+			continue
+		}
+		if l.BlockNumber == n.scanned+1 && l.Index < n.skip {
+			continue
+		}
+		fresh = append(fresh, l)
+	}
+	sort.Slice(fresh, func(i, j int) bool {
+		if fresh[i].BlockNumber != fresh[j].BlockNumber {
+			return fresh[i].BlockNumber < fresh[j].BlockNumber
+		}
+		return fresh[i].Index < fresh[j].Index
+	})
+
+	for i, l := range fresh {
+		// the log index is unique within the block, whichever contract
+		// emitted the log, so a log seen twice is only claimed once
+		if i > 0 && l.BlockNumber == fresh[i-1].BlockNumber && l.Index == fresh[i-1].Index {
+			continue
+		}
+		n.last++
+		if n.last <= lastEventNonce {
+			// the claim was acknowledged before the scan resumed
+			continue
+		}
+		n.claims = append(n.claims, eventClaim{nonce: n.last, contract: l.Address, topic: l.Topics[0], log: l})
+	}
+	n.scanned, n.skip = upTo, 0
+}
+
+// pending returns the claims of the given event which are waiting to be
+// acknowledged, ordered by nonce.
+func (n *eventNonces) pending(topic common.Hash) []eventClaim {
+	var res []eventClaim
+	for _, c := range n.claims {
+		if c.topic == topic {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// waiting returns true if claims are still waiting to be acknowledged.
+func (n *eventNonces) waiting() bool {
+	return len(n.claims) > 0
+}
@@ -0,0 +1,91 @@
+package evm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventNonces(t *testing.T) {
+	contract := common.HexToAddress("0x0123")
+	previous := common.HexToAddress("0x0456")
+	event := func(addr common.Address, topic common.Hash, block uint64, index uint) ethtypes.Log {
+		return ethtypes.Log{Address: addr, Topics: []common.Hash{topic}, BlockNumber: block, Index: index}
+	}
+	batch := func(block uint64, index uint) ethtypes.Log {
+		return event(contract, batchSendEventTopic, block, index)
+	}
+	send := func(block uint64, index uint) ethtypes.Log {
+		return event(contract, sendToPalomaEventTopic, block, index)
+	}
+	nonces := func(claims []eventClaim) []uint64 {
+		res := make([]uint64, len(claims))
+		for i, c := range claims {
+			res[i] = c.nonce
+		}
+		return res
+	}
+
+	n := eventNonces{scanned: 100}
+
+	t.Run("events get increasing nonces in the order they were emitted in", func(t *testing.T) {
+		n.assign(10, []ethtypes.Log{send(102, 0), batch(101, 3), batch(102, 1), batch(101, 1)}, 110)
+		require.Equal(t, []uint64{11, 12, 14}, nonces(n.pending(batchSendEventTopic)))
+		require.Equal(t, []uint64{13}, nonces(n.pending(sendToPalomaEventTopic)))
+		require.Equal(t, batch(101, 3), n.pending(batchSendEventTopic)[1].log)
+		require.Equal(t, uint64(110), n.scanned)
+	})
+
+	t.Run("unacknowledged claims are retried with their nonces", func(t *testing.T) {
+		require.True(t, n.waiting())
+
+		n.assign(11, []ethtypes.Log{batch(111, 0)}, 120)
+		require.Equal(t, []uint64{12, 14, 15}, nonces(n.pending(batchSendEventTopic)))
+	})
+
+	t.Run("blocks which were scanned already aren't handed nonces again", func(t *testing.T) {
+		n.assign(11, []ethtypes.Log{batch(102, 1), batch(111, 0)}, 120)
+		require.Equal(t, []uint64{12, 14, 15}, nonces(n.pending(batchSendEventTopic)))
+	})
+
+	t.Run("acknowledged claims are dropped", func(t *testing.T) {
+		n.assign(15, nil, 120)
+		require.Empty(t, n.pending(batchSendEventTopic))
+		require.Empty(t, n.pending(sendToPalomaEventTopic))
+		require.False(t, n.waiting())
+	})
+
+	t.Run("compass contracts share the sequence", func(t *testing.T) {
+		n.assign(15, []ethtypes.Log{
+			batch(122, 0),
+			event(previous, batchSendEventTopic, 121, 4),
+			event(previous, sendToPalomaEventTopic, 122, 1),
+		}, 130)
+
+		claims := n.pending(batchSendEventTopic)
+		require.Equal(t, []uint64{16, 17}, nonces(claims))
+		require.Equal(t, previous, claims[0].contract)
+		require.Equal(t, contract, claims[1].contract)
+		require.Equal(t, []uint64{18}, nonces(n.pending(sendToPalomaEventTopic)))
+	})
+
+	t.Run("the checkpoint is the first event which wasn't acknowledged", func(t *testing.T) {
+		require.Equal(t, eventCheckpoint{block: 121, index: 4, nonce: 15}, n.checkpoint())
+
+		n.assign(18, nil, 130)
+		require.Equal(t, eventCheckpoint{block: 131, nonce: 18}, n.checkpoint())
+	})
+
+	t.Run("a resumed sequence hands out the nonces it handed out before", func(t *testing.T) {
+		var resumed eventNonces
+		resumed.resume(eventCheckpoint{block: 101, index: 3, nonce: 11})
+
+		// Paloma acknowledged more claims than the checkpoint knows of
+		resumed.assign(13, []ethtypes.Log{send(102, 0), batch(101, 3), batch(102, 1), batch(101, 1)}, 110)
+		require.Equal(t, []uint64{14}, nonces(resumed.pending(batchSendEventTopic)))
+		require.Equal(t, batch(102, 1), resumed.pending(batchSendEventTopic)[0].log)
+		require.Empty(t, resumed.pending(sendToPalomaEventTopic))
+	})
+}
@@ -3,6 +3,7 @@ package evm
 import (
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -15,11 +16,21 @@ import (
 
 type Factory struct {
 	palomaClienter PalomaClienter
+	nonces         nonceManagers
+	txs            txManagers
+	store          EventStore
+	compasses      compassVersions
 }
 
-func NewFactory(pc PalomaClienter) *Factory {
+// NewFactory returns a factory of EVM processors. The checkpoints of the
+// gravity event scans and the index of the compass events are kept in the
+// given store, as are the compass contracts the processors hand over between.
+// Without a store, nothing is indexed and the rest is only kept in memory.
+func NewFactory(pc PalomaClienter, store EventStore) *Factory {
 	return &Factory{
 		palomaClienter: pc,
+		store:          store,
+		compasses:      compassVersions{store: store},
 	}
 }
 
@@ -53,6 +64,9 @@ func (f *Factory) Build(
 	if err := client.init(); err != nil {
 		return Processor{}, err
 	}
+	client.nonces = f.nonces.get(chainID, client.addr)
+	client.txs.trackedTxs = f.txs.get(chainID, client.addr)
+	client.txs.nonces = client.nonces
 
 	if libchain.IsArbitrum(chainID) {
 		if err := client.injectArbClient(); err != nil {
@@ -60,17 +74,43 @@ func (f *Factory) Build(
 		}
 	}
 
+	profitability, err := newBatchProfitability(cfg.GravityProfitability)
+	if err != nil {
+		return Processor{}, errors.Unrecoverable(err)
+	}
+
+	comp := &compass{
+		CompassID:           smartContractID,
+		ChainReferenceID:    chainReferenceID,
+		smartContractAddr:   common.HexToAddress(smartContractAddress),
+		chainID:             chainID,
+		compassAbi:          smartContractABI,
+		paloma:              f.palomaClienter,
+		evm:                 client,
+		startingBlockHeight: blockHeight,
+		parallelLogicCalls:  cfg.ParallelLogicCalls,
+		checkpoints:         newCheckpoints(f.store, chainReferenceID),
+		eventIndex:          newEventIndex(f.store, chainReferenceID, common.HexToAddress(smartContractAddress), smartContractABI, uint64(blockHeight)),
+		eventNonces:         &eventNonces{},
+		profitability:       profitability,
+	}
+
+	if len(cfg.WebsocketURL) > 0 {
+		if !strings.HasPrefix(cfg.WebsocketURL, "ws://") && !strings.HasPrefix(cfg.WebsocketURL, "wss://") {
+			return Processor{}, errors.Unrecoverable(ErrInvalidWebsocketURL.Format(cfg.WebsocketURL))
+		}
+		dial := dialLogSubscriber(cfg.WebsocketURL)
+		comp.gravityWatcher = newLogWatcher(comp.gravityEventsQuery(), dial, client.conn)
+	}
+
+	handoverWindow := cfg.CompassHandoverWindow
+	if handoverWindow <= 0 {
+		handoverWindow = defaultCompassHandoverWindow
+	}
+
 	return Processor{
-		compass: &compass{
-			CompassID:           smartContractID,
-			ChainReferenceID:    chainReferenceID,
-			smartContractAddr:   common.HexToAddress(smartContractAddress),
-			chainID:             chainID,
-			compassAbi:          smartContractABI,
-			paloma:              f.palomaClienter,
-			evm:                 client,
-			startingBlockHeight: blockHeight,
-		},
+		compass:           comp,
+		previousCompass:   f.compasses.activate(comp, handoverWindow, time.Now()),
 		evmClient:         client,
 		chainType:         "evm",
 		chainReferenceID:  chainReferenceID,
@@ -0,0 +1,264 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/palomachain/pigeon/config"
+)
+
+const (
+	gasStrategySuggested  = "suggested"
+	gasStrategyFeeHistory = "fee-history"
+	gasStrategyFixed      = "fixed"
+
+	dynamicFeeTxType = 2
+
+	defaultFeeHistoryBlocks     = 10
+	defaultFeeHistoryPercentile = 50
+)
+
+// gasPricingBackend is the part of the RPC client gas strategies need.
+type gasPricingBackend interface {
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// gasPrice is the price a transaction is sent with. Either gasPrice is set
+// for a legacy transaction, or gasFeeCap and gasTipCap for an EIP-1559 one.
+type gasPrice struct {
+	gasPrice  *big.Int
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+}
+
+func (p gasPrice) isDynamic() bool {
+	return p.gasFeeCap != nil
+}
+
+// max returns the most the transaction can pay per unit of gas.
+func (p gasPrice) max() *big.Int {
+	if p.isDynamic() {
+		return p.gasFeeCap
+	}
+	return p.gasPrice
+}
+
+// gasStrategy decides the price of the transactions sent to a chain.
+type gasStrategy interface {
+	price(ctx context.Context, backend gasPricingBackend) (gasPrice, error)
+}
+
+func newGasStrategy(cfg config.EVM) (gasStrategy, error) {
+	pricing := cfg.GasPricing
+	dynamic := cfg.TxType == dynamicFeeTxType
+
+	var s gasStrategy
+	switch pricing.Strategy {
+	case "", gasStrategySuggested:
+		s = suggestedGasStrategy{dynamic: dynamic, gasAdjustment: cfg.GasAdjustment}
+	case gasStrategyFeeHistory:
+		fh := feeHistoryGasStrategy{
+			dynamic:       dynamic,
+			gasAdjustment: cfg.GasAdjustment,
+			blocks:        pricing.FeeHistoryBlocks,
+			percentile:    pricing.FeeHistoryPercentile,
+		}
+		if fh.blocks <= 0 {
+			fh.blocks = defaultFeeHistoryBlocks
+		}
+		if fh.percentile <= 0 {
+			fh.percentile = defaultFeeHistoryPercentile
+		}
+		if fh.percentile > 100 {
+			return nil, ErrInvalidGasPricing.Format("fee history percentile can't be above 100")
+		}
+		s = fh
+	case gasStrategyFixed:
+		fixed := fixedGasStrategy{
+			dynamic:   dynamic,
+			gasPrice:  new(big.Int).SetUint64(pricing.GasPrice),
+			gasFeeCap: new(big.Int).SetUint64(pricing.MaxFeePerGas),
+			gasTipCap: new(big.Int).SetUint64(pricing.MaxPriorityFeePerGas),
+		}
+		if dynamic && (pricing.MaxFeePerGas == 0 || pricing.MaxFeePerGas < pricing.MaxPriorityFeePerGas) {
+			return nil, ErrInvalidGasPricing.Format("max fee per gas must be set and can't be below the max priority fee per gas")
+		}
+		if pricing.GasPrice == 0 {
+			// used on chains without a fee market
+			fixed.gasPrice = fixed.gasFeeCap
+		}
+		if fixed.gasPrice.Sign() == 0 {
+			return nil, ErrInvalidGasPricing.Format("gas price must be set")
+		}
+		s = fixed
+	default:
+		return nil, ErrInvalidGasPricing.Format("unknown strategy " + pricing.Strategy)
+	}
+
+	if pricing.MaxGasPrice > 0 {
+		s = gasCeiling{gasStrategy: s, ceiling: new(big.Int).SetUint64(pricing.MaxGasPrice)}
+	}
+
+	return s, nil
+}
+
+// suggestedGasStrategy prices transactions with the gas price suggested by
+// the node. EIP-1559 transactions pay double the suggested gas price plus
+// the tip at most, which leaves room for the base fee to rise.
+type suggestedGasStrategy struct {
+	dynamic       bool
+	gasAdjustment float64
+}
+
+func (s suggestedGasStrategy) price(ctx context.Context, backend gasPricingBackend) (gasPrice, error) {
+	suggested, err := backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return gasPrice{}, err
+	}
+
+	if s.dynamic {
+		ok, err := hasFeeMarket(ctx, backend)
+		if err != nil {
+			return gasPrice{}, err
+		}
+
+		if ok {
+			tipCap, err := backend.SuggestGasTipCap(ctx)
+			if err != nil {
+				return gasPrice{}, err
+			}
+
+			feeCap := new(big.Int).Mul(suggested, big.NewInt(2))
+			return gasPrice{gasFeeCap: feeCap.Add(feeCap, tipCap), gasTipCap: tipCap}, nil
+		}
+	}
+
+	return gasPrice{gasPrice: adjustGasPrice(suggested, s.gasAdjustment)}, nil
+}
+
+// feeHistoryGasStrategy prices transactions based on the priority fees paid
+// in recent blocks. The tip is the median of the given reward percentile
+// across the blocks, and the fee cap leaves room for the base fee to double.
+type feeHistoryGasStrategy struct {
+	dynamic       bool
+	gasAdjustment float64
+	blocks        int
+	percentile    float64
+}
+
+func (s feeHistoryGasStrategy) price(ctx context.Context, backend gasPricingBackend) (gasPrice, error) {
+	if s.dynamic {
+		history, err := backend.FeeHistory(ctx, uint64(s.blocks), nil, []float64{s.percentile})
+		if err == nil && feeHistoryBaseFee(history).Sign() > 0 {
+			tipCap := medianReward(history)
+			feeCap := new(big.Int).Mul(feeHistoryBaseFee(history), big.NewInt(2))
+			return gasPrice{gasFeeCap: feeCap.Add(feeCap, tipCap), gasTipCap: tipCap}, nil
+		}
+	}
+
+	// without a fee market, there are no priority fees to look at
+	suggested, err := backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return gasPrice{}, err
+	}
+
+	return gasPrice{gasPrice: adjustGasPrice(suggested, s.gasAdjustment)}, nil
+}
+
+// fixedGasStrategy always uses the configured prices.
+type fixedGasStrategy struct {
+	dynamic   bool
+	gasPrice  *big.Int
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+}
+
+func (s fixedGasStrategy) price(ctx context.Context, backend gasPricingBackend) (gasPrice, error) {
+	if s.dynamic {
+		ok, err := hasFeeMarket(ctx, backend)
+		if err != nil {
+			return gasPrice{}, err
+		}
+
+		if ok {
+			return gasPrice{gasFeeCap: s.gasFeeCap, gasTipCap: s.gasTipCap}, nil
+		}
+	}
+
+	return gasPrice{gasPrice: s.gasPrice}, nil
+}
+
+// gasCeiling refuses to price transactions above the ceiling.
+type gasCeiling struct {
+	gasStrategy
+	ceiling *big.Int
+}
+
+func (s gasCeiling) price(ctx context.Context, backend gasPricingBackend) (gasPrice, error) {
+	p, err := s.gasStrategy.price(ctx, backend)
+	if err != nil {
+		return gasPrice{}, err
+	}
+
+	if p.max().Cmp(s.ceiling) > 0 {
+		return gasPrice{}, ErrGasPriceAboveCeiling.Format(p.max(), s.ceiling)
+	}
+
+	return p, nil
+}
+
+// hasFeeMarket returns whether the chain supports EIP-1559. Chains without
+// a fee market either don't support eth_feeHistory or report no base fee.
+func hasFeeMarket(ctx context.Context, backend gasPricingBackend) (bool, error) {
+	history, err := backend.FeeHistory(ctx, 1, nil, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, nil
+	}
+
+	return feeHistoryBaseFee(history).Sign() > 0, nil
+}
+
+// feeHistoryBaseFee returns the base fee of the next block.
+func feeHistoryBaseFee(history *ethereum.FeeHistory) *big.Int {
+	if history == nil || len(history.BaseFee) == 0 || history.BaseFee[len(history.BaseFee)-1] == nil {
+		return new(big.Int)
+	}
+	return history.BaseFee[len(history.BaseFee)-1]
+}
+
+func medianReward(history *ethereum.FeeHistory) *big.Int {
+	rewards := make([]*big.Int, 0, len(history.Reward))
+	for _, r := range history.Reward {
+		if len(r) > 0 && r[0] != nil {
+			rewards = append(rewards, r[0])
+		}
+	}
+
+	if len(rewards) == 0 {
+		return new(big.Int)
+	}
+
+	sort.Slice(rewards, func(i, j int) bool {
+		return rewards[i].Cmp(rewards[j]) < 0
+	})
+
+	return new(big.Int).Set(rewards[len(rewards)/2])
+}
+
+func adjustGasPrice(gasPrice *big.Int, gasAdjustment float64) *big.Int {
+	if gasAdjustment <= 1.0 {
+		return gasPrice
+	}
+
+	gasAdj := big.NewFloat(gasAdjustment)
+	gasAdj = gasAdj.Mul(gasAdj, new(big.Float).SetInt(gasPrice))
+	adjusted, _ := gasAdj.Int(big.NewInt(0))
+	return adjusted
+}
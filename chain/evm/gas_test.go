@@ -0,0 +1,112 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasStrategies(t *testing.T) {
+	ctx := context.Background()
+	feeMarket := &ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(90), big.NewInt(100)}}
+	newStrategy := func(t *testing.T, txType uint8, gasAdjustment float64, pricing config.GasPricing) gasStrategy {
+		s, err := newGasStrategy(config.EVM{
+			EVMSpecificClientConfig: config.EVMSpecificClientConfig{TxType: txType, GasPricing: pricing},
+			ChainClientConfig:       config.ChainClientConfig{GasAdjustment: gasAdjustment},
+		})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("suggested legacy prices are adjusted", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(100), nil)
+
+		price, err := newStrategy(t, 0, 1.5, config.GasPricing{}).price(ctx, backend)
+		require.NoError(t, err)
+		require.False(t, price.isDynamic())
+		require.Equal(t, big.NewInt(150), price.gasPrice)
+	})
+
+	t.Run("suggested eip-1559 prices leave room for the base fee", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(100), nil)
+		backend.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(feeMarket, nil)
+		backend.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(4), nil)
+
+		price, err := newStrategy(t, 2, 1.5, config.GasPricing{}).price(ctx, backend)
+		require.NoError(t, err)
+		require.True(t, price.isDynamic())
+		require.Equal(t, big.NewInt(204), price.gasFeeCap)
+		require.Equal(t, big.NewInt(4), price.gasTipCap)
+	})
+
+	t.Run("chains without a fee market fall back to legacy prices", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(100), nil)
+		backend.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(nil, errors.New("method not found"))
+
+		price, err := newStrategy(t, 2, 1.5, config.GasPricing{}).price(ctx, backend)
+		require.NoError(t, err)
+		require.False(t, price.isDynamic())
+		require.Equal(t, big.NewInt(150), price.gasPrice)
+	})
+
+	t.Run("fee history tips are the median of the percentile", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("FeeHistory", mock.Anything, uint64(3), (*big.Int)(nil), []float64{60}).Return(&ethereum.FeeHistory{
+			BaseFee: []*big.Int{big.NewInt(80), big.NewInt(90), big.NewInt(95), big.NewInt(100)},
+			Reward:  [][]*big.Int{{big.NewInt(7)}, {big.NewInt(2)}, {big.NewInt(5)}},
+		}, nil)
+
+		price, err := newStrategy(t, 2, 0, config.GasPricing{
+			Strategy:             gasStrategyFeeHistory,
+			FeeHistoryBlocks:     3,
+			FeeHistoryPercentile: 60,
+		}).price(ctx, backend)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(205), price.gasFeeCap)
+		require.Equal(t, big.NewInt(5), price.gasTipCap)
+	})
+
+	t.Run("fixed prices are used as they are", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(feeMarket, nil)
+
+		price, err := newStrategy(t, 2, 0, config.GasPricing{
+			Strategy:             gasStrategyFixed,
+			MaxFeePerGas:         300,
+			MaxPriorityFeePerGas: 3,
+		}).price(ctx, backend)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(300), price.gasFeeCap)
+		require.Equal(t, big.NewInt(3), price.gasTipCap)
+	})
+
+	t.Run("prices above the ceiling are refused", func(t *testing.T) {
+		backend := newMockEthClienter(t)
+		backend.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(100), nil)
+
+		_, err := newStrategy(t, 0, 0, config.GasPricing{MaxGasPrice: 99}).price(ctx, backend)
+		require.ErrorIs(t, err, ErrGasPriceAboveCeiling)
+	})
+
+	t.Run("invalid configurations are rejected", func(t *testing.T) {
+		for _, pricing := range []config.GasPricing{
+			{Strategy: "cheapest"},
+			{Strategy: gasStrategyFixed},
+			{Strategy: gasStrategyFeeHistory, FeeHistoryPercentile: 101},
+		} {
+			_, err := newGasStrategy(config.EVM{
+				EVMSpecificClientConfig: config.EVMSpecificClientConfig{TxType: 2, GasPricing: pricing},
+			})
+			require.ErrorIs(t, err, ErrInvalidGasPricing)
+		}
+	})
+}
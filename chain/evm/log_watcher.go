@@ -0,0 +1,278 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLogWatcherRetryDelay  = 30 * time.Second
+	defaultLogWatcherIdleTimeout = time.Minute
+	defaultLogWatcherSettle      = time.Second
+
+	logWatcherBufferSize = 128
+)
+
+// logSubscriber is a websocket connection to the chain.
+type logSubscriber interface {
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- ethtypes.Log) (ethereum.Subscription, error)
+	Close()
+}
+
+func dialLogSubscriber(url string) func(context.Context) (logSubscriber, error) {
+	return func(ctx context.Context) (logSubscriber, error) {
+		return ethclient.DialContext(ctx, url)
+	}
+}
+
+// logWatcher receives the logs matching a query through a websocket
+// subscription, so that they don't have to be polled for. When it starts,
+// it backfills the logs from the given block up to the head, so that no log
+// falls in the gap between the last poll and the subscription.
+//
+// The watcher stops whenever the subscription fails, and the caller is
+// expected to poll until the watcher is started again. It also stops once
+// nobody has taken logs from it for a while, so watchers of processors which
+// have been replaced don't linger.
+type logWatcher struct {
+	mu sync.Mutex
+
+	query ethereum.FilterQuery
+	dial  func(context.Context) (logSubscriber, error)
+	conn  ethClientToFilterLogs
+	now   func() time.Time
+
+	retryDelay  time.Duration
+	idleTimeout time.Duration
+	// settle is how long the logs of the latest block are held back, so
+	// that they are not taken while the rest of the block is still coming
+	// in.
+	settle time.Duration
+
+	running bool
+	live    bool
+	logs    []ethtypes.Log
+	// synced is the block up to which the watcher has all logs.
+	synced    uint64
+	lastLogAt time.Time
+	lastRead  time.Time
+	retryAt   time.Time
+}
+
+func newLogWatcher(query ethereum.FilterQuery, dial func(context.Context) (logSubscriber, error), conn ethClientToFilterLogs) *logWatcher {
+	return &logWatcher{
+		query:       query,
+		dial:        dial,
+		conn:        conn,
+		now:         time.Now,
+		retryDelay:  defaultLogWatcherRetryDelay,
+		idleTimeout: defaultLogWatcherIdleTimeout,
+		settle:      defaultLogWatcherSettle,
+	}
+}
+
+// pending returns the logs received so far up to the given block, oldest
+// first, together with the block up to which they are complete. It returns
+// false if the watcher isn't live, in which case the logs need to be polled
+// for. The logs stay pending until they are acknowledged.
+func (w *logWatcher) pending(maxBlock uint64) ([]ethtypes.Log, uint64, bool) {
+	if w == nil {
+		return nil, 0, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastRead = w.now()
+	if !w.live {
+		return nil, 0, false
+	}
+
+	n := 0
+	for n < len(w.logs) && w.logs[n].BlockNumber <= maxBlock {
+		n++
+	}
+	if n > 0 && n == len(w.logs) && w.now().Sub(w.lastLogAt) < w.settle {
+		latest := w.logs[n-1].BlockNumber
+		for n > 0 && w.logs[n-1].BlockNumber == latest {
+			n--
+		}
+	}
+
+	res := make([]ethtypes.Log, n)
+	copy(res, w.logs[:n])
+	upTo := w.synced
+	if n > 0 && res[n-1].BlockNumber > upTo {
+		upTo = res[n-1].BlockNumber
+	}
+	if upTo > maxBlock {
+		upTo = maxBlock
+	}
+
+	return res, upTo, true
+}
+
+// ack drops the pending logs up to the given block, once they have been
+// handled.
+func (w *logWatcher) ack(upTo uint64) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := 0
+	for n < len(w.logs) && w.logs[n].BlockNumber <= upTo {
+		n++
+	}
+	w.logs = w.logs[n:]
+	if upTo > w.synced {
+		w.synced = upTo
+	}
+}
+
+// start starts watching for logs from the given block on, unless the
+// watcher is running already or failed too recently.
+func (w *logWatcher) start(from uint64) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	if w.running || now.Before(w.retryAt) {
+		return
+	}
+	w.running = true
+	w.lastRead = now
+
+	go w.run(from)
+}
+
+func (w *logWatcher) run(from uint64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := log.WithFields(log.Fields{
+		"addresses": w.query.Addresses,
+		"topics":    w.query.Topics,
+		"from":      from,
+	})
+
+	err := w.watch(ctx, from)
+
+	w.mu.Lock()
+	w.running, w.live, w.logs = false, false, nil
+	if err != nil {
+		w.retryAt = w.now().Add(w.retryDelay)
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		logger.WithError(err).Warn("log subscription failed, falling back to polling")
+		return
+	}
+	logger.Info("log subscription is no longer used, stopping it")
+}
+
+func (w *logWatcher) watch(ctx context.Context, from uint64) error {
+	client, err := w.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// subscribing before backfilling leaves no gap in between
+	ch := make(chan ethtypes.Log, logWatcherBufferSize)
+	sub, err := client.SubscribeFilterLogs(ctx, w.query, ch)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	head, err := w.conn.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var backfilled []ethtypes.Log
+	fq := w.query
+	fq.FromBlock, fq.ToBlock = new(big.Int).SetUint64(from), head.Number
+	if head.Number.Uint64() >= from {
+		_, err = filterLogs(ctx, w.conn, fq, head.Number, false, func(logs []ethtypes.Log) bool {
+			backfilled = append(backfilled, logs...)
+			return false
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sort.Slice(backfilled, func(i, j int) bool {
+		if backfilled[i].BlockNumber != backfilled[j].BlockNumber {
+			return backfilled[i].BlockNumber < backfilled[j].BlockNumber
+		}
+		return backfilled[i].Index < backfilled[j].Index
+	})
+
+	backfilledTo := head.Number.Uint64()
+	if from > 0 && backfilledTo < from-1 {
+		// the node lags behind the one which was polled
+		backfilledTo = from - 1
+	}
+	w.mu.Lock()
+	w.logs, w.live, w.synced = backfilled, true, backfilledTo
+	w.mu.Unlock()
+
+	idle := time.NewTicker(w.idleTimeout / 2)
+	defer idle.Stop()
+
+	for {
+		select {
+		case err := <-sub.Err():
+			if err == nil {
+				err = ErrLogSubscriptionClosed
+			}
+			return err
+		case l := <-ch:
+			w.mu.Lock()
+			switch {
+			case l.Removed:
+				// the block of the log was orphaned by a reorg
+				w.logs = removeLog(w.logs, l)
+			case l.BlockNumber > backfilledTo:
+				w.logs = append(w.logs, l)
+				w.lastLogAt = w.now()
+			}
+			w.mu.Unlock()
+		case <-idle.C:
+			w.mu.Lock()
+			idleFor := w.now().Sub(w.lastRead)
+			w.mu.Unlock()
+			if idleFor >= w.idleTimeout {
+				return nil
+			}
+		}
+	}
+}
+
+func removeLog(logs []ethtypes.Log, removed ethtypes.Log) []ethtypes.Log {
+	res := logs[:0]
+	for _, l := range logs {
+		if l.BlockHash == removed.BlockHash && l.TxHash == removed.TxHash && l.Index == removed.Index {
+			continue
+		}
+		res = append(res, l)
+	}
+	return res
+}
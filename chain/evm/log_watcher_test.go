@@ -0,0 +1,136 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLogSubscriber struct {
+	subscribed chan chan<- ethtypes.Log
+	fail       chan error
+}
+
+func (f *fakeLogSubscriber) SubscribeFilterLogs(_ context.Context, _ ethereum.FilterQuery, ch chan<- ethtypes.Log) (ethereum.Subscription, error) {
+	f.subscribed <- ch
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		select {
+		case <-quit:
+			return nil
+		case err := <-f.fail:
+			return err
+		}
+	}), nil
+}
+
+func (f *fakeLogSubscriber) Close() {}
+
+func TestLogWatcher(t *testing.T) {
+	topic := common.HexToHash("0x01")
+	query := ethereum.FilterQuery{Topics: [][]common.Hash{{topic}}}
+	sub := &fakeLogSubscriber{subscribed: make(chan chan<- ethtypes.Log, 1), fail: make(chan error)}
+	var dials atomic.Int32
+	conn := newMockEthClientToFilterLogs(t)
+
+	w := newLogWatcher(query, func(context.Context) (logSubscriber, error) {
+		dials.Add(1)
+		return sub, nil
+	}, conn)
+	w.settle = 0
+
+	_, _, ok := w.pending(math.MaxUint64)
+	require.False(t, ok, "the watcher is not live before it is started")
+
+	conn.On("HeaderByNumber", mock.Anything, (*big.Int)(nil)).Return(&ethtypes.Header{Number: big.NewInt(10)}, nil)
+	conn.On("FilterLogs", mock.Anything, ethereum.FilterQuery{
+		Topics:    query.Topics,
+		FromBlock: big.NewInt(5),
+		ToBlock:   big.NewInt(10),
+	}).Return([]ethtypes.Log{{BlockNumber: 6}, {BlockNumber: 7}}, nil)
+
+	w.start(5)
+	ch := <-sub.subscribed
+	require.Eventually(t, func() bool {
+		_, _, ok := w.pending(math.MaxUint64)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("the gap up to the subscription is backfilled", func(t *testing.T) {
+		logs, upTo, ok := w.pending(math.MaxUint64)
+		require.True(t, ok)
+		require.Equal(t, uint64(10), upTo)
+		require.Equal(t, []uint64{6, 7}, blockNumbers(logs))
+
+		w.ack(upTo)
+		logs, _, _ = w.pending(math.MaxUint64)
+		require.Empty(t, logs)
+	})
+
+	t.Run("new logs are received through the subscription", func(t *testing.T) {
+		// logs which were backfilled already are skipped
+		ch <- ethtypes.Log{BlockNumber: 9}
+		ch <- ethtypes.Log{BlockNumber: 12}
+
+		require.Eventually(t, func() bool {
+			logs, upTo, _ := w.pending(math.MaxUint64)
+			return len(logs) == 1 && logs[0].BlockNumber == 12 && upTo == 12
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("logs above the confirmed block stay pending", func(t *testing.T) {
+		ch <- ethtypes.Log{BlockNumber: 14}
+		require.Eventually(t, func() bool {
+			logs, _, _ := w.pending(math.MaxUint64)
+			return len(logs) == 2
+		}, time.Second, 10*time.Millisecond)
+
+		logs, upTo, ok := w.pending(13)
+		require.True(t, ok)
+		require.Equal(t, uint64(12), upTo)
+		require.Equal(t, []uint64{12}, blockNumbers(logs))
+
+		w.ack(upTo)
+		logs, upTo, _ = w.pending(13)
+		require.Empty(t, logs)
+		require.Equal(t, uint64(12), upTo)
+	})
+
+	t.Run("logs of orphaned blocks are dropped", func(t *testing.T) {
+		ch <- ethtypes.Log{BlockNumber: 14, Removed: true}
+		require.Eventually(t, func() bool {
+			logs, _, _ := w.pending(math.MaxUint64)
+			return len(logs) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("a failed subscription falls back to polling for a while", func(t *testing.T) {
+		sub.fail <- errors.New("connection reset")
+
+		require.Eventually(t, func() bool {
+			_, _, ok := w.pending(math.MaxUint64)
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+
+		w.start(13)
+		require.Equal(t, int32(1), dials.Load())
+	})
+}
+
+func blockNumbers(logs []ethtypes.Log) []uint64 {
+	res := make([]uint64, len(logs))
+	for i, l := range logs {
+		res[i] = l.BlockNumber
+	}
+	return res
+}
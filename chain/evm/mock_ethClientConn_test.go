@@ -172,6 +172,32 @@ func (_m *mockEthClientConn) EstimateGas(ctx context.Context, call ethereum.Call
 	return r0, r1
 }
 
+// FeeHistory provides a mock function with given fields: ctx, blockCount, lastBlock, rewardPercentiles
+func (_m *mockEthClientConn) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	ret := _m.Called(ctx, blockCount, lastBlock, rewardPercentiles)
+
+	var r0 *ethereum.FeeHistory
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *big.Int, []float64) (*ethereum.FeeHistory, error)); ok {
+		return rf(ctx, blockCount, lastBlock, rewardPercentiles)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *big.Int, []float64) *ethereum.FeeHistory); ok {
+		r0 = rf(ctx, blockCount, lastBlock, rewardPercentiles)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ethereum.FeeHistory)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, *big.Int, []float64) error); ok {
+		r1 = rf(ctx, blockCount, lastBlock, rewardPercentiles)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FilterLogs provides a mock function with given fields: ctx, query
 func (_m *mockEthClientConn) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
 	ret := _m.Called(ctx, query)
@@ -224,6 +250,30 @@ func (_m *mockEthClientConn) HeaderByNumber(ctx context.Context, number *big.Int
 	return r0, r1
 }
 
+// NonceAt provides a mock function with given fields: ctx, account, blockNumber
+func (_m *mockEthClientConn) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	ret := _m.Called(ctx, account, blockNumber)
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, common.Address, *big.Int) (uint64, error)); ok {
+		return rf(ctx, account, blockNumber)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, common.Address, *big.Int) uint64); ok {
+		r0 = rf(ctx, account, blockNumber)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, common.Address, *big.Int) error); ok {
+		r1 = rf(ctx, account, blockNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PendingCodeAt provides a mock function with given fields: ctx, account
 func (_m *mockEthClientConn) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
 	ret := _m.Called(ctx, account)
@@ -399,6 +449,32 @@ func (_m *mockEthClientConn) TransactionByHash(ctx context.Context, hash common.
 	return r0, r1, r2
 }
 
+// TransactionReceipt provides a mock function with given fields: ctx, txHash
+func (_m *mockEthClientConn) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	ret := _m.Called(ctx, txHash)
+
+	var r0 *types.Receipt
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash) (*types.Receipt, error)); ok {
+		return rf(ctx, txHash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash) *types.Receipt); ok {
+		r0 = rf(ctx, txHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Receipt)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash) error); ok {
+		r1 = rf(ctx, txHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // newMockEthClientConn creates a new instance of mockEthClientConn. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func newMockEthClientConn(t interface {
@@ -3,15 +3,21 @@
 package evm
 
 import (
-	context "context"
 	big "math/big"
-	time "time"
 
-	ethereum "github.com/ethereum/go-ethereum"
 	abi "github.com/ethereum/go-ethereum/accounts/abi"
+
 	common "github.com/ethereum/go-ethereum/common"
-	types "github.com/ethereum/go-ethereum/core/types"
+
+	context "context"
+
+	ethereum "github.com/ethereum/go-ethereum"
+
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+
+	types "github.com/ethereum/go-ethereum/core/types"
 )
 
 // mockEvmClienter is an autogenerated mock type for the evmClienter type
@@ -80,6 +86,32 @@ func (_m *mockEvmClienter) DeployContract(ctx context.Context, chainID *big.Int,
 	return r0, r1, r2
 }
 
+// EstimateContractCost provides a mock function with given fields: ctx, contractAbi, addr, method, arguments
+func (_m *mockEvmClienter) EstimateContractCost(ctx context.Context, contractAbi abi.ABI, addr common.Address, method string, arguments []interface{}) (*big.Int, error) {
+	ret := _m.Called(ctx, contractAbi, addr, method, arguments)
+
+	var r0 *big.Int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, abi.ABI, common.Address, string, []interface{}) (*big.Int, error)); ok {
+		return rf(ctx, contractAbi, addr, method, arguments)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, abi.ABI, common.Address, string, []interface{}) *big.Int); ok {
+		r0 = rf(ctx, contractAbi, addr, method, arguments)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*big.Int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, abi.ABI, common.Address, string, []interface{}) error); ok {
+		r1 = rf(ctx, contractAbi, addr, method, arguments)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ExecuteSmartContract provides a mock function with given fields: ctx, chainID, contractAbi, addr, mevRelay, method, arguments
 func (_m *mockEvmClienter) ExecuteSmartContract(ctx context.Context, chainID *big.Int, contractAbi abi.ABI, addr common.Address, mevRelay bool, method string, arguments []interface{}) (*types.Transaction, error) {
 	ret := _m.Called(ctx, chainID, contractAbi, addr, mevRelay, method, arguments)
@@ -154,6 +186,32 @@ func (_m *mockEvmClienter) FindBlockNearestToTime(ctx context.Context, startingH
 	return r0, r1
 }
 
+// FindConfirmedBlockNumber provides a mock function with given fields: ctx
+func (_m *mockEvmClienter) FindConfirmedBlockNumber(ctx context.Context) (*big.Int, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *big.Int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*big.Int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *big.Int); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*big.Int)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindCurrentBlockNumber provides a mock function with given fields: ctx
 func (_m *mockEvmClienter) FindCurrentBlockNumber(ctx context.Context) (*big.Int, error) {
 	ret := _m.Called(ctx)
@@ -180,6 +238,30 @@ func (_m *mockEvmClienter) FindCurrentBlockNumber(ctx context.Context) (*big.Int
 	return r0, r1
 }
 
+// FindCurrentBlockTime provides a mock function with given fields: ctx
+func (_m *mockEvmClienter) FindCurrentBlockTime(ctx context.Context) (time.Time, error) {
+	ret := _m.Called(ctx)
+
+	var r0 time.Time
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (time.Time, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) time.Time); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEthClient provides a mock function with given fields:
 func (_m *mockEvmClienter) GetEthClient() ethClientConn {
 	ret := _m.Called()
@@ -196,6 +278,22 @@ func (_m *mockEvmClienter) GetEthClient() ethClientConn {
 	return r0
 }
 
+// GetQuorumEthClient provides a mock function with given fields:
+func (_m *mockEvmClienter) GetQuorumEthClient() ethClientConn {
+	ret := _m.Called()
+
+	var r0 ethClientConn
+	if rf, ok := ret.Get(0).(func() ethClientConn); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(ethClientConn)
+		}
+	}
+
+	return r0
+}
+
 // LastValsetID provides a mock function with given fields: ctx, addr
 func (_m *mockEvmClienter) LastValsetID(ctx context.Context, addr common.Address) (*big.Int, error) {
 	ret := _m.Called(ctx, addr)
@@ -222,6 +320,11 @@ func (_m *mockEvmClienter) LastValsetID(ctx context.Context, addr common.Address
 	return r0, r1
 }
 
+// TrackTransaction provides a mock function with given fields: tx, hooks
+func (_m *mockEvmClienter) TrackTransaction(tx *types.Transaction, hooks txHooks) {
+	_m.Called(tx, hooks)
+}
+
 // TransactionByHash provides a mock function with given fields: ctx, txHash
 func (_m *mockEvmClienter) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
 	ret := _m.Called(ctx, txHash)
@@ -260,8 +363,7 @@ func (_m *mockEvmClienter) TransactionByHash(ctx context.Context, txHash common.
 func newMockEvmClienter(t interface {
 	mock.TestingT
 	Cleanup(func())
-},
-) *mockEvmClienter {
+}) *mockEvmClienter {
 	mock := &mockEvmClienter{}
 	mock.Mock.Test(t)
 
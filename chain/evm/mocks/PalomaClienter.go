@@ -1,10 +1,12 @@
-// Code generated by mockery v2.20.0. DO NOT EDIT.
+// Code generated by mockery v2.32.4. DO NOT EDIT.
 
 package mocks
 
 import (
 	context "context"
 
+	chain "github.com/palomachain/pigeon/chain"
+
 	evmtypes "github.com/palomachain/paloma/x/evm/types"
 
 	mock "github.com/stretchr/testify/mock"
@@ -107,6 +109,56 @@ func (_m *PalomaClienter) QueryGetLastEventNonce(ctx context.Context, orchestrat
 	return r0, r1
 }
 
+// QueryGetLastObservedEthBlock provides a mock function with given fields: ctx
+func (_m *PalomaClienter) QueryGetLastObservedEthBlock(ctx context.Context) (uint64, error) {
+	ret := _m.Called(ctx)
+
+	var r0 uint64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (uint64, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) uint64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QueryMessagesForRelaying provides a mock function with given fields: ctx, queueTypeName
+func (_m *PalomaClienter) QueryMessagesForRelaying(ctx context.Context, queueTypeName string) ([]chain.MessageWithSignatures, error) {
+	ret := _m.Called(ctx, queueTypeName)
+
+	var r0 []chain.MessageWithSignatures
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]chain.MessageWithSignatures, error)); ok {
+		return rf(ctx, queueTypeName)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []chain.MessageWithSignatures); ok {
+		r0 = rf(ctx, queueTypeName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]chain.MessageWithSignatures)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, queueTypeName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendBatchSendToEVMClaim provides a mock function with given fields: ctx, claim
 func (_m *PalomaClienter) SendBatchSendToEVMClaim(ctx context.Context, claim types.MsgBatchSendToEthClaim) error {
 	ret := _m.Called(ctx, claim)
@@ -163,13 +215,12 @@ func (_m *PalomaClienter) SetPublicAccessData(ctx context.Context, queueTypeName
 	return r0
 }
 
-type mockConstructorTestingTNewPalomaClienter interface {
+// NewPalomaClienter creates a new instance of PalomaClienter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewPalomaClienter(t interface {
 	mock.TestingT
 	Cleanup(func())
-}
-
-// NewPalomaClienter creates a new instance of PalomaClienter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
-func NewPalomaClienter(t mockConstructorTestingTNewPalomaClienter) *PalomaClienter {
+}) *PalomaClienter {
 	mock := &PalomaClienter{}
 	mock.Mock.Test(t)
 
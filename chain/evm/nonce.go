@@ -0,0 +1,168 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/palomachain/pigeon/internal/liblog"
+	log "github.com/sirupsen/logrus"
+)
+
+// nonceErrors are returned by nodes when the nonce of a transaction doesn't
+// line up with the state of the account. Errors coming over RPC lose their
+// type, so they can only be matched by their message.
+var nonceErrors = []string{
+	"nonce too low",
+	"nonce too high",
+	"replacement transaction underpriced",
+	"already known",
+}
+
+func isNonceError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, nonceErr := range nonceErrors {
+		if strings.Contains(msg, nonceErr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nonceManager hands out transaction nonces for a single signing address on
+// a single chain. It syncs with the pending nonce of the chain when it is
+// first used and after errors, and hands out nonces sequentially from its
+// local state in between, so that concurrent transactions never end up with
+// the same nonce.
+//
+// A nil manager falls back to asking the chain for every transaction.
+type nonceManager struct {
+	mu     sync.Mutex
+	next   uint64
+	synced bool
+	// gaps holds the nonces below next which were handed out, but never
+	// used. They are handed out again first, as every transaction after a
+	// gap would be stuck until it is filled.
+	gaps []uint64
+}
+
+// allocate returns the nonce to use for the next transaction.
+func (m *nonceManager) allocate(ctx context.Context, pendingNonce func(context.Context) (uint64, error)) (uint64, error) {
+	if m == nil {
+		return pendingNonce(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.synced {
+		pending, err := pendingNonce(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		if m.next > pending {
+			// transactions which were sent never made it into the mempool,
+			// or were dropped from it
+			liblog.WithContext(ctx).WithFields(log.Fields{
+				"pending-nonce": pending,
+				"local-nonce":   m.next,
+			}).Warn("nonce gap detected, filling it")
+		}
+
+		m.next = pending
+		m.gaps = nil
+		m.synced = true
+	}
+
+	if len(m.gaps) > 0 {
+		nonce := m.gaps[0]
+		m.gaps = m.gaps[1:]
+		return nonce, nil
+	}
+
+	nonce := m.next
+	m.next++
+
+	return nonce, nil
+}
+
+// release gives back a nonce which was not used, because its transaction
+// was never sent.
+func (m *nonceManager) release(nonce uint64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.synced || nonce >= m.next {
+		return
+	}
+
+	if nonce+1 == m.next {
+		m.next = nonce
+		return
+	}
+
+	i := sort.Search(len(m.gaps), func(i int) bool { return m.gaps[i] >= nonce })
+	if i < len(m.gaps) && m.gaps[i] == nonce {
+		return
+	}
+	m.gaps = append(m.gaps, 0)
+	copy(m.gaps[i+1:], m.gaps[i:])
+	m.gaps[i] = nonce
+}
+
+// resync makes the manager sync with the chain again before handing out the
+// next nonce. It is used after errors which show that the local state is off,
+// e.g. because someone else sent a transaction from the same address.
+func (m *nonceManager) resync() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.synced = false
+}
+
+type nonceManagerKey struct {
+	chainID string
+	addr    common.Address
+}
+
+// nonceManagers holds a nonce manager for every chain and signing address,
+// so that the processors built for the same chain share it.
+type nonceManagers struct {
+	mu       sync.Mutex
+	managers map[nonceManagerKey]*nonceManager
+}
+
+func (n *nonceManagers) get(chainID *big.Int, addr common.Address) *nonceManager {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.managers == nil {
+		n.managers = make(map[nonceManagerKey]*nonceManager)
+	}
+
+	key := nonceManagerKey{chainID: chainID.String(), addr: addr}
+	m, ok := n.managers[key]
+	if !ok {
+		m = &nonceManager{}
+		n.managers[key] = m
+	}
+
+	return m
+}
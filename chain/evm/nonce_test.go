@@ -0,0 +1,119 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceManager(t *testing.T) {
+	ctx := context.Background()
+	pending := uint64(10)
+	calls := 0
+	pendingNonce := func(context.Context) (uint64, error) {
+		calls++
+		return pending, nil
+	}
+
+	t.Run("concurrent allocations never collide", func(t *testing.T) {
+		var m nonceManager
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		seen := make(map[uint64]struct{})
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				nonce, err := m.allocate(ctx, pendingNonce)
+				require.NoError(t, err)
+				mu.Lock()
+				seen[nonce] = struct{}{}
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, seen, 20)
+		for nonce := uint64(10); nonce < 30; nonce++ {
+			require.Contains(t, seen, nonce)
+		}
+	})
+
+	t.Run("it only syncs with the chain at the start and after errors", func(t *testing.T) {
+		var m nonceManager
+		calls = 0
+		for i := uint64(0); i < 3; i++ {
+			nonce, err := m.allocate(ctx, pendingNonce)
+			require.NoError(t, err)
+			require.Equal(t, pending+i, nonce)
+		}
+		require.Equal(t, 1, calls)
+
+		// someone else sent transactions from the same address
+		pending = 15
+		m.resync()
+		nonce, err := m.allocate(ctx, pendingNonce)
+		require.NoError(t, err)
+		require.Equal(t, uint64(15), nonce)
+		require.Equal(t, 2, calls)
+		pending = 10
+	})
+
+	t.Run("released nonces fill the gaps first", func(t *testing.T) {
+		var m nonceManager
+		first, _ := m.allocate(ctx, pendingNonce)
+		second, _ := m.allocate(ctx, pendingNonce)
+		third, _ := m.allocate(ctx, pendingNonce)
+
+		m.release(third)
+		nonce, err := m.allocate(ctx, pendingNonce)
+		require.NoError(t, err)
+		require.Equal(t, third, nonce)
+
+		m.release(second)
+		m.release(first)
+		for _, want := range []uint64{first, second, third + 1} {
+			nonce, err := m.allocate(ctx, pendingNonce)
+			require.NoError(t, err)
+			require.Equal(t, want, nonce)
+		}
+	})
+
+	t.Run("syncing errors are returned", func(t *testing.T) {
+		var m nonceManager
+		_, err := m.allocate(ctx, func(context.Context) (uint64, error) {
+			return 0, errors.New("oh no")
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("without a manager the pending nonce is used", func(t *testing.T) {
+		var m *nonceManager
+		nonce, err := m.allocate(ctx, pendingNonce)
+		require.NoError(t, err)
+		require.Equal(t, pending, nonce)
+		m.release(nonce)
+		m.resync()
+	})
+}
+
+func TestNonceManagers(t *testing.T) {
+	var n nonceManagers
+	addr := common.HexToAddress("0x12")
+
+	require.Same(t, n.get(big.NewInt(1), addr), n.get(big.NewInt(1), addr))
+	require.NotSame(t, n.get(big.NewInt(1), addr), n.get(big.NewInt(2), addr))
+	require.NotSame(t, n.get(big.NewInt(1), addr), n.get(big.NewInt(1), common.HexToAddress("0x34")))
+}
+
+func TestIsNonceError(t *testing.T) {
+	require.True(t, isNonceError(errors.New("nonce too low: next nonce 5, tx nonce 4")))
+	require.True(t, isNonceError(errors.New("replacement transaction underpriced")))
+	require.False(t, isNonceError(errors.New("execution reverted")))
+	require.False(t, isNonceError(nil))
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/VolumeFi/whoops"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	evmtypes "github.com/palomachain/paloma/x/evm/types"
 	gravity "github.com/palomachain/paloma/x/gravity/types"
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/errors"
@@ -17,7 +19,11 @@ import (
 )
 
 type Processor struct {
-	compass          *compass
+	compass *compass
+	// previousCompass is the compass the chain was upgraded from. The
+	// messages created for it are still relayed to it, and its gravity
+	// events are still claimed, until the handover window closes.
+	previousCompass  *compassHandover
 	evmClient        *Client
 	chainType        string
 	chainReferenceID string
@@ -116,11 +122,63 @@ func (p Processor) ProcessMessages(ctx context.Context, queueTypeName queue.Type
 		return chain.ErrProcessorDoesNotSupportThisQueue.Format(queueTypeName)
 	}
 
-	return p.compass.processMessages(
-		ctx,
-		queueTypeName.String(),
-		msgs,
-	)
+	// a message whose transaction is still pending is only told to Paloma
+	// once the transaction is final, so it must not be relayed again
+	// meanwhile
+	msgs = slice.Filter(msgs, func(msg chain.MessageWithSignatures) bool {
+		return !p.evmClient.hasPendingTransaction(messageTxRef(queueTypeName.String(), msg.ID))
+	})
+
+	current, previous := p.routeMessages(msgs)
+	if len(previous) == 0 {
+		return p.compass.processMessages(ctx, queueTypeName.String(), current)
+	}
+
+	var gErr whoops.Group
+	gErr.Add(p.previousCompass.compass.processMessages(ctx, queueTypeName.String(), previous))
+	gErr.Add(p.compass.processMessages(ctx, queueTypeName.String(), current))
+
+	return gErr.Return()
+}
+
+// handingOver returns the compass the chain was upgraded from, or nil once
+// its handover window closed.
+func (p Processor) handingOver() *compass {
+	if !p.previousCompass.active(time.Now()) {
+		return nil
+	}
+	return p.previousCompass.compass
+}
+
+// routeMessages splits the messages into the ones for the active compass and
+// the ones created for the compass the chain is handing over from.
+func (p Processor) routeMessages(msgs []chain.MessageWithSignatures) (current, previous []chain.MessageWithSignatures) {
+	prev := p.handingOver()
+	if prev == nil {
+		return msgs, nil
+	}
+
+	for _, msg := range msgs {
+		m, ok := msg.Msg.(*evmtypes.Message)
+		if ok && len(m.GetCompassAddr()) > 0 && common.HexToAddress(m.GetCompassAddr()) == prev.smartContractAddr {
+			previous = append(previous, msg)
+			continue
+		}
+		current = append(current, msg)
+	}
+
+	return current, previous
+}
+
+// CompassVersions returns the compass contracts the processor relays to.
+func (p Processor) CompassVersions() chain.CompassVersions {
+	res := chain.CompassVersions{Active: compassVersion(p.compass)}
+	if prev := p.handingOver(); prev != nil {
+		v := compassVersion(prev)
+		res.Previous = &v
+		res.HandoverUntil = p.previousCompass.until
+	}
+	return res
 }
 
 func (p Processor) GravityRelayBatches(ctx context.Context, batches []chain.GravityBatchWithSignatures) error {
@@ -171,6 +229,10 @@ func (p Processor) ProvideEvidence(ctx context.Context, queueTypeName queue.Type
 	return gErr.Return()
 }
 
+func (p Processor) TrackTransactions(ctx context.Context) error {
+	return p.evmClient.CheckTransactions(ctx)
+}
+
 func (p Processor) ExternalAccount() chain.ExternalAccount {
 	return chain.ExternalAccount{
 		ChainType:        p.chainType,
@@ -212,11 +274,11 @@ func (p Processor) isRightChain(blockHash common.Hash) error {
 }
 
 func (p Processor) GetBatchSendEvents(ctx context.Context, orchestrator string) ([]chain.BatchSendEvent, error) {
-	return p.compass.GetBatchSendEvents(ctx, orchestrator)
+	return p.compass.GetBatchSendEvents(ctx, orchestrator, p.handingOver())
 }
 
 func (p Processor) GetSendToPalomaEvents(ctx context.Context, orchestrator string) ([]chain.SendToPalomaEvent, error) {
-	return p.compass.GetSendToPalomaEvents(ctx, orchestrator)
+	return p.compass.GetSendToPalomaEvents(ctx, orchestrator, p.handingOver())
 }
 
 func (p Processor) SubmitBatchSendToEthClaims(ctx context.Context, batchSendEvents []chain.BatchSendEvent, orchestrator string) error {
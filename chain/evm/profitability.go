@@ -0,0 +1,83 @@
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/config"
+)
+
+// priceSource values token amounts in the native coin of the chain.
+type priceSource interface {
+	// weiValue returns the value in wei of the amount of base units of the
+	// token.
+	weiValue(token common.Address, amount *big.Int) (*big.Int, error)
+}
+
+// fixedPriceSource values tokens with the prices set in the config.
+type fixedPriceSource map[common.Address]*big.Rat
+
+func (s fixedPriceSource) weiValue(token common.Address, amount *big.Int) (*big.Int, error) {
+	if amount.Sign() == 0 {
+		return new(big.Int), nil
+	}
+
+	price, ok := s[token]
+	if !ok {
+		return nil, ErrNoTokenPrice.Format(token)
+	}
+
+	value := new(big.Rat).Mul(price, new(big.Rat).SetInt(amount))
+	return new(big.Int).Quo(value.Num(), value.Denom()), nil
+}
+
+// batchProfitability decides whether a gravity batch is worth relaying.
+type batchProfitability struct {
+	prices  priceSource
+	maxLoss *big.Int
+}
+
+func newBatchProfitability(cfg config.GravityProfitability) (*batchProfitability, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	prices := make(fixedPriceSource, len(cfg.TokenPrices))
+	for token, price := range cfg.TokenPrices {
+		if !common.IsHexAddress(token) {
+			return nil, ErrInvalidTokenPrice.Format(token, price)
+		}
+		p, ok := new(big.Rat).SetString(price)
+		if !ok || p.Sign() < 0 {
+			return nil, ErrInvalidTokenPrice.Format(token, price)
+		}
+		prices[common.HexToAddress(token)] = p
+	}
+
+	return &batchProfitability{
+		prices:  prices,
+		maxLoss: new(big.Int).SetUint64(cfg.MaxLoss),
+	}, nil
+}
+
+// batchFees returns the fees the transfers of the batch pay to its relayer,
+// in base units of the batch's token. The transfers of this version of
+// Paloma don't carry fees yet, so batches are always relayed at a loss,
+// which the loss threshold caps.
+func batchFees(chain.GravityBatchWithSignatures) *big.Int {
+	return new(big.Int)
+}
+
+// loss returns how much relaying the batch for the given cost in wei would
+// lose, and true if that is more than the threshold. The loss is negative
+// when the batch is profitable.
+func (p *batchProfitability) loss(batch chain.GravityBatchWithSignatures, cost *big.Int) (*big.Int, bool, error) {
+	feesValue, err := p.prices.weiValue(common.HexToAddress(batch.TokenContract), batchFees(batch))
+	if err != nil {
+		return nil, false, err
+	}
+
+	loss := new(big.Int).Sub(cost, feesValue)
+	return loss, loss.Cmp(p.maxLoss) > 0, nil
+}
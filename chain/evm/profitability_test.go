@@ -0,0 +1,64 @@
+package evm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchProfitability(t *testing.T) {
+	token := common.HexToAddress("0x0123")
+
+	t.Run("the check is disabled unless enabled", func(t *testing.T) {
+		p, err := newBatchProfitability(config.GravityProfitability{MaxLoss: 5})
+		require.NoError(t, err)
+		require.Nil(t, p)
+	})
+
+	t.Run("invalid token prices are rejected", func(t *testing.T) {
+		for _, prices := range []map[string]string{
+			{"not an address": "1"},
+			{token.Hex(): "one"},
+			{token.Hex(): "-1"},
+		} {
+			_, err := newBatchProfitability(config.GravityProfitability{Enabled: true, TokenPrices: prices})
+			require.ErrorIs(t, err, ErrInvalidTokenPrice)
+		}
+	})
+
+	t.Run("token amounts are valued in wei", func(t *testing.T) {
+		prices := fixedPriceSource{token: big.NewRat(5, 2)}
+
+		value, err := prices.weiValue(token, big.NewInt(3))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(7), value)
+
+		_, err = prices.weiValue(common.HexToAddress("0x0456"), big.NewInt(3))
+		require.ErrorIs(t, err, ErrNoTokenPrice)
+	})
+
+	t.Run("batches losing more than the threshold are unprofitable", func(t *testing.T) {
+		p, err := newBatchProfitability(config.GravityProfitability{
+			Enabled:     true,
+			MaxLoss:     100,
+			TokenPrices: map[string]string{token.Hex(): "2.5"},
+		})
+		require.NoError(t, err)
+
+		batch := chain.GravityBatchWithSignatures{}
+		batch.TokenContract = token.Hex()
+
+		loss, unprofitable, err := p.loss(batch, big.NewInt(100))
+		require.NoError(t, err)
+		require.False(t, unprofitable)
+		require.Equal(t, big.NewInt(100), loss)
+
+		_, unprofitable, err = p.loss(batch, big.NewInt(101))
+		require.NoError(t, err)
+		require.True(t, unprofitable)
+	})
+}
@@ -0,0 +1,137 @@
+package evm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	revertKindNone   = "none"
+	revertKindError  = "error"
+	revertKindPanic  = "panic"
+	revertKindCustom = "custom"
+	revertKindRaw    = "raw"
+)
+
+var (
+	errorStringSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector       = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// revertReason is the decoded reason of a reverted contract call.
+type revertReason struct {
+	// kind tells how the revert data was decoded.
+	kind string
+	// message is the reason of an Error(string) revert, or the name of a
+	// custom error.
+	message string
+	// panicCode is the code of a Panic(uint256) revert.
+	panicCode *big.Int
+	// args are the arguments of a custom error.
+	args []any
+	data []byte
+}
+
+func (r revertReason) String() string {
+	switch r.kind {
+	case revertKindError:
+		return r.message
+	case revertKindPanic:
+		return fmt.Sprintf("panic: 0x%x", r.panicCode)
+	case revertKindCustom:
+		return fmt.Sprintf("%s%v", r.message, r.args)
+	case revertKindRaw:
+		return hexutil.Encode(r.data)
+	}
+	return "no reason given"
+}
+
+// decodeRevert decodes the data a reverted call returned. Besides the
+// built-in Error(string) and Panic(uint256), custom errors defined in the
+// contract's ABI are recognised.
+func decodeRevert(contractABI *abi.ABI, data []byte) revertReason {
+	res := revertReason{kind: revertKindRaw, data: data}
+	if len(data) == 0 {
+		res.kind = revertKindNone
+		return res
+	}
+	if len(data) < 4 {
+		return res
+	}
+
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, errorStringSelector):
+		if msg, err := abi.UnpackRevert(data); err == nil {
+			res.kind, res.message = revertKindError, msg
+		}
+	case bytes.Equal(selector, panicSelector):
+		if len(data) == 4+32 {
+			res.kind, res.panicCode = revertKindPanic, new(big.Int).SetBytes(data[4:])
+		}
+	case contractABI != nil:
+		for _, abiErr := range contractABI.Errors {
+			if !bytes.Equal(abiErr.ID[:4], selector) {
+				continue
+			}
+			args, err := abiErr.Inputs.Unpack(data[4:])
+			if err != nil {
+				break
+			}
+			res.kind, res.message, res.args = revertKindCustom, abiErr.Name, args
+			break
+		}
+	}
+
+	return res
+}
+
+// revertFromError returns the decoded revert reason if the error returned
+// by the node says that the call reverted.
+func revertFromError(contractABI *abi.ABI, err error) (revertReason, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		var data []byte
+		switch d := dataErr.ErrorData().(type) {
+		case string:
+			data, _ = hexutil.Decode(d)
+		case []byte:
+			data = d
+		}
+		return decodeRevert(contractABI, data), true
+	}
+
+	// reverts without a reason don't carry any data
+	if err != nil && strings.Contains(err.Error(), "execution reverted") {
+		return decodeRevert(contractABI, nil), true
+	}
+
+	return revertReason{}, false
+}
+
+// revertError is returned instead of sending a transaction which would
+// revert.
+type revertError struct {
+	reason revertReason
+	err    error
+}
+
+func (e *revertError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTxWouldRevert, e.reason)
+}
+
+func (e *revertError) Unwrap() error {
+	return e.err
+}
+
+func (e *revertError) Is(target error) bool {
+	return target == ErrTxWouldRevert
+}
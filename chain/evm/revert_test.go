@@ -0,0 +1,67 @@
+package evm
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRevert(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"InvalidSignature","inputs":[{"name":"index","type":"uint256"}]}]`))
+	require.NoError(t, err)
+	uint256Type, err := abi.NewType("uint256", "", nil)
+	require.NoError(t, err)
+	stringType, err := abi.NewType("string", "", nil)
+	require.NoError(t, err)
+
+	pack := func(selector []byte, typ abi.Type, value any) []byte {
+		packed, err := abi.Arguments{{Type: typ}}.Pack(value)
+		require.NoError(t, err)
+		return append(append([]byte{}, selector...), packed...)
+	}
+
+	t.Run("error strings", func(t *testing.T) {
+		reason := decodeRevert(&contractABI, pack(errorStringSelector, stringType, "Deadline passed"))
+		require.Equal(t, revertKindError, reason.kind)
+		require.Equal(t, "Deadline passed", reason.String())
+	})
+
+	t.Run("panics", func(t *testing.T) {
+		reason := decodeRevert(&contractABI, pack(panicSelector, uint256Type, big.NewInt(0x11)))
+		require.Equal(t, revertKindPanic, reason.kind)
+		require.Equal(t, big.NewInt(0x11), reason.panicCode)
+	})
+
+	t.Run("custom errors from the ABI", func(t *testing.T) {
+		id := contractABI.Errors["InvalidSignature"].ID
+		reason := decodeRevert(&contractABI, pack(id[:4], uint256Type, big.NewInt(3)))
+		require.Equal(t, revertKindCustom, reason.kind)
+		require.Equal(t, "InvalidSignature", reason.message)
+		require.Equal(t, []any{big.NewInt(3)}, reason.args)
+	})
+
+	t.Run("unknown data is kept as it is", func(t *testing.T) {
+		reason := decodeRevert(&contractABI, []byte{1, 2, 3, 4, 5})
+		require.Equal(t, revertKindRaw, reason.kind)
+		require.Equal(t, "0x0102030405", reason.String())
+	})
+
+	t.Run("reverts are recognised in errors returned by the node", func(t *testing.T) {
+		data := pack(errorStringSelector, stringType, "oh no")
+		reason, ok := revertFromError(&contractABI, fakeJsonRpcError(hexutil.Encode(data)))
+		require.True(t, ok)
+		require.Equal(t, "oh no", reason.message)
+
+		reason, ok = revertFromError(&contractABI, errors.New("execution reverted"))
+		require.True(t, ok)
+		require.Equal(t, revertKindNone, reason.kind)
+
+		_, ok = revertFromError(&contractABI, errors.New("connection refused"))
+		require.False(t, ok)
+	})
+}
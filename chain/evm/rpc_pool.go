@@ -0,0 +1,430 @@
+package evm
+
+import (
+	"context"
+	goerrors "errors"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/palomachain/pigeon/config"
+	"github.com/palomachain/pigeon/errors"
+	"github.com/palomachain/pigeon/internal/liblog"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	rpcBenchBase = 5 * time.Second
+	rpcBenchMax  = 2 * time.Minute
+
+	// rpcScoreDecay is the weight of the latest call in the health score of
+	// an endpoint.
+	rpcScoreDecay = 0.2
+
+	// rpcLimitExceededCode is returned by providers which rate limit
+	// callers.
+	rpcLimitExceededCode = -32005
+)
+
+// rpcURLs returns the configured RPC endpoints of a chain, the base one
+// first.
+func rpcURLs(cfg config.EVM) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{cfg.BaseRPCURL}, cfg.RPCURLs...) {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		res = append(res, u)
+	}
+	return res
+}
+
+type rpcEndpoint struct {
+	url  string
+	conn ethClientConn
+
+	// score is a moving average of the calls which the endpoint answered,
+	// between zero and one.
+	score        float64
+	failures     int
+	benchedUntil time.Time
+}
+
+// name is the host of the endpoint. The full URL often carries an API key,
+// so it is kept out of the logs.
+func (e *rpcEndpoint) name() string {
+	u, err := url.Parse(e.url)
+	if err != nil || u.Host == "" {
+		return "invalid-url"
+	}
+	return u.Host
+}
+
+// rpcPool spreads the calls to a chain over several RPC endpoints. Calls go
+// round-robin over the healthy endpoints, and an endpoint which doesn't
+// answer is benched for a while as the call fails over to the next one.
+// Critical reads can be made to require a quorum of endpoints agreeing on
+// the result.
+type rpcPool struct {
+	mu        sync.Mutex
+	endpoints []*rpcEndpoint
+	next      int
+	now       func() time.Time
+
+	quorum int
+}
+
+var _ ethClientConn = &rpcPool{}
+
+func newRPCPool(quorum int) *rpcPool {
+	return &rpcPool{
+		now:    time.Now,
+		quorum: quorum,
+	}
+}
+
+func (p *rpcPool) add(rawURL string, conn ethClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.endpoints = append(p.endpoints, &rpcEndpoint{url: rawURL, conn: conn, score: 1})
+}
+
+// dialRPCPool connects to all RPC endpoints of a chain. Endpoints which
+// can't be dialed are left out, as long as at least one of them can be.
+func dialRPCPool(urls []string, quorum int) (*rpcPool, error) {
+	if len(urls) == 0 {
+		return nil, errors.Unrecoverable(ErrNoRPCEndpoints)
+	}
+	if quorum > len(urls) {
+		return nil, errors.Unrecoverable(ErrInvalidRPCQuorum.Format(quorum, len(urls)))
+	}
+
+	p := newRPCPool(quorum)
+	var dialErr error
+	for _, u := range urls {
+		conn, err := ethclient.Dial(u)
+		if err != nil {
+			e := &rpcEndpoint{url: u}
+			log.WithError(err).WithField("rpc-endpoint", e.name()).Warn("couldn't dial RPC endpoint")
+			dialErr = err
+			continue
+		}
+		p.add(u, conn)
+	}
+
+	if len(p.endpoints) == 0 {
+		return nil, dialErr
+	}
+
+	return p, nil
+}
+
+// order returns the endpoints in the order they should be tried in: the
+// healthy ones round-robin, followed by the benched ones, best score first.
+func (p *rpcPool) order() []*rpcEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := len(p.endpoints)
+	healthy := make([]*rpcEndpoint, 0, n)
+	var benched []*rpcEndpoint
+	for i := 0; i < n; i++ {
+		e := p.endpoints[(p.next+i)%n]
+		if now.Before(e.benchedUntil) {
+			benched = append(benched, e)
+			continue
+		}
+		healthy = append(healthy, e)
+	}
+	if n > 0 {
+		p.next = (p.next + 1) % n
+	}
+
+	sort.SliceStable(benched, func(i, j int) bool { return benched[i].score > benched[j].score })
+
+	return append(healthy, benched...)
+}
+
+func (p *rpcPool) succeeded(e *rpcEndpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.score = e.score*(1-rpcScoreDecay) + rpcScoreDecay
+	e.failures = 0
+	e.benchedUntil = time.Time{}
+}
+
+// failed benches the endpoint for a time which doubles with every
+// consecutive failure.
+func (p *rpcPool) failed(ctx context.Context, e *rpcEndpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.score *= 1 - rpcScoreDecay
+	e.failures++
+
+	bench := rpcBenchMax
+	if shift := e.failures - 1; shift < 16 {
+		if d := rpcBenchBase << shift; d < bench {
+			bench = d
+		}
+	}
+	e.benchedUntil = p.now().Add(bench)
+
+	liblog.WithContext(ctx).WithError(err).WithFields(log.Fields{
+		"rpc-endpoint": e.name(),
+		"score":        e.score,
+		"benched-for":  bench,
+	}).Warn("RPC endpoint failed")
+}
+
+// isEndpointError tells whether an error was caused by the endpoint, as
+// opposed to the call itself. Only the former are worth failing over.
+func isEndpointError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if goerrors.Is(err, ethereum.NotFound) {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if goerrors.As(err, &httpErr) {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if goerrors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == rpcLimitExceededCode
+	}
+
+	var dataErr rpc.DataError
+	return !goerrors.As(err, &dataErr)
+}
+
+// poolCall makes the call against the endpoints of the pool until one of
+// them answers.
+func poolCall[T any](ctx context.Context, p *rpcPool, fn func(ethClientConn) (T, error)) (T, error) {
+	var res T
+	var lastErr error = ErrNoRPCEndpoints
+	for _, e := range p.order() {
+		res, lastErr = fn(e.conn)
+		if !isEndpointError(ctx, lastErr) {
+			if ctx.Err() == nil {
+				p.succeeded(e)
+			}
+			return res, lastErr
+		}
+		p.failed(ctx, e, lastErr)
+	}
+
+	return res, lastErr
+}
+
+// quorumCall makes the call against all endpoints of the pool at once and
+// returns the result once enough of them agree on it. Results are compared
+// by their key.
+func quorumCall[T any](ctx context.Context, p *rpcPool, fn func(ethClientConn) (T, error), key func(T) string) (T, error) {
+	if p.quorum <= 1 {
+		return poolCall(ctx, p, fn)
+	}
+
+	type result struct {
+		val T
+		err error
+	}
+
+	endpoints := p.order()
+	results := make([]result, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		i, e := i, e
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := fn(e.conn)
+			results[i] = result{val: val, err: err}
+		}()
+	}
+	wg.Wait()
+
+	votes := make(map[string]int)
+	var agreed *T
+	var firstErr error
+	for i, r := range results {
+		if isEndpointError(ctx, r.err) {
+			p.failed(ctx, endpoints[i], r.err)
+		} else if ctx.Err() == nil {
+			p.succeeded(endpoints[i])
+		}
+
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+
+		k := key(r.val)
+		votes[k]++
+		if agreed == nil && votes[k] >= p.quorum {
+			val := r.val
+			agreed = &val
+		}
+	}
+
+	if agreed != nil {
+		return *agreed, nil
+	}
+
+	var zero T
+	if len(votes) == 0 && firstErr != nil {
+		return zero, firstErr
+	}
+
+	liblog.WithContext(ctx).WithFields(log.Fields{
+		"quorum":    p.quorum,
+		"endpoints": len(endpoints),
+		"results":   len(votes),
+	}).Error("RPC endpoints don't agree on the result")
+
+	return zero, ErrNoRPCQuorum.Format(p.quorum, len(endpoints))
+}
+
+func (p *rpcPool) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return poolCall(ctx, p, func(c ethClientConn) ([]byte, error) { return c.CodeAt(ctx, contract, blockNumber) })
+}
+
+func (p *rpcPool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return poolCall(ctx, p, func(c ethClientConn) ([]byte, error) { return c.CallContract(ctx, call, blockNumber) })
+}
+
+func (p *rpcPool) HeaderByNumber(ctx context.Context, number *big.Int) (*ethtypes.Header, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*ethtypes.Header, error) { return c.HeaderByNumber(ctx, number) })
+}
+
+func (p *rpcPool) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return poolCall(ctx, p, func(c ethClientConn) ([]byte, error) { return c.PendingCodeAt(ctx, account) })
+}
+
+func (p *rpcPool) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (uint64, error) { return c.PendingNonceAt(ctx, account) })
+}
+
+func (p *rpcPool) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*big.Int, error) { return c.SuggestGasPrice(ctx) })
+}
+
+func (p *rpcPool) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*big.Int, error) { return c.SuggestGasTipCap(ctx) })
+}
+
+func (p *rpcPool) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (uint64, error) { return c.EstimateGas(ctx, call) })
+}
+
+// SendTransaction fails over like any other call. Sending the same signed
+// transaction to several endpoints is harmless, as it can only be included
+// once.
+func (p *rpcPool) SendTransaction(ctx context.Context, tx *ethtypes.Transaction) error {
+	_, err := poolCall(ctx, p, func(c ethClientConn) (struct{}, error) { return struct{}{}, c.SendTransaction(ctx, tx) })
+	return err
+}
+
+func (p *rpcPool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]ethtypes.Log, error) {
+	return poolCall(ctx, p, func(c ethClientConn) ([]ethtypes.Log, error) { return c.FilterLogs(ctx, query) })
+}
+
+// SubscribeFilterLogs subscribes through the first endpoint which accepts
+// the subscription. The subscription doesn't move to another endpoint if
+// that one fails later on.
+func (p *rpcPool) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- ethtypes.Log) (ethereum.Subscription, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (ethereum.Subscription, error) { return c.SubscribeFilterLogs(ctx, query, ch) })
+}
+
+func (p *rpcPool) TransactionByHash(ctx context.Context, hash common.Hash) (*ethtypes.Transaction, bool, error) {
+	type res struct {
+		tx        *ethtypes.Transaction
+		isPending bool
+	}
+	r, err := poolCall(ctx, p, func(c ethClientConn) (res, error) {
+		tx, isPending, err := c.TransactionByHash(ctx, hash)
+		return res{tx: tx, isPending: isPending}, err
+	})
+	return r.tx, r.isPending, err
+}
+
+func (p *rpcPool) BlockByHash(ctx context.Context, hash common.Hash) (*ethtypes.Block, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*ethtypes.Block, error) { return c.BlockByHash(ctx, hash) })
+}
+
+func (p *rpcPool) BlockNumber(ctx context.Context) (uint64, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (uint64, error) { return c.BlockNumber(ctx) })
+}
+
+func (p *rpcPool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*big.Int, error) { return c.BalanceAt(ctx, account, blockNumber) })
+}
+
+func (p *rpcPool) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*ethereum.FeeHistory, error) {
+		return c.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+	})
+}
+
+func (p *rpcPool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (uint64, error) { return c.NonceAt(ctx, account, blockNumber) })
+}
+
+func (p *rpcPool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*ethtypes.Receipt, error) {
+	return poolCall(ctx, p, func(c ethClientConn) (*ethtypes.Receipt, error) { return c.TransactionReceipt(ctx, txHash) })
+}
+
+// quorumPool is a view of the pool whose contract calls, log queries and
+// block lookups need a quorum of endpoints agreeing on the result. All
+// other calls behave as they do on the pool.
+type quorumPool struct {
+	*rpcPool
+}
+
+func (q quorumPool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return quorumCall(ctx, q.rpcPool, func(c ethClientConn) ([]byte, error) {
+		return c.CallContract(ctx, call, blockNumber)
+	}, hexutil.Encode)
+}
+
+func (q quorumPool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]ethtypes.Log, error) {
+	return quorumCall(ctx, q.rpcPool, func(c ethClientConn) ([]ethtypes.Log, error) {
+		return c.FilterLogs(ctx, query)
+	}, func(logs []ethtypes.Log) string {
+		var sb strings.Builder
+		for _, l := range logs {
+			sb.WriteString(l.BlockHash.Hex())
+			sb.WriteString(l.TxHash.Hex())
+			sb.WriteString(hexutil.EncodeUint64(uint64(l.Index)))
+		}
+		return sb.String()
+	})
+}
+
+func (q quorumPool) BlockByHash(ctx context.Context, hash common.Hash) (*ethtypes.Block, error) {
+	return quorumCall(ctx, q.rpcPool, func(c ethClientConn) (*ethtypes.Block, error) {
+		return c.BlockByHash(ctx, hash)
+	}, func(b *ethtypes.Block) string {
+		return b.Hash().Hex()
+	})
+}
@@ -0,0 +1,101 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCPool(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, quorum, n int) (*rpcPool, []*mockEthClientConn, *time.Time) {
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		p := newRPCPool(quorum)
+		p.now = func() time.Time { return now }
+		conns := make([]*mockEthClientConn, n)
+		for i := range conns {
+			conns[i] = newMockEthClientConn(t)
+			p.add("https://rpc.example.org", conns[i])
+		}
+		return p, conns, &now
+	}
+
+	t.Run("reads go round-robin over the endpoints", func(t *testing.T) {
+		p, conns, _ := setup(t, 0, 2)
+		conns[0].On("BlockNumber", mock.Anything).Return(uint64(1), nil).Once()
+		conns[1].On("BlockNumber", mock.Anything).Return(uint64(2), nil).Once()
+
+		first, err := p.BlockNumber(ctx)
+		require.NoError(t, err)
+		second, err := p.BlockNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1, 2}, []uint64{first, second})
+	})
+
+	t.Run("failing endpoints are benched and calls fail over", func(t *testing.T) {
+		p, conns, now := setup(t, 0, 2)
+		conns[0].On("BlockNumber", mock.Anything).Return(uint64(0), errors.New("connection refused")).Once()
+		conns[1].On("BlockNumber", mock.Anything).Return(uint64(2), nil).Twice()
+
+		for i := 0; i < 2; i++ {
+			res, err := p.BlockNumber(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(2), res)
+		}
+		require.Less(t, p.endpoints[0].score, p.endpoints[1].score)
+
+		// once the bench is over, the endpoint gets its turn again
+		*now = now.Add(rpcBenchBase)
+		conns[0].On("BlockNumber", mock.Anything).Return(uint64(1), nil).Once()
+		res, err := p.BlockNumber(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res)
+	})
+
+	t.Run("errors of the call itself don't fail over", func(t *testing.T) {
+		p, conns, _ := setup(t, 0, 2)
+		conns[0].On("TransactionReceipt", mock.Anything, mock.Anything).Return(nil, ethereum.NotFound).Once()
+		conns[1].On("EstimateGas", mock.Anything, mock.Anything).Return(uint64(0), fakeJsonRpcError("0x")).Once()
+
+		_, err := p.TransactionReceipt(ctx, [32]byte{})
+		require.ErrorIs(t, err, ethereum.NotFound)
+		_, err = p.EstimateGas(ctx, ethereum.CallMsg{})
+		require.Error(t, err)
+		require.Zero(t, p.endpoints[0].failures)
+		require.Zero(t, p.endpoints[1].failures)
+	})
+
+	t.Run("quorum reads need enough endpoints to agree", func(t *testing.T) {
+		p, conns, _ := setup(t, 2, 3)
+		conns[0].On("CallContract", mock.Anything, mock.Anything, mock.Anything).Return([]byte{1}, nil)
+		conns[1].On("CallContract", mock.Anything, mock.Anything, mock.Anything).Return([]byte{2}, nil)
+		conns[2].On("CallContract", mock.Anything, mock.Anything, mock.Anything).Return([]byte{1}, nil).Once()
+
+		res, err := quorumPool{p}.CallContract(ctx, ethereum.CallMsg{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte{1}, res)
+
+		conns[2].On("CallContract", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused")).Once()
+		_, err = quorumPool{p}.CallContract(ctx, ethereum.CallMsg{}, nil)
+		require.ErrorIs(t, err, ErrNoRPCQuorum)
+	})
+
+	t.Run("endpoints are taken from the config", func(t *testing.T) {
+		urls := rpcURLs(config.EVM{
+			ChainClientConfig:       config.ChainClientConfig{BaseRPCURL: "https://a.example.org"},
+			EVMSpecificClientConfig: config.EVMSpecificClientConfig{RPCURLs: []string{"https://b.example.org", "https://a.example.org", " "}},
+		})
+		require.Equal(t, []string{"https://a.example.org", "https://b.example.org"}, urls)
+
+		_, err := dialRPCPool(urls, 3)
+		require.ErrorIs(t, err, ErrInvalidRPCQuorum)
+		_, err = dialRPCPool(nil, 0)
+		require.ErrorIs(t, err, ErrNoRPCEndpoints)
+	})
+}
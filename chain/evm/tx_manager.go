@@ -0,0 +1,440 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/palomachain/pigeon/config"
+	"github.com/palomachain/pigeon/internal/liblog"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStuckTxTimeout    = 3 * time.Minute
+	defaultFeeBumpPercent    = 20
+	defaultMaxTxReplacements = 5
+
+	// minFeeBumpPercent is the smallest fee increase nodes accept for a
+	// transaction which replaces another one with the same nonce.
+	minFeeBumpPercent = 10
+
+	cancelTxGasLimit = 21000
+)
+
+type txStatus int
+
+const (
+	// txMined means that the transaction, or one of its replacements, made
+	// it into a block and succeeded.
+	txMined txStatus = iota
+	// txReverted means that the transaction made it into a block, but
+	// failed.
+	txReverted
+	// txReplaced means that the nonce was used up by a transaction pigeon
+	// doesn't know about.
+	txReplaced
+	// txCancelled means that the transaction was no longer needed and was
+	// replaced by a zero value transfer to self.
+	txCancelled
+)
+
+func (s txStatus) String() string {
+	switch s {
+	case txMined:
+		return "mined"
+	case txReverted:
+		return "reverted"
+	case txReplaced:
+		return "replaced"
+	case txCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// txResult is the final outcome of a tracked transaction.
+type txResult struct {
+	status txStatus
+	// tx is the transaction which made it into the block. It is nil if the
+	// nonce was used up by someone else.
+	tx      *ethtypes.Transaction
+	receipt *ethtypes.Receipt
+}
+
+// txHooks connect a tracked transaction back to whoever sent it.
+type txHooks struct {
+	// stillNeeded reports whether the transaction is still worth sending.
+	// Stuck transactions which are no longer needed get cancelled instead of
+	// sped up. Without it, the transaction is always needed.
+	stillNeeded func(context.Context) (bool, error)
+	// onFinal is called once the nonce of the transaction has been used up.
+	onFinal func(context.Context, txResult)
+	// ref identifies what the transaction was sent for, so that nothing
+	// else is sent for it while it is pending.
+	ref string
+	// private is set for transactions which were sent through a private
+	// relay. They are never replaced, as the replacements would end up in
+	// the public mempool. If they don't make it into a block in time, the
+	// relay is assumed to have dropped them, and the nonces are synced with
+	// the chain again to fill the gap they left behind.
+	private bool
+}
+
+type trackedTx struct {
+	hooks txHooks
+	// txs holds the original transaction followed by all of its
+	// replacements, the last one being the most recent.
+	txs         []*ethtypes.Transaction
+	submittedAt time.Time
+	cancelling  bool
+}
+
+func (t *trackedTx) latest() *ethtypes.Transaction {
+	return t.txs[len(t.txs)-1]
+}
+
+// trackedTxs are the pending transactions of a signing address, by nonce.
+type trackedTxs struct {
+	mu      sync.Mutex
+	pending map[uint64]*trackedTx
+}
+
+// txManagers holds the tracked transactions of every chain and signing
+// address, so that the processors built for the same chain don't lose track
+// of the transactions sent by the ones they replaced.
+type txManagers struct {
+	mu      sync.Mutex
+	tracked map[nonceManagerKey]*trackedTxs
+}
+
+func (t *txManagers) get(chainID *big.Int, addr common.Address) *trackedTxs {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tracked == nil {
+		t.tracked = make(map[nonceManagerKey]*trackedTxs)
+	}
+
+	key := nonceManagerKey{chainID: chainID.String(), addr: addr}
+	tracked, ok := t.tracked[key]
+	if !ok {
+		tracked = &trackedTxs{pending: make(map[uint64]*trackedTx)}
+		t.tracked[key] = tracked
+	}
+
+	return tracked
+}
+
+// txManager follows every transaction sent from the signing address until
+// its nonce has been used up. Transactions which don't make it into a block
+// in time are replaced by the same transaction with bumped fees, or
+// cancelled if they are no longer needed.
+type txManager struct {
+	*trackedTxs
+
+	conn ethClientConn
+	addr common.Address
+	sign func(*ethtypes.Transaction) (*ethtypes.Transaction, error)
+	gas  gasStrategy
+	now  func() time.Time
+	// nonces is synced with the chain again when a transaction sent
+	// through a private relay got dropped.
+	nonces *nonceManager
+
+	stuckTimeout    time.Duration
+	feeBumpPercent  int64
+	maxReplacements int
+	// ceiling is the highest price replacements may pay. Nil means no
+	// ceiling.
+	ceiling *big.Int
+}
+
+func newTxManager(conn ethClientConn, addr common.Address, sign func(*ethtypes.Transaction) (*ethtypes.Transaction, error), gas gasStrategy, cfg config.EVMSpecificClientConfig) *txManager {
+	m := &txManager{
+		trackedTxs:      &trackedTxs{pending: make(map[uint64]*trackedTx)},
+		conn:            conn,
+		addr:            addr,
+		sign:            sign,
+		gas:             gas,
+		now:             time.Now,
+		stuckTimeout:    cfg.StuckTxTimeout,
+		feeBumpPercent:  int64(cfg.FeeBumpPercent),
+		maxReplacements: cfg.MaxTxReplacements,
+	}
+	if m.stuckTimeout <= 0 {
+		m.stuckTimeout = defaultStuckTxTimeout
+	}
+	if m.feeBumpPercent <= 0 {
+		m.feeBumpPercent = defaultFeeBumpPercent
+	}
+	if m.feeBumpPercent < minFeeBumpPercent {
+		m.feeBumpPercent = minFeeBumpPercent
+	}
+	if m.maxReplacements <= 0 {
+		m.maxReplacements = defaultMaxTxReplacements
+	}
+	if cfg.GasPricing.MaxGasPrice > 0 {
+		m.ceiling = new(big.Int).SetUint64(cfg.GasPricing.MaxGasPrice)
+	}
+
+	return m
+}
+
+// track starts following a transaction which has just been sent.
+func (m *txManager) track(tx *ethtypes.Transaction, hooks txHooks) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending[tx.Nonce()] = &trackedTx{
+		hooks:       hooks,
+		txs:         []*ethtypes.Transaction{tx},
+		submittedAt: m.now(),
+	}
+}
+
+// has reports whether a transaction which was sent for ref is still
+// pending.
+func (m *txManager) has(ref string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tracked := range m.pending {
+		if tracked.hooks.ref == ref {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check goes through the tracked transactions once, resolving the ones which
+// made it into a block and replacing the ones which are stuck.
+func (m *txManager) check(ctx context.Context) error {
+	m.mu.Lock()
+	nonces := make([]uint64, 0, len(m.pending))
+	for nonce := range m.pending {
+		nonces = append(nonces, nonce)
+	}
+	m.mu.Unlock()
+
+	if len(nonces) == 0 {
+		return nil
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+
+	// every nonce below the one of the next transaction to be mined has
+	// been used up
+	confirmedNonce, err := m.conn.NonceAt(ctx, m.addr, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, nonce := range nonces {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		m.mu.Lock()
+		tracked := m.pending[nonce]
+		m.mu.Unlock()
+		if tracked == nil {
+			continue
+		}
+
+		if err := m.checkTx(ctx, nonce, tracked, confirmedNonce); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *txManager) checkTx(ctx context.Context, nonce uint64, tracked *trackedTx, confirmedNonce uint64) error {
+	logger := liblog.WithContext(ctx).WithFields(log.Fields{
+		"nonce":   nonce,
+		"tx-hash": tracked.latest().Hash(),
+	})
+
+	result, done, err := m.findResult(ctx, tracked)
+	if err != nil {
+		return err
+	}
+
+	if !done && nonce < confirmedNonce {
+		result, done = txResult{status: txReplaced}, true
+	}
+
+	if done {
+		m.mu.Lock()
+		delete(m.pending, nonce)
+		m.mu.Unlock()
+
+		logger.WithField("status", result.status).Info("transaction resolved")
+		if tracked.hooks.onFinal != nil {
+			tracked.hooks.onFinal(ctx, result)
+		}
+		return nil
+	}
+
+	if m.now().Sub(tracked.submittedAt) < m.stuckTimeout {
+		return nil
+	}
+
+	if tracked.hooks.private {
+		m.mu.Lock()
+		delete(m.pending, nonce)
+		m.mu.Unlock()
+
+		logger.Warn("privately relayed transaction didn't make it into a block in time, syncing nonces again")
+		m.nonces.resync()
+		return nil
+	}
+
+	if len(tracked.txs) > m.maxReplacements {
+		logger.Warn("transaction is stuck, but it has been replaced too many times already")
+		return nil
+	}
+
+	cancel := tracked.cancelling
+	if !cancel && tracked.hooks.stillNeeded != nil {
+		needed, err := tracked.hooks.stillNeeded(ctx)
+		if err != nil {
+			logger.WithError(err).Warn("couldn't check if stuck transaction is still needed")
+		} else {
+			cancel = !needed
+		}
+	}
+
+	replacement, err := m.replace(ctx, tracked.latest(), cancel)
+	if err != nil {
+		logger.WithError(err).Error("couldn't replace stuck transaction")
+		return nil
+	}
+
+	m.mu.Lock()
+	tracked.txs = append(tracked.txs, replacement)
+	tracked.submittedAt = m.now()
+	tracked.cancelling = cancel
+	m.mu.Unlock()
+
+	logger.WithFields(log.Fields{
+		"replacement-tx-hash": replacement.Hash(),
+		"cancel":              cancel,
+	}).Info("replaced stuck transaction")
+
+	return nil
+}
+
+// findResult looks for a receipt of the transaction or any of its
+// replacements.
+func (m *txManager) findResult(ctx context.Context, tracked *trackedTx) (txResult, bool, error) {
+	for i := len(tracked.txs) - 1; i >= 0; i-- {
+		tx := tracked.txs[i]
+		receipt, err := m.conn.TransactionReceipt(ctx, tx.Hash())
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return txResult{}, false, err
+		}
+
+		res := txResult{status: txMined, tx: tx, receipt: receipt}
+		switch {
+		case tracked.cancelling && tx.To() != nil && *tx.To() == m.addr && len(tx.Data()) == 0:
+			res.status = txCancelled
+		case receipt.Status == ethtypes.ReceiptStatusFailed:
+			res.status = txReverted
+		}
+
+		return res, true, nil
+	}
+
+	return txResult{}, false, nil
+}
+
+// replace sends a transaction with the same nonce as the given one and fees
+// which are high enough for nodes to accept it as its replacement. When
+// cancelling, the replacement is a zero value transfer to self.
+func (m *txManager) replace(ctx context.Context, tx *ethtypes.Transaction, cancel bool) (*ethtypes.Transaction, error) {
+	to, value, data, gas := tx.To(), tx.Value(), tx.Data(), tx.Gas()
+	if cancel {
+		to, value, data, gas = &m.addr, new(big.Int), nil, cancelTxGasLimit
+	}
+
+	// the replacement pays the bumped fees, or the current price if the
+	// market moved even more since
+	price, err := m.gas.price(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var inner ethtypes.TxData
+	var maxFee *big.Int
+	if tx.Type() == ethtypes.DynamicFeeTxType {
+		feeCap, tipCap := m.bump(tx.GasFeeCap()), m.bump(tx.GasTipCap())
+		if price.isDynamic() {
+			feeCap, tipCap = maxBig(feeCap, price.gasFeeCap), maxBig(tipCap, price.gasTipCap)
+		}
+		inner = &ethtypes.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: tipCap,
+			GasFeeCap: feeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		}
+		maxFee = feeCap
+	} else {
+		gasPrice := maxBig(m.bump(tx.GasPrice()), price.max())
+		inner = &ethtypes.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}
+		maxFee = gasPrice
+	}
+
+	if m.ceiling != nil && maxFee.Cmp(m.ceiling) > 0 {
+		return nil, ErrGasPriceAboveCeiling.Format(maxFee, m.ceiling)
+	}
+
+	signed, err := m.sign(ethtypes.NewTx(inner))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.conn.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	}
+
+	return signed, nil
+}
+
+func (m *txManager) bump(fee *big.Int) *big.Int {
+	res := new(big.Int).Mul(fee, big.NewInt(100+m.feeBumpPercent))
+	res.Div(res, big.NewInt(100))
+	// rounding down could leave a tiny fee where it was
+	if res.Cmp(fee) <= 0 {
+		res.Add(fee, big.NewInt(1))
+	}
+	return res
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,194 @@
+package evm
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxManager(t *testing.T) {
+	ctx := context.Background()
+	chainID := big.NewInt(5)
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	sign := func(tx *ethtypes.Transaction) (*ethtypes.Transaction, error) {
+		return ethtypes.SignTx(tx, ethtypes.LatestSignerForChainID(chainID), key)
+	}
+	contract := common.HexToAddress("0x12")
+
+	newTx := func(nonce uint64) *ethtypes.Transaction {
+		tx, err := sign(ethtypes.NewTx(&ethtypes.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			GasTipCap: big.NewInt(100),
+			GasFeeCap: big.NewInt(1000),
+			Gas:       100000,
+			To:        &contract,
+			Data:      []byte("payload"),
+		}))
+		require.NoError(t, err)
+		return tx
+	}
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	setup := func(t *testing.T) (*txManager, *mockEthClientConn) {
+		conn := newMockEthClientConn(t)
+		m := newTxManager(conn, addr, sign, suggestedGasStrategy{dynamic: true}, config.EVMSpecificClientConfig{StuckTxTimeout: time.Minute})
+		m.now = func() time.Time { return now }
+		return m, conn
+	}
+	track := func(m *txManager, tx *ethtypes.Transaction, needed bool) *txResult {
+		res := new(txResult)
+		m.track(tx, txHooks{
+			stillNeeded: func(context.Context) (bool, error) { return needed, nil },
+			onFinal:     func(_ context.Context, r txResult) { *res = r },
+		})
+		return res
+	}
+
+	t.Run("mined and reverted transactions are resolved", func(t *testing.T) {
+		m, conn := setup(t)
+		mined, reverted := newTx(1), newTx(2)
+		minedRes, revertedRes := track(m, mined, true), track(m, reverted, true)
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(3), nil)
+		conn.On("TransactionReceipt", mock.Anything, mined.Hash()).Return(&ethtypes.Receipt{Status: ethtypes.ReceiptStatusSuccessful}, nil)
+		conn.On("TransactionReceipt", mock.Anything, reverted.Hash()).Return(&ethtypes.Receipt{Status: ethtypes.ReceiptStatusFailed}, nil)
+
+		require.NoError(t, m.check(ctx))
+		require.Equal(t, txMined, minedRes.status)
+		require.Equal(t, txReverted, revertedRes.status)
+		require.Empty(t, m.pending)
+	})
+
+	t.Run("a nonce used up by an unknown transaction is reported as replaced", func(t *testing.T) {
+		m, conn := setup(t)
+		tx := newTx(1)
+		res := track(m, tx, true)
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(2), nil)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(nil, ethereum.NotFound)
+
+		require.NoError(t, m.check(ctx))
+		require.Equal(t, txReplaced, res.status)
+		require.Nil(t, res.tx)
+	})
+
+	t.Run("stuck transactions are replaced with bumped fees", func(t *testing.T) {
+		m, conn := setup(t)
+		tx := newTx(1)
+		res := track(m, tx, true)
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(1), nil)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(nil, ethereum.NotFound).Once()
+		conn.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(50), nil)
+		conn.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(50)}}, nil)
+		conn.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(50), nil)
+		var replacement *ethtypes.Transaction
+		conn.On("SendTransaction", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			replacement = args.Get(1).(*ethtypes.Transaction)
+		})
+
+		// not stuck yet
+		require.NoError(t, m.check(ctx))
+		conn.AssertNotCalled(t, "SendTransaction", mock.Anything, mock.Anything)
+
+		now = now.Add(time.Minute)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(nil, ethereum.NotFound)
+		require.NoError(t, m.check(ctx))
+		require.NotNil(t, replacement)
+		require.Equal(t, tx.Nonce(), replacement.Nonce())
+		require.Equal(t, tx.Data(), replacement.Data())
+		require.Equal(t, big.NewInt(120), replacement.GasTipCap())
+		require.Equal(t, big.NewInt(1200), replacement.GasFeeCap())
+
+		conn.On("TransactionReceipt", mock.Anything, replacement.Hash()).Return(&ethtypes.Receipt{Status: ethtypes.ReceiptStatusSuccessful}, nil)
+		require.NoError(t, m.check(ctx))
+		require.Equal(t, txMined, res.status)
+		require.Equal(t, replacement.Hash(), res.tx.Hash())
+	})
+
+	t.Run("stuck transactions which are no longer needed are cancelled", func(t *testing.T) {
+		m, conn := setup(t)
+		tx := newTx(1)
+		res := track(m, tx, false)
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(1), nil)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(nil, ethereum.NotFound)
+		conn.On("SuggestGasPrice", mock.Anything).Return(big.NewInt(50), nil)
+		conn.On("FeeHistory", mock.Anything, uint64(1), (*big.Int)(nil), []float64(nil)).Return(&ethereum.FeeHistory{BaseFee: []*big.Int{big.NewInt(50)}}, nil)
+		conn.On("SuggestGasTipCap", mock.Anything).Return(big.NewInt(50), nil)
+		var cancel *ethtypes.Transaction
+		conn.On("SendTransaction", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			cancel = args.Get(1).(*ethtypes.Transaction)
+		})
+
+		now = now.Add(time.Minute)
+		require.NoError(t, m.check(ctx))
+		require.NotNil(t, cancel)
+		require.Equal(t, addr, *cancel.To())
+		require.Empty(t, cancel.Data())
+		require.Zero(t, cancel.Value().Sign())
+
+		conn.On("TransactionReceipt", mock.Anything, cancel.Hash()).Return(&ethtypes.Receipt{Status: ethtypes.ReceiptStatusSuccessful}, nil)
+		require.NoError(t, m.check(ctx))
+		require.Equal(t, txCancelled, res.status)
+	})
+
+	t.Run("pending transactions can be looked up by what they were sent for", func(t *testing.T) {
+		m, conn := setup(t)
+		tx := newTx(1)
+		m.track(tx, txHooks{ref: "queue-name/7"})
+		require.True(t, m.has("queue-name/7"))
+		require.False(t, m.has("queue-name/8"))
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(2), nil)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(&ethtypes.Receipt{Status: ethtypes.ReceiptStatusSuccessful}, nil)
+		require.NoError(t, m.check(ctx))
+		require.False(t, m.has("queue-name/7"))
+	})
+
+	t.Run("rebuilt processors keep track of the transactions of the previous ones", func(t *testing.T) {
+		var managers txManagers
+		old, _ := setup(t)
+		old.trackedTxs = managers.get(chainID, addr)
+		old.track(newTx(1), txHooks{ref: "queue-name/7"})
+
+		rebuilt, _ := setup(t)
+		rebuilt.trackedTxs = managers.get(chainID, addr)
+		require.True(t, rebuilt.has("queue-name/7"))
+
+		other, _ := setup(t)
+		other.trackedTxs = managers.get(big.NewInt(6), addr)
+		require.False(t, other.has("queue-name/7"))
+	})
+
+	t.Run("privately relayed transactions which got dropped resync the nonces", func(t *testing.T) {
+		m, conn := setup(t)
+		m.nonces = &nonceManager{next: 3, synced: true}
+		tx := newTx(1)
+		m.track(tx, txHooks{private: true})
+
+		conn.On("NonceAt", mock.Anything, addr, (*big.Int)(nil)).Return(uint64(1), nil)
+		conn.On("TransactionReceipt", mock.Anything, tx.Hash()).Return(nil, ethereum.NotFound)
+
+		require.NoError(t, m.check(ctx))
+		require.True(t, m.nonces.synced)
+
+		now = now.Add(time.Minute)
+		require.NoError(t, m.check(ctx))
+		require.False(t, m.nonces.synced)
+		require.Empty(t, m.pending)
+		conn.AssertNotCalled(t, "SendTransaction", mock.Anything, mock.Anything)
+	})
+}
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.20.0. DO NOT EDIT.
+// Code generated by mockery v2.32.4. DO NOT EDIT.
 
 package mocks
 
@@ -279,13 +279,26 @@ func (_m *Processor) SupportedQueues() []string {
 	return r0
 }
 
-type mockConstructorTestingTNewProcessor interface {
-	mock.TestingT
-	Cleanup(func())
+// TrackTransactions provides a mock function with given fields: _a0
+func (_m *Processor) TrackTransactions(_a0 context.Context) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // NewProcessor creates a new instance of Processor. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
-func NewProcessor(t mockConstructorTestingTNewProcessor) *Processor {
+// The first argument is typically a *testing.T value.
+func NewProcessor(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *Processor {
 	mock := &Processor{}
 	mock.Mock.Test(t)
 
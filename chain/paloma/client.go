@@ -26,6 +26,11 @@ import (
 
 type ResultStatus = coretypes.ResultStatus
 
+const (
+	eventSubscriber           = "pigeon"
+	eventSubscriptionCapacity = 100
+)
+
 //go:generate mockery --name=MessageSender
 type MessageSender interface {
 	SendMsg(ctx context.Context, msg sdk.Msg, memo string) (*sdk.TxResponse, error)
@@ -296,6 +301,17 @@ func (c Client) QueryGetLastEventNonce(ctx context.Context, orchestrator string)
 	return res.EventNonce, nil
 }
 
+// QueryGetLastObservedEthBlock returns the height of the last gravity event
+// which was observed by Paloma, or zero if none was.
+func (c Client) QueryGetLastObservedEthBlock(ctx context.Context) (uint64, error) {
+	qc := gravity.NewQueryClient(c.GRPCClient)
+	res, err := qc.GetLastObservedEthBlock(ctx, &gravity.QueryLastObservedEthBlockRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return res.Block, nil
+}
+
 func (c Client) QueryBatchRequestByNonce(ctx context.Context, nonce uint64, contract string) (gravity.OutgoingTxBatch, error) {
 	qc := gravity.NewQueryClient(c.GRPCClient)
 	res, err := qc.BatchRequestByNonce(ctx, &gravity.QueryBatchRequestByNonceRequest{
@@ -569,6 +585,29 @@ func (c Client) PalomaStatus(ctx context.Context) error {
 	return nil
 }
 
+// SubscribeToEvents subscribes to CometBFT events matching the query using
+// Paloma's websocket endpoint. The websocket connection is started on the
+// first subscription.
+func (c Client) SubscribeToEvents(ctx context.Context, query string) (<-chan coretypes.ResultEvent, error) {
+	rpcClient := c.L.RPCClient
+	if !rpcClient.IsRunning() {
+		if err := rpcClient.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	return rpcClient.Subscribe(ctx, eventSubscriber, query, eventSubscriptionCapacity)
+}
+
+// UnsubscribeFromEvents removes all event subscriptions made by pigeon.
+func (c Client) UnsubscribeFromEvents(ctx context.Context) error {
+	if !c.L.RPCClient.IsRunning() {
+		return nil
+	}
+
+	return c.L.RPCClient.UnsubscribeAll(ctx, eventSubscriber)
+}
+
 func (c Client) GetValidator(ctx context.Context) (*stakingtypes.Validator, error) {
 	res, err := c.lensQuery().Staking_Validator(c.GetValidatorAddress().String())
 	if err != nil {
@@ -2,6 +2,7 @@ package paloma
 
 import (
 	"context"
+	"sync"
 
 	"github.com/VolumeFi/whoops"
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -14,6 +15,8 @@ var _ grpc.ClientConn = GRPCClientDowner{}
 
 var _ MessageSender = MessageSenderDowner{}
 
+var _ MessageSender = &MessageSenderSerializer{}
+
 type GRPCClientDowner struct {
 	W grpc.ClientConn
 }
@@ -22,6 +25,15 @@ type MessageSenderDowner struct {
 	W MessageSender
 }
 
+// MessageSenderSerializer makes sure that only a single message is being
+// broadcasted at a time. All messages are signed by the same account, so
+// sending them concurrently would result in account sequence mismatches.
+type MessageSenderSerializer struct {
+	W MessageSender
+
+	mu sync.Mutex
+}
+
 func (g GRPCClientDowner) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...ggrpc.CallOption) error {
 	err := g.W.Invoke(ctx, method, args, reply, opts...)
 	if IsPalomaDown(err) {
@@ -50,3 +62,10 @@ func (m MessageSenderDowner) SendMsg(ctx context.Context, msg sdk.Msg, memo stri
 
 	return res, err
 }
+
+func (m *MessageSenderSerializer) SendMsg(ctx context.Context, msg sdk.Msg, memo string) (*sdk.TxResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.W.SendMsg(ctx, msg, memo)
+}
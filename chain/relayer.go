@@ -2,6 +2,7 @@ package chain
 
 import (
 	"context"
+	"time"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	gravity "github.com/palomachain/paloma/x/gravity/types"
@@ -97,6 +98,21 @@ type SendToPalomaEvent struct {
 	TokenContract  string
 }
 
+// CompassVersion identifies a compass contract deployed on a chain.
+type CompassVersion struct {
+	SmartContractID string `json:"smart-contract-id"`
+	Address         string `json:"address"`
+}
+
+// CompassVersions are the compass contracts a processor relays to. Previous
+// is set while the processor hands over from the compass the chain was
+// upgraded from.
+type CompassVersions struct {
+	Active        CompassVersion
+	Previous      *CompassVersion
+	HandoverUntil time.Time
+}
+
 type ChainInfo interface {
 	ChainReferenceID() string
 	ChainID() string
@@ -136,6 +152,10 @@ type Processor interface {
 
 	GetBatchSendEvents(context.Context, string) ([]BatchSendEvent, error)
 	GetSendToPalomaEvents(context.Context, string) ([]SendToPalomaEvent, error)
+
+	// TrackTransactions follows the transactions sent to the chain until they
+	// make it into a block, replacing the ones which got stuck.
+	TrackTransactions(context.Context) error
 }
 
 type ProcessorBuilder interface {
@@ -39,6 +39,7 @@ var startCmd = &cobra.Command{
 				pid,
 				app.Version(),
 				app.Commit(),
+				app.Relayer(),
 			)
 		}()
 
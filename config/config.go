@@ -6,6 +6,7 @@ import (
 	"os/user"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/VolumeFi/whoops"
 	"gopkg.in/yaml.v2"
@@ -25,6 +26,80 @@ type CosmosSpecificClientConfig struct {
 type EVMSpecificClientConfig struct {
 	TxType                      uint8 `yaml:"tx-type"`
 	BloxrouteIntegrationEnabled bool  `yaml:"bloxroute-mev-enabled"`
+	// ParallelLogicCalls is the maximum number of SubmitLogicCall messages
+	// of a queue which are relayed at the same time. Defaults to one.
+	ParallelLogicCalls int `yaml:"parallel-logic-calls"`
+	// StuckTxTimeout is how long a transaction may stay pending before it
+	// gets replaced with higher fees. Defaults to three minutes.
+	StuckTxTimeout time.Duration `yaml:"stuck-tx-timeout"`
+	// FeeBumpPercent is by how much the fees of a replacement transaction
+	// are increased. Defaults to 20, and can't go below 10.
+	FeeBumpPercent int `yaml:"fee-bump-percent"`
+	// MaxTxReplacements is how many times a stuck transaction is replaced
+	// before pigeon gives up on it. Defaults to five.
+	MaxTxReplacements int `yaml:"max-tx-replacements"`
+	// RPCURLs are further RPC endpoints of the chain, used next to
+	// base-rpc-url. Reads are spread over the healthy endpoints, and calls
+	// fail over to the next endpoint when one stops responding.
+	RPCURLs []string `yaml:"rpc-urls"`
+	// RPCQuorum is the number of endpoints which must agree on the result
+	// of critical reads, such as the last valset ID of compass or the
+	// gravity events. Zero or one disables quorum reads.
+	RPCQuorum int `yaml:"rpc-quorum"`
+	// WebsocketURL is a ws:// or wss:// endpoint of the chain. When set,
+	// gravity events are received through log subscriptions instead of
+	// being polled for.
+	WebsocketURL string `yaml:"websocket-url"`
+	// ConfirmationDepth is the number of blocks which need to be built on
+	// top of a gravity event before it is claimed.
+	ConfirmationDepth uint64 `yaml:"confirmation-depth"`
+	// ConfirmationBlockTag is "safe" or "finalized" to only claim gravity
+	// events up to the block with that tag. Chains which don't support the
+	// tag fall back to the confirmation depth.
+	ConfirmationBlockTag string `yaml:"confirmation-block-tag"`
+	// CompassHandoverWindow is how long the previous compass of the chain
+	// is still relayed to and watched for gravity events after compass was
+	// upgraded. Defaults to one hour.
+	CompassHandoverWindow time.Duration `yaml:"compass-handover-window"`
+
+	GasPricing GasPricing `yaml:"gas-pricing"`
+
+	GravityProfitability GravityProfitability `yaml:"gravity-profitability"`
+}
+
+// GravityProfitability configures the check whether a gravity batch is
+// worth relaying. The fees a batch pays are valued with the token prices,
+// and compared with what relaying it costs at the current gas price.
+type GravityProfitability struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxLoss is the most, in wei, relaying a batch may cost above the
+	// value of its fees. Batches which would lose more are deferred.
+	MaxLoss uint64 `yaml:"max-loss"`
+	// TokenPrices are the prices, in wei, of one base unit of the tokens
+	// keyed by their contract address. Prices are decimal numbers.
+	TokenPrices map[string]string `yaml:"token-prices"`
+}
+
+// GasPricing configures how the transactions sent to a chain are priced. All
+// prices are in wei.
+type GasPricing struct {
+	// Strategy is one of "suggested", "fee-history" or "fixed". Defaults
+	// to "suggested", which uses the gas price suggested by the node.
+	Strategy string `yaml:"strategy"`
+	// FeeHistoryBlocks and FeeHistoryPercentile pick the recent blocks and
+	// the percentile of their priority fees the "fee-history" strategy
+	// bases the tip on. Default to 10 blocks and the 50th percentile.
+	FeeHistoryBlocks     int     `yaml:"fee-history-blocks"`
+	FeeHistoryPercentile float64 `yaml:"fee-history-percentile"`
+	// GasPrice, MaxFeePerGas and MaxPriorityFeePerGas are used by the
+	// "fixed" strategy. GasPrice is used for legacy transactions and falls
+	// back to MaxFeePerGas.
+	GasPrice             uint64 `yaml:"gas-price"`
+	MaxFeePerGas         uint64 `yaml:"max-fee-per-gas"`
+	MaxPriorityFeePerGas uint64 `yaml:"max-priority-fee-per-gas"`
+	// MaxGasPrice is the ceiling above which pigeon refuses to send
+	// transactions, whatever the strategy. Zero means no ceiling.
+	MaxGasPrice uint64 `yaml:"max-gas-price"`
 }
 
 type ChainClientConfig struct {
@@ -50,9 +125,16 @@ type Root struct {
 
 	BloxrouteAuthorizationHeader string `yaml:"bloxroute-auth-header"`
 
+	// DataDir is where pigeon keeps its state across restarts, such as
+	// how far it has scanned the chains for gravity events and the index
+	// of the events compass emitted. Defaults to ~/.pigeon/data.
+	DataDir Filepath `yaml:"data-dir"`
+
 	Paloma Paloma `yaml:"paloma"`
 
 	EVM map[string]EVM `yaml:"evm"`
+
+	Relayer Relayer `yaml:"relayer"`
 }
 
 func (r *Root) HealthCheckPort() int {
@@ -63,6 +145,9 @@ func (r *Root) HealthCheckPort() int {
 }
 
 func (r *Root) init() {
+	if r.DataDir == "" {
+		r.DataDir = "~/.pigeon/data"
+	}
 	(&r.Paloma).init()
 }
 
@@ -75,11 +160,63 @@ type Paloma struct {
 	CosmosSpecificClientConfig `yaml:",inline"`
 	ChainClientConfig          `yaml:",inline"`
 	ChainID                    string `yaml:"chain-id"`
+
+	// SubscribeToEvents enables event driven relaying. Instead of polling
+	// the consensus queues, pigeon waits for Paloma blocks which changed
+	// them. It falls back to polling whenever the subscription drops.
+	SubscribeToEvents bool `yaml:"subscribe-to-events"`
+	// EventsMaxIdle is how long the event driven loops may go without
+	// running while the queues look unchanged. Defaults to 30 seconds.
+	EventsMaxIdle time.Duration `yaml:"events-max-idle"`
 }
 
 func (p *Paloma) init() {
 }
 
+// Relayer configures the relayer's process loops. Loops which are not
+// configured run with their default settings.
+type Relayer struct {
+	KeepAliveBlockThreshold int64           `yaml:"keep-alive-block-threshold"`
+	Loops                   map[string]Loop `yaml:"loops"`
+	CircuitBreaker          CircuitBreaker  `yaml:"circuit-breaker"`
+	Scheduling              Scheduling      `yaml:"scheduling"`
+}
+
+type Loop struct {
+	// Interval is the time between two iterations of the loop.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout is the deadline for a single iteration of the loop. Zero
+	// means that an iteration can run for as long as it needs.
+	Timeout  time.Duration `yaml:"timeout"`
+	Disabled bool          `yaml:"disabled"`
+}
+
+// CircuitBreaker configures how chain loops back off after failures. Zero
+// values fall back to the defaults.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures after which
+	// a loop is skipped for the open timeout.
+	FailureThreshold int           `yaml:"failure-threshold"`
+	OpenTimeout      time.Duration `yaml:"open-timeout"`
+	// MaxBackoff caps the delay between retries of a failing loop.
+	MaxBackoff time.Duration `yaml:"max-backoff"`
+}
+
+// Scheduling configures how pigeon shares the relaying of messages with
+// the other validators.
+type Scheduling struct {
+	// Strategy is one of "sequential", "random" or "assigned". Defaults
+	// to "random". The "assigned" strategy only lets a few validators,
+	// picked deterministically for each message, relay it at first.
+	Strategy string `yaml:"strategy"`
+	// RelayersPerMessage is the number of validators which may relay a
+	// message right away when using the "assigned" strategy.
+	RelayersPerMessage int `yaml:"relayers-per-message"`
+	// FallbackTimeout is how long the assigned validators get to relay a
+	// message before the next ones in line are allowed to relay it.
+	FallbackTimeout time.Duration `yaml:"fallback-timeout"`
+}
+
 func KeyringPassword(envKey string) string {
 	envVal, ok := os.LookupEnv(envKey)
 	if !ok {
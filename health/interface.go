@@ -9,3 +9,9 @@ type Checker interface {
 type BootChecker interface {
 	BootHealthCheck(ctx context.Context) error
 }
+
+// StateReporter provides details about the state of pigeon, which are included
+// in the health check server's response.
+type StateReporter interface {
+	HealthReport() any
+}
@@ -16,6 +16,7 @@ type jsonResponse struct {
 	Pid     int    `json:"pid"`
 	Version string `json:"version"`
 	Commit  string `json:"commit"`
+	Relayer any    `json:"relayer,omitempty"`
 }
 
 func StartHTTPServer(
@@ -24,15 +25,20 @@ func StartHTTPServer(
 	pid int,
 	appVersion string,
 	commit string,
+	reporter StateReporter,
 ) {
 	m := http.NewServeMux()
 	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		pid := os.Getpid()
-		if err := json.NewEncoder(w).Encode(jsonResponse{
+		res := jsonResponse{
 			Pid:     pid,
 			Version: appVersion,
 			Commit:  commit,
-		}); err != nil {
+		}
+		if reporter != nil {
+			res.Relayer = reporter.HealthReport()
+		}
+		if err := json.NewEncoder(w).Encode(res); err != nil {
 			log.WithError(err).Error("responding to health-check")
 		}
 	})
@@ -0,0 +1,61 @@
+// Package store keeps pigeon's state across restarts in an embedded
+// key-value database.
+package store
+
+import (
+	"errors"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type Store struct {
+	db *leveldb.DB
+}
+
+// Open opens the store in the given directory, creating it if needed.
+func Open(dir string) (*Store, error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the value stored under the key, and false if there is none.
+func (s *Store) Get(key []byte) ([]byte, bool, error) {
+	value, err := s.db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+func (s *Store) Put(key, value []byte) error {
+	return s.db.Put(key, value, nil)
+}
+
+// Iterate calls fn with the keys from start up to, but excluding, limit in
+// order, together with their values. It stops at the first error fn
+// returns. The slices passed to fn are only valid until it returns.
+func (s *Store) Iterate(start, limit []byte, fn func(key, value []byte) error) error {
+	it := s.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	require.NoError(t, err)
+
+	_, found, err := s.Get([]byte("key"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.Put([]byte("key"), []byte("value")))
+	require.NoError(t, s.Close())
+
+	// values survive reopening the store
+	s, err = Open(dir)
+	require.NoError(t, err)
+	defer s.Close()
+
+	value, found, err := s.Get([]byte("key"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("value"), value)
+}
+
+func TestIterate(t *testing.T) {
+	s, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, key := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		require.NoError(t, s.Put([]byte(key), []byte("value of "+key)))
+	}
+
+	var keys []string
+	err = s.Iterate([]byte("a/2"), []byte("b"), func(key, value []byte) error {
+		require.Equal(t, "value of "+string(key), string(value))
+		keys = append(keys, string(key))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a/2", "a/3"}, keys)
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	evmtypes "github.com/palomachain/paloma/x/evm/types"
 	"github.com/palomachain/pigeon/chain"
-	"github.com/palomachain/pigeon/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -24,58 +23,128 @@ func (r *Relayer) buildProcessors(ctx context.Context, locker sync.Locker) error
 
 	logger.WithField("chains-infos", queriedChainsInfos).Trace("got chain infos")
 
-	// See if we need to update
-	if (r.processors != nil) && (r.chainsInfos != nil) && (len(r.chainsInfos) == len(queriedChainsInfos)) {
-		chainsChanged := false
-		for k, c := range r.chainsInfos {
-			if c.Id != queriedChainsInfos[k].Id ||
-				c.ChainReferenceID != queriedChainsInfos[k].ChainReferenceID ||
-				c.ChainID != queriedChainsInfos[k].ChainID ||
-				string(c.SmartContractUniqueID) != string(queriedChainsInfos[k].SmartContractUniqueID) ||
-				c.SmartContractAddr != queriedChainsInfos[k].SmartContractAddr ||
-				c.ReferenceBlockHeight != queriedChainsInfos[k].ReferenceBlockHeight ||
-				c.ReferenceBlockHash != queriedChainsInfos[k].ReferenceBlockHash ||
-				c.Abi != queriedChainsInfos[k].Abi ||
-				string(c.Bytecode) != string(queriedChainsInfos[k].Bytecode) ||
-				string(c.ConstructorInput) != string(queriedChainsInfos[k].ConstructorInput) ||
-				c.Status != queriedChainsInfos[k].Status ||
-				c.ActiveSmartContractID != queriedChainsInfos[k].ActiveSmartContractID ||
-				c.MinOnChainBalance != queriedChainsInfos[k].MinOnChainBalance {
-				chainsChanged = true
-			}
-		}
-		if !chainsChanged {
-			logger.Debug("chain infos unchanged since last tick")
-			return nil
-		}
-	}
-
-	logger.Debug("chain infos changed.  building processors")
+	// Processors of chains whose chain info hasn't changed are kept, so
+	// that they don't have to redial their RPCs and keep their state.
+	existing := r.existingProcessors()
 
-	r.processors = []chain.Processor{}
-	r.chainsInfos = []evmtypes.ChainInfo{}
+	processors := make([]chain.Processor, 0, len(queriedChainsInfos))
+	chainsInfos := make([]evmtypes.ChainInfo, 0, len(queriedChainsInfos))
+	chainReferenceIDs := make([]string, 0, len(queriedChainsInfos))
+	built := false
 	for _, chainInfo := range queriedChainsInfos {
-		logger = logger.WithFields(log.Fields{
+		chainReferenceIDs = append(chainReferenceIDs, chainInfo.GetChainReferenceID())
+		logger := logger.WithFields(log.Fields{
 			"chain-reference-id": chainInfo.GetChainReferenceID(),
 		})
-		processor, err := r.processorFactory(chainInfo)
-		if errors.IsUnrecoverable(err) {
-			logger.WithError(err).Error("unable to build processor")
-			return err
+
+		if e, ok := existing[chainInfo.GetChainReferenceID()]; ok && chainInfoEqual(e.chainInfo, *chainInfo) {
+			processors = append(processors, e.processor)
+			chainsInfos = append(chainsInfos, *chainInfo)
+			continue
+		}
+
+		if !r.quarantine.retry(*chainInfo) {
+			logger.Trace("chain is quarantined. backing off")
+			continue
+		}
+
+		logger.Debug("chain info changed. building processor")
+		processor, err := r.buildProcessor(ctx, chainInfo)
+		if err != nil {
+			// a broken chain must not keep the healthy ones from relaying
+			if r.quarantine.add(*chainInfo, err) {
+				logger.WithError(err).Error("unable to build processor. chain is quarantined")
+			}
+			continue
 		}
 
-		if err := processor.IsRightChain(ctx); err != nil {
-			logger.WithError(err).Error("incorrect chain")
-			return err
+		if r.quarantine.remove(chainInfo.GetChainReferenceID()) {
+			logger.Info("chain was released from quarantine")
 		}
 
-		r.processors = append(r.processors, processor)
-		r.chainsInfos = append(r.chainsInfos, *chainInfo)
+		built = true
+		processors = append(processors, processor)
+		chainsInfos = append(chainsInfos, *chainInfo)
 	}
+	r.quarantine.retain(chainReferenceIDs)
+
+	if !built && len(processors) == len(existing) {
+		logger.Debug("chain infos unchanged since last tick")
+		return nil
+	}
+
+	r.processors.Set(processors)
+	r.chainsInfos = chainsInfos
 
 	return nil
 }
 
+type existingProcessor struct {
+	chainInfo evmtypes.ChainInfo
+	processor chain.Processor
+}
+
+// existingProcessors returns the current processors keyed by their chain
+// reference ID, together with the chain infos they were built from.
+func (r *Relayer) existingProcessors() map[string]existingProcessor {
+	processors := r.processors.List()
+	res := make(map[string]existingProcessor, len(processors))
+	if len(processors) != len(r.chainsInfos) {
+		// the processors are out of sync with the chain infos, so none
+		// of them can be reused
+		return res
+	}
+
+	for i, chainInfo := range r.chainsInfos {
+		res[chainInfo.GetChainReferenceID()] = existingProcessor{
+			chainInfo: chainInfo,
+			processor: processors[i],
+		}
+	}
+
+	return res
+}
+
+func chainInfoEqual(a, b evmtypes.ChainInfo) bool {
+	return a.Id == b.Id &&
+		a.ChainReferenceID == b.ChainReferenceID &&
+		a.ChainID == b.ChainID &&
+		string(a.SmartContractUniqueID) == string(b.SmartContractUniqueID) &&
+		a.SmartContractAddr == b.SmartContractAddr &&
+		a.ReferenceBlockHeight == b.ReferenceBlockHeight &&
+		a.ReferenceBlockHash == b.ReferenceBlockHash &&
+		a.Abi == b.Abi &&
+		string(a.Bytecode) == string(b.Bytecode) &&
+		string(a.ConstructorInput) == string(b.ConstructorInput) &&
+		a.Status == b.Status &&
+		a.ActiveSmartContractID == b.ActiveSmartContractID &&
+		a.MinOnChainBalance == b.MinOnChainBalance &&
+		relayWeightsEqual(a.RelayWeights, b.RelayWeights)
+}
+
+func relayWeightsEqual(a, b *evmtypes.RelayWeights) bool {
+	return a.GetFee() == b.GetFee() &&
+		a.GetUptime() == b.GetUptime() &&
+		a.GetSuccessRate() == b.GetSuccessRate() &&
+		a.GetExecutionTime() == b.GetExecutionTime() &&
+		a.GetFeatureSet() == b.GetFeatureSet()
+}
+
+// buildProcessor builds the processor for the chain and makes sure that it's
+// connected to the right chain.
+func (r *Relayer) buildProcessor(ctx context.Context, chainInfo *evmtypes.ChainInfo) (chain.Processor, error) {
+	processor, err := r.processorFactory(chainInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := processor.IsRightChain(ctx); err != nil {
+		return nil, whoops.Wrap(err, ErrIncorrectChain)
+	}
+
+	return processor, nil
+}
+
 func (r *Relayer) processorFactory(chainInfo *evmtypes.ChainInfo) (chain.Processor, error) {
 	// TODO: add support of other types of chains! Right now, only EVM types are supported!
 	retErr := whoops.Wrap(ErrMissingChainConfig, whoops.Errorf("reference chain id: %s").Format(chainInfo.GetChainReferenceID()))
@@ -2,7 +2,9 @@ package relayer
 
 import (
 	"context"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/palomachain/paloma/x/evm/types"
 	"github.com/palomachain/pigeon/chain"
@@ -108,9 +110,9 @@ func TestBuildProcessors(t *testing.T) {
 					timemocks.NewTime(t),
 					Config{},
 				)
-				r.processors = []chain.Processor{
+				r.processors.Set([]chain.Processor{
 					chainmocks.NewProcessor(t),
-				}
+				})
 
 				return r,
 					[]chain.Processor{
@@ -164,9 +166,9 @@ func TestBuildProcessors(t *testing.T) {
 					timemocks.NewTime(t),
 					Config{},
 				)
-				r.processors = []chain.Processor{
+				r.processors.Set([]chain.Processor{
 					chainmocks.NewProcessor(t),
-				}
+				})
 
 				r.chainsInfos = []types.ChainInfo{
 					chain1Info,
@@ -225,9 +227,9 @@ func TestBuildProcessors(t *testing.T) {
 					timemocks.NewTime(t),
 					Config{},
 				)
-				r.processors = []chain.Processor{
+				r.processors.Set([]chain.Processor{
 					chainmocks.NewProcessor(t),
-				}
+				})
 
 				r.chainsInfos = []types.ChainInfo{
 					chain1Info,
@@ -242,6 +244,86 @@ func TestBuildProcessors(t *testing.T) {
 					}
 			},
 		},
+		{
+			name: "only the processors of changed chains are rebuilt",
+			setup: func(t *testing.T) (*Relayer, []chain.Processor, []types.ChainInfo) {
+				chain1Info := types.ChainInfo{
+					Id:                1,
+					ChainReferenceID:  "chain-1",
+					MinOnChainBalance: "5",
+				}
+				chain2Info := types.ChainInfo{
+					Id:                2,
+					ChainReferenceID:  "chain-2",
+					MinOnChainBalance: "5",
+				}
+				chain2NewInfo := types.ChainInfo{
+					Id:                2,
+					ChainReferenceID:  "chain-2",
+					MinOnChainBalance: "50",
+				}
+				chain3Info := types.ChainInfo{
+					Id:                3,
+					ChainReferenceID:  "chain-3",
+					MinOnChainBalance: "5",
+				}
+
+				pc := mocks.NewPalomaClienter(t)
+				pc.On(
+					"QueryGetEVMChainInfos",
+					mock.Anything,
+					mock.Anything,
+				).Return(
+					[]*types.ChainInfo{
+						&chain1Info,
+						&chain2NewInfo,
+					},
+					nil,
+				)
+
+				processorMock := chainmocks.NewProcessor(t)
+				processorMock.On("IsRightChain", mock.Anything).Return(nil)
+
+				evmFactoryMock := mocks.NewEvmFactorier(t)
+				evmFactoryMock.On("Build", mock.Anything, "chain-2", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(processorMock, nil).Once()
+
+				r := New(
+					config.Root{
+						EVM: map[string]config.EVM{
+							"chain-1": {},
+							"chain-2": {},
+						},
+					},
+					pc,
+					evmFactoryMock,
+					timemocks.NewTime(t),
+					Config{},
+				)
+
+				chain1Processor := chainmocks.NewProcessor(t)
+				chain1Processor.On("GetChainReferenceID").Return("chain-1").Maybe()
+				r.processors.Set([]chain.Processor{
+					chain1Processor,
+					chainmocks.NewProcessor(t),
+					chainmocks.NewProcessor(t),
+				})
+				r.chainsInfos = []types.ChainInfo{
+					chain1Info,
+					chain2Info,
+					chain3Info,
+				}
+
+				return r,
+					[]chain.Processor{
+						chain1Processor,
+						processorMock,
+					},
+					[]types.ChainInfo{
+						chain1Info,
+						chain2NewInfo,
+					}
+			},
+		},
 		{
 			name: "when the chains are the same it doesn't build processors",
 			setup: func(t *testing.T) (*Relayer, []chain.Processor, []types.ChainInfo) {
@@ -276,9 +358,9 @@ func TestBuildProcessors(t *testing.T) {
 				)
 
 				origProcessor := chainmocks.NewProcessor(t)
-				r.processors = []chain.Processor{
+				r.processors.Set([]chain.Processor{
 					origProcessor,
-				}
+				})
 
 				r.chainsInfos = []types.ChainInfo{
 					chain1Info,
@@ -304,8 +386,131 @@ func TestBuildProcessors(t *testing.T) {
 
 			actualErr := relayer.buildProcessors(ctx, locker)
 			asserter.Equal(tt.expectedErr, actualErr)
-			asserter.Equal(expectedProcessors, relayer.processors)
+			asserter.Equal(expectedProcessors, relayer.processors.List())
 			asserter.Equal(expectedChainsInfos, relayer.chainsInfos)
 		})
 	}
 }
+
+func TestBuildProcessorsQuarantinesBrokenChains(t *testing.T) {
+	ctx := context.Background()
+	healthyInfo := types.ChainInfo{
+		Id:                1,
+		ChainReferenceID:  "healthy",
+		MinOnChainBalance: "5",
+	}
+	unconfiguredInfo := types.ChainInfo{
+		Id:                2,
+		ChainReferenceID:  "unconfigured",
+		MinOnChainBalance: "5",
+	}
+	wrongChainInfo := types.ChainInfo{
+		Id:                3,
+		ChainReferenceID:  "wrong-chain",
+		MinOnChainBalance: "5",
+	}
+
+	pc := mocks.NewPalomaClienter(t)
+	pc.On("QueryGetEVMChainInfos", mock.Anything, mock.Anything).Return(
+		[]*types.ChainInfo{&healthyInfo, &unconfiguredInfo, &wrongChainInfo},
+		nil,
+	).Once()
+
+	healthy := chainmocks.NewProcessor(t)
+	healthy.On("IsRightChain", mock.Anything).Return(nil)
+	wrongChain := chainmocks.NewProcessor(t)
+	wrongChain.On("IsRightChain", mock.Anything).Return(chain.ErrNotConnectedToRightChain)
+
+	evmFactoryMock := mocks.NewEvmFactorier(t)
+	evmFactoryMock.On("Build", mock.Anything, "healthy", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(healthy, nil)
+	evmFactoryMock.On("Build", mock.Anything, "wrong-chain", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(wrongChain, nil).Once()
+
+	r := New(
+		config.Root{
+			EVM: map[string]config.EVM{
+				"healthy":     {},
+				"wrong-chain": {},
+			},
+		},
+		pc,
+		evmFactoryMock,
+		timemocks.NewTime(t),
+		Config{},
+	)
+
+	now := time.Now()
+	r.quarantine.now = func() time.Time { return now }
+
+	var locker testutil.FakeMutex
+	assert.NoError(t, r.buildProcessors(ctx, locker))
+	assert.Equal(t, []chain.Processor{healthy}, r.processors.List())
+	assert.Equal(t, []types.ChainInfo{healthyInfo}, r.chainsInfos)
+	assert.Equal(t, []string{"unconfigured", "wrong-chain"}, r.quarantine.ChainReferenceIDs())
+
+	t.Run("quarantined chains are rebuilt with a backoff", func(t *testing.T) {
+		pc.On("QueryGetEVMChainInfos", mock.Anything, mock.Anything).Return(
+			[]*types.ChainInfo{&healthyInfo, &unconfiguredInfo, &wrongChainInfo},
+			nil,
+		).Twice()
+
+		// the wrong chain isn't built again before its backoff passed
+		assert.NoError(t, r.buildProcessors(ctx, locker))
+		evmFactoryMock.AssertNumberOfCalls(t, "Build", 2)
+
+		now = now.Add(time.Hour)
+		evmFactoryMock.On("Build", mock.Anything, "wrong-chain", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(wrongChain, nil).Once()
+		assert.NoError(t, r.buildProcessors(ctx, locker))
+		evmFactoryMock.AssertNumberOfCalls(t, "Build", 3)
+		assert.Equal(t, []string{"unconfigured", "wrong-chain"}, r.quarantine.ChainReferenceIDs())
+	})
+
+	t.Run("quarantined chains are rebuilt right away when their chain info changes", func(t *testing.T) {
+		fixedInfo := wrongChainInfo
+		fixedInfo.ChainID = 5
+		rightChain := chainmocks.NewProcessor(t)
+		rightChain.On("IsRightChain", mock.Anything).Return(nil)
+		evmFactoryMock.On("Build", mock.Anything, "wrong-chain", mock.Anything, mock.Anything, mock.Anything, big.NewInt(5), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(rightChain, nil).Once()
+		pc.On("QueryGetEVMChainInfos", mock.Anything, mock.Anything).Return(
+			[]*types.ChainInfo{&healthyInfo, &unconfiguredInfo, &fixedInfo},
+			nil,
+		).Once()
+
+		assert.NoError(t, r.buildProcessors(ctx, locker))
+		assert.Equal(t, []chain.Processor{healthy, rightChain}, r.processors.List())
+		assert.Equal(t, []string{"unconfigured"}, r.quarantine.ChainReferenceIDs())
+	})
+
+	t.Run("a change of the relay weights rebuilds the processor", func(t *testing.T) {
+		healthyBuilds := func() int {
+			n := 0
+			for _, c := range evmFactoryMock.Calls {
+				if c.Method == "Build" && c.Arguments[1] == "healthy" {
+					n++
+				}
+			}
+			return n
+		}
+		weightedInfo := healthyInfo
+		weightedInfo.RelayWeights = &types.RelayWeights{Fee: "0.5"}
+		pc.On("QueryGetEVMChainInfos", mock.Anything, mock.Anything).Return(
+			[]*types.ChainInfo{&weightedInfo},
+			nil,
+		).Once()
+
+		before := healthyBuilds()
+		assert.NoError(t, r.buildProcessors(ctx, locker))
+		assert.Equal(t, before+1, healthyBuilds())
+		assert.Equal(t, []types.ChainInfo{weightedInfo}, r.chainsInfos)
+	})
+
+	t.Run("chains which are gone from paloma are released", func(t *testing.T) {
+		pc.On("QueryGetEVMChainInfos", mock.Anything, mock.Anything).Return(
+			[]*types.ChainInfo{&healthyInfo},
+			nil,
+		).Once()
+
+		assert.NoError(t, r.buildProcessors(ctx, locker))
+		assert.Equal(t, []chain.Processor{healthy}, r.processors.List())
+		assert.Empty(t, r.quarantine.ChainReferenceIDs())
+	})
+}
@@ -0,0 +1,234 @@
+package relayer
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/palomachain/pigeon/config"
+)
+
+const (
+	defaultCircuitBreakerFailureThreshold = 5
+	defaultCircuitBreakerOpenTimeout      = time.Minute
+	defaultCircuitBreakerMaxBackoff       = 30 * time.Second
+)
+
+type circuitState string
+
+const (
+	// circuitClosed lets every iteration through, although failing
+	// iterations are retried with an exponential backoff.
+	circuitClosed circuitState = "closed"
+	// circuitOpen skips all iterations until the open timeout has passed.
+	circuitOpen circuitState = "open"
+	// circuitHalfOpen lets a single iteration through to probe whether the
+	// chain has recovered.
+	circuitHalfOpen circuitState = "half-open"
+)
+
+type circuitBreakerConfig struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	maxBackoff       time.Duration
+}
+
+func newCircuitBreakerConfig(cfg config.CircuitBreaker) circuitBreakerConfig {
+	c := circuitBreakerConfig{
+		failureThreshold: cfg.FailureThreshold,
+		openTimeout:      cfg.OpenTimeout,
+		maxBackoff:       cfg.MaxBackoff,
+	}
+	if c.failureThreshold <= 0 {
+		c.failureThreshold = defaultCircuitBreakerFailureThreshold
+	}
+	if c.openTimeout <= 0 {
+		c.openTimeout = defaultCircuitBreakerOpenTimeout
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = defaultCircuitBreakerMaxBackoff
+	}
+
+	return c
+}
+
+// circuitBreaker keeps a failing loop from hammering a chain. Every failure
+// pushes the next attempt further out, and after too many consecutive
+// failures the circuit opens and the loop is skipped entirely for a while.
+type circuitBreaker struct {
+	mu  sync.Mutex
+	cfg circuitBreakerConfig
+	// base is the backoff after the first failure, which is the loop's
+	// interval.
+	base time.Duration
+	now  func() time.Time
+
+	state    circuitState
+	failures int
+	lastErr  error
+	retryAt  time.Time
+}
+
+func newCircuitBreaker(cfg circuitBreakerConfig, base time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		cfg:   cfg,
+		base:  base,
+		now:   time.Now,
+		state: circuitClosed,
+	}
+}
+
+// allow reports whether the next iteration may run.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	if now.Before(cb.retryAt) {
+		return false
+	}
+
+	if cb.state == circuitOpen {
+		cb.state = circuitHalfOpen
+	}
+
+	return true
+}
+
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state = circuitClosed
+	cb.failures = 0
+	cb.lastErr = nil
+	cb.retryAt = time.Time{}
+}
+
+// failure records a failed iteration and returns true if it caused the
+// circuit to open.
+func (cb *circuitBreaker) failure(err error) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.lastErr = err
+	now := cb.now()
+
+	if cb.state == circuitHalfOpen || cb.failures >= cb.cfg.failureThreshold {
+		opened := cb.state != circuitOpen
+		cb.state = circuitOpen
+		cb.retryAt = now.Add(cb.cfg.openTimeout)
+		return opened
+	}
+
+	cb.retryAt = now.Add(cb.backoff())
+	return false
+}
+
+// backoff doubles with every consecutive failure and is capped at the
+// configured maximum. Half of it is jittered, so that loops of many chains
+// failing at the same time don't retry in lockstep.
+func (cb *circuitBreaker) backoff() time.Duration {
+	d := cb.cfg.maxBackoff
+	if shift := cb.failures - 1; shift < 32 {
+		if exp := cb.base << shift; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+
+	//nolint:gosec // jitter doesn't need a secure source of randomness
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func (cb *circuitBreaker) report() CircuitBreakerReport {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	r := CircuitBreakerReport{
+		State:               string(cb.state),
+		ConsecutiveFailures: cb.failures,
+	}
+	if cb.lastErr != nil {
+		r.LastError = cb.lastErr.Error()
+	}
+	if !cb.retryAt.IsZero() {
+		retryAt := cb.retryAt
+		r.RetryAt = &retryAt
+	}
+
+	return r
+}
+
+type circuitBreakerKey struct {
+	loop             string
+	chainReferenceID string
+}
+
+// circuitBreakers holds a circuit breaker for every loop of every chain.
+type circuitBreakers struct {
+	mu       sync.Mutex
+	cfg      circuitBreakerConfig
+	breakers map[circuitBreakerKey]*circuitBreaker
+}
+
+func newCircuitBreakers(cfg circuitBreakerConfig) *circuitBreakers {
+	return &circuitBreakers{
+		cfg:      cfg,
+		breakers: make(map[circuitBreakerKey]*circuitBreaker),
+	}
+}
+
+func (cbs *circuitBreakers) get(loop loopConfig, chainReferenceID string) *circuitBreaker {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	key := circuitBreakerKey{loop: loop.name, chainReferenceID: chainReferenceID}
+	cb, ok := cbs.breakers[key]
+	if !ok {
+		cb = newCircuitBreaker(cbs.cfg, loop.interval)
+		cbs.breakers[key] = cb
+	}
+
+	return cb
+}
+
+// remove drops all circuit breakers of a chain.
+func (cbs *circuitBreakers) remove(chainReferenceID string) {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	for key := range cbs.breakers {
+		if key.chainReferenceID == chainReferenceID {
+			delete(cbs.breakers, key)
+		}
+	}
+}
+
+func (cbs *circuitBreakers) report() []CircuitBreakerReport {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+
+	res := make([]CircuitBreakerReport, 0, len(cbs.breakers))
+	for key, cb := range cbs.breakers {
+		r := cb.report()
+		r.Loop = key.loop
+		r.ChainReferenceID = key.chainReferenceID
+		res = append(res, r)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].ChainReferenceID != res[j].ChainReferenceID {
+			return res[i].ChainReferenceID < res[j].ChainReferenceID
+		}
+		return res[i].Loop < res[j].Loop
+	})
+
+	return res
+}
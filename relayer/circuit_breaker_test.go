@@ -0,0 +1,96 @@
+package relayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VolumeFi/whoops"
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	errRPC := whoops.String("rpc is down")
+
+	newBreaker := func() *circuitBreaker {
+		cb := newCircuitBreaker(newCircuitBreakerConfig(config.CircuitBreaker{
+			FailureThreshold: 3,
+			OpenTimeout:      time.Minute,
+			MaxBackoff:       10 * time.Second,
+		}), time.Second)
+		cb.now = func() time.Time { return now }
+		return cb
+	}
+
+	t.Run("failures back off exponentially", func(t *testing.T) {
+		cb := newBreaker()
+		require.True(t, cb.allow())
+
+		require.False(t, cb.failure(errRPC))
+		require.Equal(t, circuitClosed, cb.state)
+		backoff := cb.retryAt.Sub(now)
+		require.GreaterOrEqual(t, backoff, 500*time.Millisecond)
+		require.LessOrEqual(t, backoff, time.Second)
+		require.False(t, cb.allow())
+
+		require.False(t, cb.failure(errRPC))
+		backoff = cb.retryAt.Sub(now)
+		require.GreaterOrEqual(t, backoff, time.Second)
+		require.LessOrEqual(t, backoff, 2*time.Second)
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		cb := newBreaker()
+		cb.failures = 40
+		backoff := cb.backoff()
+		require.GreaterOrEqual(t, backoff, 5*time.Second)
+		require.LessOrEqual(t, backoff, 10*time.Second)
+	})
+
+	t.Run("circuit opens after too many failures and recovers", func(t *testing.T) {
+		cb := newBreaker()
+		require.False(t, cb.failure(errRPC))
+		require.False(t, cb.failure(errRPC))
+		require.True(t, cb.failure(errRPC))
+		require.Equal(t, circuitOpen, cb.state)
+		require.Equal(t, now.Add(time.Minute), cb.retryAt)
+
+		now = now.Add(30 * time.Second)
+		require.False(t, cb.allow())
+
+		now = now.Add(30 * time.Second)
+		require.True(t, cb.allow())
+		require.Equal(t, circuitHalfOpen, cb.state)
+
+		// the probe failed, so the circuit opens again right away
+		require.True(t, cb.failure(errRPC))
+		require.Equal(t, circuitOpen, cb.state)
+
+		now = now.Add(time.Minute)
+		require.True(t, cb.allow())
+		cb.success()
+		require.Equal(t, circuitClosed, cb.state)
+		require.Zero(t, cb.failures)
+		require.True(t, cb.allow())
+	})
+
+	t.Run("state is reported", func(t *testing.T) {
+		cbs := newCircuitBreakers(newCircuitBreakerConfig(config.CircuitBreaker{}))
+		cb := cbs.get(loopConfig{name: loopRelayMessages, interval: time.Second}, "chain-1")
+		cb.failure(errRPC)
+		cbs.get(loopConfig{name: loopSignMessages, interval: time.Second}, "chain-2")
+
+		report := cbs.report()
+		require.Len(t, report, 2)
+		require.Equal(t, "chain-1", report[0].ChainReferenceID)
+		require.Equal(t, loopRelayMessages, report[0].Loop)
+		require.Equal(t, "closed", report[0].State)
+		require.Equal(t, 1, report[0].ConsecutiveFailures)
+		require.Equal(t, errRPC.Error(), report[0].LastError)
+		require.NotNil(t, report[0].RetryAt)
+
+		cbs.remove("chain-1")
+		require.Len(t, cbs.report(), 1)
+	})
+}
@@ -13,11 +13,23 @@ var (
 	ErrMissingChainConfig = errors.Unrecoverable(whoops.String("missing chain config"))
 	ErrUnknown            = errors.Unrecoverable(whoops.String("unknown errror"))
 
+	ErrIncorrectChain = whoops.String("incorrect chain")
+
 	ErrInvalidMinOnChainBalance = whoops.Errorf("invalid minOnChainBalance: %s")
 
 	ErrNotAValidatorAccount = whoops.String("not a validator account")
 
 	ErrValidatorIsNotStaking = whoops.String("validator is not staking")
+
+	ErrUnknownLoop                    = whoops.Errorf("unknown loop: %s")
+	ErrInvalidLoopConfig              = whoops.Errorf("invalid configuration of loop %s: %s")
+	ErrInvalidKeepAliveBlockThreshold = whoops.Errorf("invalid keep alive block threshold: %d")
+
+	ErrUnknownSchedulingStrategy = whoops.Errorf("unknown scheduling strategy: %s")
+	ErrInvalidSchedulingConfig   = whoops.Errorf("invalid scheduling configuration: %s")
+
+	ErrPalomaEventsStale              = whoops.String("no new paloma blocks received over the event subscription")
+	ErrPalomaEventsSubscriptionClosed = whoops.String("paloma event subscription closed")
 )
 
 func handleProcessError(err error) error {
@@ -2,7 +2,6 @@ package relayer
 
 import (
 	"context"
-	"sync"
 
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/internal/liblog"
@@ -10,26 +9,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (r *Relayer) GravityRelayBatches(ctx context.Context, locker sync.Locker) error {
-	logger := liblog.WithContext(ctx)
-	logger.Info("relayer loop")
-	if ctx.Err() != nil {
-		logger.Info("exiting relayer loop as context has ended")
-		return ctx.Err()
-	}
-
-	err := r.buildProcessors(ctx, locker)
-	if err != nil {
-		return err
-	}
-
-	locker.Lock()
-	err = r.gravityRelayBatches(ctx, r.processors)
-	locker.Unlock()
-
-	return handleProcessError(err)
-}
-
 func (r *Relayer) gravityRelayBatches(ctx context.Context, processors []chain.Processor) error {
 	if len(processors) == 0 {
 		return nil
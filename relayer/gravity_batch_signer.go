@@ -2,7 +2,6 @@ package relayer
 
 import (
 	"context"
-	"sync"
 
 	gravity "github.com/palomachain/paloma/x/gravity/types"
 	"github.com/palomachain/pigeon/chain"
@@ -10,26 +9,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (r *Relayer) GravitySignBatches(ctx context.Context, locker sync.Locker) error {
-	log.Info("signer loop")
-	if ctx.Err() != nil {
-		log.Info("exiting signer loop as context has ended")
-		return ctx.Err()
-	}
-
-	err := r.buildProcessors(ctx, locker)
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	locker.Lock()
-	err = r.gravitySignBatches(ctx, r.processors)
-	locker.Unlock()
-
-	return handleProcessError(err)
-}
-
 func (r *Relayer) gravitySignBatches(ctx context.Context, processors []chain.Processor) error {
 	if len(processors) == 0 {
 		return nil
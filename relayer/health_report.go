@@ -0,0 +1,108 @@
+package relayer
+
+import (
+	"time"
+
+	"github.com/palomachain/pigeon/chain"
+)
+
+// HealthReport is the relayer's part of the health check server's response.
+type HealthReport struct {
+	State           string                 `json:"state"`
+	Loops           []LoopReport           `json:"loops"`
+	CircuitBreakers []CircuitBreakerReport `json:"circuit-breakers"`
+	// QuarantinedChains are skipped until they can be built again.
+	QuarantinedChains []QuarantinedChainReport `json:"quarantined-chains"`
+	// Compasses are the compass contracts each chain is relayed to.
+	Compasses []CompassReport `json:"compasses"`
+}
+
+// CompassReport holds the compass versions a chain is relayed to. The
+// previous compass is only set while the chain hands over from it after an
+// upgrade.
+type CompassReport struct {
+	ChainReferenceID string                `json:"chain-reference-id"`
+	Active           chain.CompassVersion  `json:"active"`
+	Previous         *chain.CompassVersion `json:"previous,omitempty"`
+	HandoverUntil    *time.Time            `json:"handover-until,omitempty"`
+}
+
+// compassVersioner is implemented by the processors of chains which are
+// relayed to through compass.
+type compassVersioner interface {
+	CompassVersions() chain.CompassVersions
+}
+
+// QuarantinedChainReport holds the reason why a chain is quarantined.
+type QuarantinedChainReport struct {
+	ChainReferenceID string    `json:"chain-reference-id"`
+	Reason           string    `json:"reason"`
+	Since            time.Time `json:"since"`
+}
+
+// CircuitBreakerReport holds the state of the circuit breaker of a single
+// loop on a single chain.
+type CircuitBreakerReport struct {
+	Loop                string     `json:"loop"`
+	ChainReferenceID    string     `json:"chain-reference-id"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive-failures"`
+	LastError           string     `json:"last-error,omitempty"`
+	RetryAt             *time.Time `json:"retry-at,omitempty"`
+}
+
+// LoopReport holds the effective configuration of a process loop.
+type LoopReport struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	Timeout  string `json:"timeout,omitempty"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func (r *Relayer) HealthReport() any {
+	loops := r.relayerConfig.loops()
+	report := HealthReport{
+		State:             string(r.lifecycle.State()),
+		Loops:             make([]LoopReport, 0, len(loops)),
+		CircuitBreakers:   r.breakers.report(),
+		QuarantinedChains: r.quarantine.report(),
+		Compasses:         r.compassReport(),
+	}
+
+	for _, loop := range loops {
+		lr := LoopReport{
+			Name:     loop.name,
+			Interval: loop.interval.String(),
+			Enabled:  loop.enabled,
+		}
+		if loop.timeout > 0 {
+			lr.Timeout = loop.timeout.String()
+		}
+		report.Loops = append(report.Loops, lr)
+	}
+
+	return report
+}
+
+func (r *Relayer) compassReport() []CompassReport {
+	var res []CompassReport
+	for _, p := range r.processors.List() {
+		v, ok := p.(compassVersioner)
+		if !ok {
+			continue
+		}
+
+		versions := v.CompassVersions()
+		report := CompassReport{
+			ChainReferenceID: p.GetChainReferenceID(),
+			Active:           versions.Active,
+			Previous:         versions.Previous,
+		}
+		if versions.Previous != nil {
+			until := versions.HandoverUntil
+			report.HandoverUntil = &until
+		}
+		res = append(res, report)
+	}
+	return res
+}
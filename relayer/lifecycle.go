@@ -0,0 +1,124 @@
+package relayer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultShutdownTimeout = 25 * time.Second
+
+type lifecycleState string
+
+const (
+	stateStarting lifecycleState = "starting"
+	stateRunning  lifecycleState = "running"
+	stateStopping lifecycleState = "stopping"
+	stateStopped  lifecycleState = "stopped"
+)
+
+// lifecycle supervises all goroutines started by the relayer. When pigeon
+// shuts down, no new iterations are started, but the ones in flight get a
+// chance to finish their EVM transactions and Paloma broadcasts before they
+// are aborted.
+type lifecycle struct {
+	wg sync.WaitGroup
+
+	mu    sync.RWMutex
+	state lifecycleState
+
+	abort     chan struct{}
+	abortOnce sync.Once
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		state: stateStarting,
+		abort: make(chan struct{}),
+	}
+}
+
+func (l *lifecycle) State() lifecycleState {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.state
+}
+
+func (l *lifecycle) setState(state lifecycleState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	log.WithField("state", state).Info("relayer state changed")
+	l.state = state
+}
+
+// Go runs fn in a goroutine which is waited for during shutdown.
+func (l *lifecycle) Go(fn func()) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn()
+	}()
+}
+
+// abortInFlight cancels the contexts of all iterations which are still
+// running.
+func (l *lifecycle) abortInFlight() {
+	l.abortOnce.Do(func() {
+		close(l.abort)
+	})
+}
+
+// drain waits for all goroutines to finish. If they don't finish within the
+// timeout, the iterations in flight are aborted and waited for once more.
+func (l *lifecycle) drain(timeout time.Duration) {
+	l.setState(stateStopping)
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.WithField("timeout", timeout).Warn("in-flight work didn't finish in time. aborting it")
+		l.abortInFlight()
+		<-done
+	}
+
+	l.setState(stateStopped)
+}
+
+// inFlightContext returns a context for a single iteration of a loop. It
+// carries the values of ctx, but is not canceled together with it. Instead,
+// it is only canceled when in-flight work gets aborted.
+func (l *lifecycle) inFlightContext(ctx context.Context) context.Context {
+	return inFlightContext{Context: ctx, abort: l.abort}
+}
+
+type inFlightContext struct {
+	context.Context
+	abort <-chan struct{}
+}
+
+func (c inFlightContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c inFlightContext) Done() <-chan struct{} {
+	return c.abort
+}
+
+func (c inFlightContext) Err() error {
+	select {
+	case <-c.abort:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package relayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Run("in-flight work is drained", func(t *testing.T) {
+		l := newLifecycle()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		finished := make(chan struct{})
+		started := make(chan struct{})
+		var iterationErr error
+		l.Go(func() {
+			iterationCtx := l.inFlightContext(ctx)
+			close(started)
+			<-ctx.Done()
+			// the iteration is not canceled together with the loop
+			iterationErr = iterationCtx.Err()
+			time.Sleep(10 * time.Millisecond)
+			close(finished)
+		})
+
+		<-started
+		cancel()
+		l.drain(time.Second)
+
+		require.Equal(t, stateStopped, l.State())
+		require.NoError(t, iterationErr)
+		select {
+		case <-finished:
+		default:
+			t.Fatal("drain returned before the work finished")
+		}
+	})
+
+	t.Run("work which does not finish in time is aborted", func(t *testing.T) {
+		l := newLifecycle()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var iterationErr error
+		l.Go(func() {
+			iterationCtx := l.inFlightContext(ctx)
+			<-iterationCtx.Done()
+			iterationErr = iterationCtx.Err()
+		})
+
+		l.drain(10 * time.Millisecond)
+		require.Equal(t, stateStopped, l.State())
+		require.ErrorIs(t, iterationErr, context.Canceled)
+	})
+
+	t.Run("in-flight contexts keep the values of their parent", func(t *testing.T) {
+		type key struct{}
+		l := newLifecycle()
+		ctx := context.WithValue(context.Background(), key{}, "bob")
+		require.Equal(t, "bob", l.inFlightContext(ctx).Value(key{}))
+	})
+}
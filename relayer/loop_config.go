@@ -0,0 +1,148 @@
+package relayer
+
+import (
+	"time"
+
+	"github.com/VolumeFi/whoops"
+	"github.com/palomachain/pigeon/config"
+)
+
+const (
+	loopCheckStaking              = "check-staking"
+	loopUpdateChainWorkers        = "update-chain-workers"
+	loopUpdateExternalChainInfos  = "update-external-chain-infos"
+	loopKeepAlive                 = "keep-alive"
+	loopSignMessages              = "sign-messages"
+	loopRelayMessages             = "relay-messages"
+	loopAttestMessages            = "attest-messages"
+	loopGravitySignBatches        = "gravity-sign-batches"
+	loopGravityRelayBatches       = "gravity-relay-batches"
+	loopGravityBatchSendEvents    = "gravity-batch-send-event-watcher"
+	loopGravitySendToPalomaEvents = "gravity-send-to-paloma-event-watcher"
+	loopTrackTransactions         = "track-transactions"
+)
+
+// requiredLoops can't be disabled as pigeon can't work without them.
+var requiredLoops = map[string]struct{}{
+	loopCheckStaking:       {},
+	loopUpdateChainWorkers: {},
+	loopKeepAlive:          {},
+}
+
+// loopConfig is the effective configuration of a single process loop.
+type loopConfig struct {
+	name     string
+	interval time.Duration
+	timeout  time.Duration
+	enabled  bool
+}
+
+func (c Config) defaultLoopIntervals() map[string]time.Duration {
+	return map[string]time.Duration{
+		loopCheckStaking:              checkStakingLoopInterval,
+		loopUpdateChainWorkers:        updateChainWorkersLoopInterval,
+		loopUpdateExternalChainInfos:  updateExternalChainsLoopInterval,
+		loopKeepAlive:                 c.KeepAliveLoopTimeout,
+		loopSignMessages:              signMessagesLoopInterval,
+		loopRelayMessages:             relayMessagesLoopInterval,
+		loopAttestMessages:            attestMessagesLoopInterval,
+		loopGravitySignBatches:        gravitySignBatchesLoopInterval,
+		loopGravityRelayBatches:       gravityRelayBatchesLoopInterval,
+		loopGravityBatchSendEvents:    batchSendEventWatcherLoopInterval,
+		loopGravitySendToPalomaEvents: sendToPalomaEventWatcherLoopInterval,
+		loopTrackTransactions:         trackTransactionsLoopInterval,
+	}
+}
+
+// loop returns the configuration of the loop with the given name, falling
+// back to the defaults for everything that was not configured.
+func (c Config) loop(name string) loopConfig {
+	lc := loopConfig{
+		name:     name,
+		interval: c.defaultLoopIntervals()[name],
+		enabled:  true,
+	}
+
+	cfg, ok := c.Loops[name]
+	if !ok {
+		return lc
+	}
+
+	if cfg.Interval > 0 {
+		lc.interval = cfg.Interval
+	}
+	lc.timeout = cfg.Timeout
+	lc.enabled = !cfg.Disabled
+
+	return lc
+}
+
+// loops returns the effective configuration of all loops.
+func (c Config) loops() []loopConfig {
+	names := []string{
+		loopCheckStaking,
+		loopUpdateChainWorkers,
+		loopUpdateExternalChainInfos,
+		loopKeepAlive,
+		loopSignMessages,
+		loopRelayMessages,
+		loopAttestMessages,
+		loopGravitySignBatches,
+		loopGravityRelayBatches,
+		loopGravityBatchSendEvents,
+		loopGravitySendToPalomaEvents,
+		loopTrackTransactions,
+	}
+
+	res := make([]loopConfig, 0, len(names))
+	for _, name := range names {
+		res = append(res, c.loop(name))
+	}
+
+	return res
+}
+
+// Validate makes sure that the relayer's configuration is sane.
+func (c Config) Validate() error {
+	var g whoops.Group
+
+	if c.loop(loopKeepAlive).interval <= 0 {
+		g.Add(ErrInvalidLoopConfig.Format(loopKeepAlive, "interval must be positive"))
+	}
+
+	if c.KeepAliveBlockThreshold <= 0 {
+		g.Add(ErrInvalidKeepAliveBlockThreshold.Format(c.KeepAliveBlockThreshold))
+	}
+
+	known := c.defaultLoopIntervals()
+	for name, loop := range c.Loops {
+		if _, ok := known[name]; !ok {
+			g.Add(ErrUnknownLoop.Format(name))
+			continue
+		}
+
+		g.Add(validateLoop(name, loop))
+	}
+
+	g.Add(validateScheduling(c.Scheduling))
+
+	return g.Return()
+}
+
+func validateLoop(name string, loop config.Loop) error {
+	var g whoops.Group
+
+	if loop.Interval < 0 {
+		g.Add(ErrInvalidLoopConfig.Format(name, "interval can't be negative"))
+	}
+
+	if loop.Timeout < 0 {
+		g.Add(ErrInvalidLoopConfig.Format(name, "timeout can't be negative"))
+	}
+
+	if _, ok := requiredLoops[name]; ok && loop.Disabled {
+		g.Add(ErrInvalidLoopConfig.Format(name, "loop can't be disabled"))
+	}
+
+	return g.Return()
+}
@@ -0,0 +1,135 @@
+package relayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palomachain/pigeon/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopConfig(t *testing.T) {
+	cfg := Config{
+		KeepAliveLoopTimeout:    30 * time.Second,
+		KeepAliveBlockThreshold: 30,
+		Loops: map[string]config.Loop{
+			loopRelayMessages: {
+				Interval: time.Second,
+				Timeout:  time.Minute,
+			},
+			loopGravitySignBatches: {
+				Disabled: true,
+			},
+		},
+	}
+
+	t.Run("loops without configuration use the defaults", func(t *testing.T) {
+		require.Equal(t, loopConfig{
+			name:     loopSignMessages,
+			interval: signMessagesLoopInterval,
+			enabled:  true,
+		}, cfg.loop(loopSignMessages))
+
+		require.Equal(t, loopConfig{
+			name:     loopKeepAlive,
+			interval: 30 * time.Second,
+			enabled:  true,
+		}, cfg.loop(loopKeepAlive))
+	})
+
+	t.Run("configured loops override the defaults", func(t *testing.T) {
+		require.Equal(t, loopConfig{
+			name:     loopRelayMessages,
+			interval: time.Second,
+			timeout:  time.Minute,
+			enabled:  true,
+		}, cfg.loop(loopRelayMessages))
+
+		require.Equal(t, loopConfig{
+			name:     loopGravitySignBatches,
+			interval: gravitySignBatchesLoopInterval,
+			enabled:  false,
+		}, cfg.loop(loopGravitySignBatches))
+	})
+
+	t.Run("disabled loops are not scheduled for chains", func(t *testing.T) {
+		r := &Relayer{relayerConfig: cfg}
+		for _, loop := range r.chainLoops() {
+			require.NotEqual(t, loopGravitySignBatches, loop.name)
+		}
+		require.Len(t, r.chainLoops(), 7)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			KeepAliveLoopTimeout:    30 * time.Second,
+			KeepAliveBlockThreshold: 30,
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*Config)
+		expErr error
+	}{
+		{
+			name:   "default configuration is valid",
+			modify: func(*Config) {},
+		},
+		{
+			name: "keep alive interval can be set by the loop configuration",
+			modify: func(c *Config) {
+				c.KeepAliveLoopTimeout = 0
+				c.Loops = map[string]config.Loop{loopKeepAlive: {Interval: time.Minute}}
+			},
+		},
+		{
+			name: "keep alive interval must be positive",
+			modify: func(c *Config) {
+				c.KeepAliveLoopTimeout = 0
+			},
+			expErr: ErrInvalidLoopConfig,
+		},
+		{
+			name: "keep alive block threshold must be positive",
+			modify: func(c *Config) {
+				c.KeepAliveBlockThreshold = 0
+			},
+			expErr: ErrInvalidKeepAliveBlockThreshold,
+		},
+		{
+			name: "unknown loops are rejected",
+			modify: func(c *Config) {
+				c.Loops = map[string]config.Loop{"bob": {}}
+			},
+			expErr: ErrUnknownLoop,
+		},
+		{
+			name: "negative timeouts are rejected",
+			modify: func(c *Config) {
+				c.Loops = map[string]config.Loop{loopSignMessages: {Timeout: -time.Second}}
+			},
+			expErr: ErrInvalidLoopConfig,
+		},
+		{
+			name: "required loops can't be disabled",
+			modify: func(c *Config) {
+				c.Loops = map[string]config.Loop{loopCheckStaking: {Disabled: true}}
+			},
+			expErr: ErrInvalidLoopConfig,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.expErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.expErr)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package relayer
 
 import (
 	"context"
-	"sync"
 
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/internal/liblog"
@@ -11,35 +10,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (r *Relayer) AttestMessages(ctx context.Context, locker sync.Locker) error {
-	logger := liblog.WithContext(ctx)
-	logger.Info("attester loop")
-	if ctx.Err() != nil {
-		logger.Info("exiting attester loop as context has ended")
-		return ctx.Err()
-	}
-
-	err := r.buildProcessors(ctx, locker)
-	if err != nil {
-		return err
-	}
-
-	locker.Lock()
-	err = r.attestMessages(ctx, r.processors)
-	locker.Unlock()
-
-	return handleProcessError(err)
-}
-
 func (r *Relayer) attestMessages(ctx context.Context, processors []chain.Processor) error {
 	if len(processors) == 0 {
 		return nil
 	}
 
-	// todo randomise
-	for _, p := range processors {
-		// todo randomise
-		for _, queueName := range p.SupportedQueues() {
+	for _, p := range r.scheduling.orderProcessors(processors) {
+		for _, queueName := range r.scheduling.orderQueues(p.SupportedQueues()) {
 			logger := liblog.WithContext(ctx).WithFields(log.Fields{
 				"queue-name": queueName,
 				"action":     "attest",
@@ -36,7 +36,7 @@ func TestAttestMessages(t *testing.T) {
 				r := New(
 					config.Root{},
 					pc,
-					evm.NewFactory(evmmocks.NewPalomaClienter(t)),
+					evm.NewFactory(evmmocks.NewPalomaClienter(t), nil),
 					timemocks.NewTime(t),
 					Config{},
 				)
@@ -114,7 +114,7 @@ func TestAttestMessages(t *testing.T) {
 			},
 		},
 		{
-			name: "if the processor is connected to the wrong chain it returns the error",
+			name: "if the processor is connected to the wrong chain the chain is skipped",
 			setup: func(t *testing.T) *Relayer {
 				keyringPass := "abcd"
 
@@ -161,7 +161,6 @@ func TestAttestMessages(t *testing.T) {
 					Config{},
 				)
 			},
-			expErr: chain.ErrNotConnectedToRightChain,
 		},
 	}
 
@@ -171,7 +170,8 @@ func TestAttestMessages(t *testing.T) {
 			relayer := tt.setup(t)
 
 			var locker testutil.FakeMutex
-			actualErr := relayer.AttestMessages(ctx, &locker)
+			require.NoError(t, relayer.buildProcessors(ctx, &locker))
+			actualErr := relayer.attestMessages(ctx, relayer.processors.List())
 			asserter.Equal(tt.expErr, actualErr)
 		})
 	}
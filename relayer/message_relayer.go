@@ -2,7 +2,6 @@ package relayer
 
 import (
 	"context"
-	"sync"
 
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/internal/queue"
@@ -10,34 +9,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (r *Relayer) RelayMessages(ctx context.Context, locker sync.Locker) error {
-	log.Info("relayer loop")
-	if ctx.Err() != nil {
-		log.Info("exiting relayer loop as context has ended")
-		return ctx.Err()
-	}
-
-	err := r.buildProcessors(ctx, locker)
-	if err != nil {
-		return err
-	}
-
-	locker.Lock()
-	err = r.relayMessages(ctx, r.processors)
-	locker.Unlock()
-
-	return handleProcessError(err)
-}
-
 func (r *Relayer) relayMessages(ctx context.Context, processors []chain.Processor) error {
 	if len(processors) == 0 {
 		return nil
 	}
 
-	// todo randomise
-	for _, p := range processors {
-		// todo randomise
-		for _, queueName := range p.SupportedQueues() {
+	for _, p := range r.scheduling.orderProcessors(processors) {
+		for _, queueName := range r.scheduling.orderQueues(p.SupportedQueues()) {
 			logger := log.WithFields(log.Fields{
 				"queue-name": queueName,
 				"action":     "relay",
@@ -57,6 +35,7 @@ func (r *Relayer) relayMessages(ctx context.Context, processors []chain.Processo
 				return err
 			}
 
+			messagesInQueue = r.scheduling.selectForRelaying(queueName, messagesInQueue)
 			if len(messagesInQueue) > 0 {
 				logger := logger.WithFields(log.Fields{
 					"messages-to-relay": slice.Map(messagesInQueue, func(msg chain.MessageWithSignatures) uint64 {
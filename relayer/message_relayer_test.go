@@ -36,7 +36,7 @@ func TestRelayMessages(t *testing.T) {
 				r := New(
 					config.Root{},
 					pc,
-					evm.NewFactory(evmmocks.NewPalomaClienter(t)),
+					evm.NewFactory(evmmocks.NewPalomaClienter(t), nil),
 					timemocks.NewTime(t),
 					Config{},
 				)
@@ -117,7 +117,7 @@ func TestRelayMessages(t *testing.T) {
 			},
 		},
 		{
-			name: "if the processor is connected to the wrong chain it returns the error",
+			name: "if the processor is connected to the wrong chain the chain is skipped",
 			setup: func(t *testing.T) *Relayer {
 				keyringPass := "abcd"
 
@@ -164,7 +164,6 @@ func TestRelayMessages(t *testing.T) {
 					Config{},
 				)
 			},
-			expErr: chain.ErrNotConnectedToRightChain,
 		},
 	}
 
@@ -174,7 +173,8 @@ func TestRelayMessages(t *testing.T) {
 			relayer := tt.setup(t)
 
 			var locker testutil.FakeMutex
-			actualErr := relayer.RelayMessages(ctx, &locker)
+			require.NoError(t, relayer.buildProcessors(ctx, &locker))
+			actualErr := relayer.relayMessages(ctx, relayer.processors.List())
 			asserter.Equal(tt.expErr, actualErr)
 		})
 	}
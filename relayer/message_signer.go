@@ -2,7 +2,6 @@ package relayer
 
 import (
 	"context"
-	"sync"
 
 	"github.com/palomachain/pigeon/chain"
 	"github.com/palomachain/pigeon/chain/paloma"
@@ -10,35 +9,13 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (r *Relayer) SignMessages(ctx context.Context, locker sync.Locker) error {
-	log.Info("signer loop")
-	if ctx.Err() != nil {
-		log.Info("exiting signer loop as context has ended")
-		return ctx.Err()
-	}
-
-	err := r.buildProcessors(ctx, locker)
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	locker.Lock()
-	err = r.signMessages(ctx, r.processors)
-	locker.Unlock()
-
-	return handleProcessError(err)
-}
-
 func (r *Relayer) signMessages(ctx context.Context, processors []chain.Processor) error {
 	if len(processors) == 0 {
 		return nil
 	}
 
-	// todo randomise
-	for _, p := range processors {
-		// todo randomise
-		for _, queueName := range p.SupportedQueues() {
+	for _, p := range r.scheduling.orderProcessors(processors) {
+		for _, queueName := range r.scheduling.orderQueues(p.SupportedQueues()) {
 			logger := log.WithFields(log.Fields{
 				"queue-name": queueName,
 				"action":     "sign",
@@ -35,7 +35,7 @@ func TestSignMessages(t *testing.T) {
 				return New(
 					config.Root{},
 					pc,
-					evm.NewFactory(evmmocks.NewPalomaClienter(t)),
+					evm.NewFactory(evmmocks.NewPalomaClienter(t), nil),
 					timemocks.NewTime(t),
 					Config{},
 				)
@@ -108,7 +108,7 @@ func TestSignMessages(t *testing.T) {
 			},
 		},
 		{
-			name: "if the processor is connected to the wrong chain it returns the error",
+			name: "if the processor is connected to the wrong chain the chain is skipped",
 			setup: func(t *testing.T) *Relayer {
 				keyringPass := "abcd"
 
@@ -155,7 +155,6 @@ func TestSignMessages(t *testing.T) {
 					Config{},
 				)
 			},
-			expErr: chain.ErrNotConnectedToRightChain,
 		},
 	}
 
@@ -165,7 +164,8 @@ func TestSignMessages(t *testing.T) {
 			relayer := tt.setup(t)
 
 			var locker testutil.FakeMutex
-			actualErr := relayer.SignMessages(ctx, &locker)
+			require.NoError(t, relayer.buildProcessors(ctx, &locker))
+			actualErr := relayer.signMessages(ctx, relayer.processors.List())
 			asserter.Equal(tt.expErr, actualErr)
 		})
 	}
@@ -1,4 +1,4 @@
-// Code generated by mockery v2.20.0. DO NOT EDIT.
+// Code generated by mockery v2.32.4. DO NOT EDIT.
 
 package mocks
 
@@ -7,6 +7,8 @@ import (
 
 	chain "github.com/palomachain/pigeon/chain"
 
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+
 	cosmos_sdktypes "github.com/cosmos/cosmos-sdk/types"
 
 	evmtypes "github.com/palomachain/paloma/x/evm/types"
@@ -486,13 +488,52 @@ func (_m *PalomaClienter) SetPublicAccessData(ctx context.Context, queueTypeName
 	return r0
 }
 
-type mockConstructorTestingTNewPalomaClienter interface {
-	mock.TestingT
-	Cleanup(func())
+// SubscribeToEvents provides a mock function with given fields: ctx, query
+func (_m *PalomaClienter) SubscribeToEvents(ctx context.Context, query string) (<-chan coretypes.ResultEvent, error) {
+	ret := _m.Called(ctx, query)
+
+	var r0 <-chan coretypes.ResultEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (<-chan coretypes.ResultEvent, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) <-chan coretypes.ResultEvent); ok {
+		r0 = rf(ctx, query)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan coretypes.ResultEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UnsubscribeFromEvents provides a mock function with given fields: ctx
+func (_m *PalomaClienter) UnsubscribeFromEvents(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // NewPalomaClienter creates a new instance of PalomaClienter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
-func NewPalomaClienter(t mockConstructorTestingTNewPalomaClienter) *PalomaClienter {
+// The first argument is typically a *testing.T value.
+func NewPalomaClienter(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *PalomaClienter {
 	mock := &PalomaClienter{}
 	mock.Mock.Test(t)
 
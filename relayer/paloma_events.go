@@ -0,0 +1,214 @@
+package relayer
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/palomachain/pigeon/internal/liblog"
+)
+
+const (
+	newBlockEventsQuery = "tm.event='NewBlock'"
+	txEventsQuery       = "tm.event='Tx'"
+
+	// If there was no new block for this long, the subscription is
+	// considered to be dropped and the relayer falls back to polling.
+	palomaEventsStaleTimeout = 30 * time.Second
+	palomaEventsRetryTimeout = 10 * time.Second
+
+	// defaultQueueGateMaxIdle is how long a gated process may go without
+	// running while the queues look quiet. Deadlines, fallbacks and retries
+	// are time based, and Paloma doesn't emit events for everything its
+	// EndBlockers put into the queues.
+	defaultQueueGateMaxIdle = 30 * time.Second
+)
+
+// queueChangingModules are the Paloma modules which can put messages into,
+// or take them out of, the consensus queues.
+var queueChangingModules = map[string]struct{}{
+	"consensus": {},
+	"evm":       {},
+	"scheduler": {},
+	"valset":    {},
+}
+
+// queueChangingActions are the actions of the events Paloma emits, from
+// transactions as well as from its EndBlockers, when it changes the queues.
+var queueChangingActions = map[string]struct{}{
+	"ConsensusQueueItemRemoved":              {},
+	"SmartContractExecutionFailed":           {},
+	"AttestingUpdateValsetRemoveOldMessages": {},
+	"JobScheduler":                           {},
+}
+
+// queueNotifier keeps track of consensus queue changes on Paloma. Every
+// block that changed the state of the queues bumps its generation.
+type queueNotifier struct {
+	subscribed atomic.Bool
+	generation atomic.Uint64
+
+	// maxIdle is how long a gated process may go without running. It
+	// defaults to defaultQueueGateMaxIdle.
+	maxIdle time.Duration
+	now     func() time.Time
+}
+
+func (n *queueNotifier) idleLimit() time.Duration {
+	if n.maxIdle > 0 {
+		return n.maxIdle
+	}
+	return defaultQueueGateMaxIdle
+}
+
+func (n *queueNotifier) clock() time.Time {
+	if n.now != nil {
+		return n.now()
+	}
+	return time.Now()
+}
+
+func (n *queueNotifier) notify() {
+	n.generation.Add(1)
+}
+
+// queueGateMaxIdle returns how long the event driven loops may stay idle.
+// With the "assigned" scheduling it's never longer than the fallback
+// timeout, so that taking over from an assigned relayer which went silent
+// doesn't wait for the queues to change.
+func queueGateMaxIdle(maxIdle time.Duration, scheduling schedulingStrategy) time.Duration {
+	if maxIdle <= 0 {
+		maxIdle = defaultQueueGateMaxIdle
+	}
+	if s, ok := scheduling.(*assignedScheduling); ok && s.fallbackTimeout < maxIdle {
+		maxIdle = s.fallbackTimeout
+	}
+	return maxIdle
+}
+
+// gate wraps a process so that it only runs when the queues have changed
+// since its last successful run, or when it hasn't run for the max idle
+// interval. If pigeon is not subscribed to Paloma events, the process runs
+// on every tick.
+func (n *queueNotifier) gate(process func(context.Context) error) func(context.Context) error {
+	var lastSeen uint64
+	var lastRun time.Time
+	var ran bool
+	return func(ctx context.Context) error {
+		now := n.clock()
+		if n.subscribed.Load() {
+			gen := n.generation.Load()
+			if ran && gen == lastSeen && now.Sub(lastRun) < n.idleLimit() {
+				return nil
+			}
+			lastSeen = gen
+		}
+
+		err := process(ctx)
+		// a failed run is retried on the next tick
+		ran = err == nil
+		if ran {
+			lastRun = now
+		}
+		return err
+	}
+}
+
+// changesQueueState returns true if the event was emitted by a module which
+// changes the queues, or has an action which does. Blocks carry the events
+// of their BeginBlockers and EndBlockers, whatever their type.
+func changesQueueState(ev coretypes.ResultEvent) bool {
+	for key, values := range ev.Events {
+		var match map[string]struct{}
+		switch {
+		case strings.HasSuffix(key, ".module"):
+			match = queueChangingModules
+		case strings.HasSuffix(key, ".action"):
+			match = queueChangingActions
+		default:
+			continue
+		}
+
+		for _, v := range values {
+			if _, ok := match[v]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// watchPalomaEvents subscribes to new blocks and transactions on Paloma and
+// notifies the message loops whenever the consensus queues might have
+// changed. If the subscription drops, it resubscribes and meanwhile lets the
+// loops fall back to polling.
+func (r *Relayer) watchPalomaEvents(ctx context.Context) {
+	logger := liblog.WithContext(ctx)
+	for ctx.Err() == nil {
+		err := r.consumePalomaEvents(ctx)
+		r.queueNotifier.subscribed.Store(false)
+		if ctx.Err() != nil {
+			break
+		}
+
+		logger.WithError(err).Warn("paloma event subscription dropped. falling back to polling")
+		select {
+		case <-ctx.Done():
+		case <-time.After(palomaEventsRetryTimeout):
+		}
+	}
+
+	if err := r.palomaClient.UnsubscribeFromEvents(context.Background()); err != nil {
+		logger.WithError(err).Warn("unable to unsubscribe from paloma events")
+	}
+}
+
+func (r *Relayer) consumePalomaEvents(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks, err := r.palomaClient.SubscribeToEvents(ctx, newBlockEventsQuery)
+	if err != nil {
+		return err
+	}
+
+	txs, err := r.palomaClient.SubscribeToEvents(ctx, txEventsQuery)
+	if err != nil {
+		return err
+	}
+
+	liblog.WithContext(ctx).Info("subscribed to paloma events")
+	r.queueNotifier.subscribed.Store(true)
+	// anything could have happened while we were not subscribed
+	r.queueNotifier.notify()
+
+	stale := time.NewTimer(palomaEventsStaleTimeout)
+	defer stale.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stale.C:
+			return ErrPalomaEventsStale
+		case ev, ok := <-blocks:
+			if !ok {
+				return ErrPalomaEventsSubscriptionClosed
+			}
+			stale.Reset(palomaEventsStaleTimeout)
+			if changesQueueState(ev) {
+				r.queueNotifier.notify()
+			}
+		case ev, ok := <-txs:
+			if !ok {
+				return ErrPalomaEventsSubscriptionClosed
+			}
+			if changesQueueState(ev) {
+				r.queueNotifier.notify()
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package relayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	"github.com/palomachain/pigeon/relayer/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueNotifierGate(t *testing.T) {
+	var n queueNotifier
+	var runs int
+	process := n.gate(func(context.Context) error {
+		runs++
+		return nil
+	})
+	ctx := context.Background()
+
+	t.Run("without a subscription it runs on every tick", func(t *testing.T) {
+		require.NoError(t, process(ctx))
+		require.NoError(t, process(ctx))
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("with a subscription it runs only after the queues changed", func(t *testing.T) {
+		runs = 0
+		n.subscribed.Store(true)
+		n.notify()
+
+		require.NoError(t, process(ctx))
+		require.Equal(t, 1, runs)
+
+		require.NoError(t, process(ctx))
+		require.Equal(t, 1, runs)
+
+		n.notify()
+		require.NoError(t, process(ctx))
+		require.NoError(t, process(ctx))
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("with a subscription it still runs after the max idle interval", func(t *testing.T) {
+		now := time.Now()
+		n := queueNotifier{maxIdle: time.Minute, now: func() time.Time { return now }}
+		n.subscribed.Store(true)
+		var runs int
+		process := n.gate(func(context.Context) error {
+			runs++
+			return nil
+		})
+
+		require.NoError(t, process(ctx))
+		now = now.Add(59 * time.Second)
+		require.NoError(t, process(ctx))
+		require.Equal(t, 1, runs)
+
+		now = now.Add(time.Second)
+		require.NoError(t, process(ctx))
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("failed runs are retried", func(t *testing.T) {
+		var n queueNotifier
+		n.subscribed.Store(true)
+		var runs int
+		process := n.gate(func(context.Context) error {
+			runs++
+			return ErrUnknown
+		})
+
+		require.Error(t, process(ctx))
+		require.Error(t, process(ctx))
+		require.Equal(t, 2, runs)
+	})
+}
+
+func TestChangesQueueState(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		events map[string][]string
+		exp    bool
+	}{
+		{
+			name:   "transactions of queue changing modules",
+			events: map[string][]string{"message.module": {"bank", "consensus"}},
+			exp:    true,
+		},
+		{
+			name:   "events of other modules",
+			events: map[string][]string{"message.module": {"bank"}, "transfer.amount": {"1ugrain"}},
+		},
+		{
+			name:   "events of end blockers with an action changing the queues",
+			events: map[string][]string{"message.action": {"ConsensusQueueItemRemoved"}},
+			exp:    true,
+		},
+		{
+			name:   "events of other types from queue changing modules",
+			events: map[string][]string{"attestation.module": {"evm"}},
+			exp:    true,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.exp, changesQueueState(coretypes.ResultEvent{Events: tt.events}))
+		})
+	}
+}
+
+func TestConsumePalomaEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	blocks := make(chan coretypes.ResultEvent, 3)
+	txs := make(chan coretypes.ResultEvent, 3)
+
+	pc := mocks.NewPalomaClienter(t)
+	pc.On("SubscribeToEvents", mock.Anything, newBlockEventsQuery).Return((<-chan coretypes.ResultEvent)(blocks), nil)
+	pc.On("SubscribeToEvents", mock.Anything, txEventsQuery).Return((<-chan coretypes.ResultEvent)(txs), nil)
+
+	r := &Relayer{palomaClient: pc}
+
+	done := make(chan error)
+	go func() {
+		done <- r.consumePalomaEvents(ctx)
+	}()
+
+	require.Eventually(t, r.queueNotifier.subscribed.Load, time.Second, 10*time.Millisecond)
+	require.Equal(t, uint64(1), r.queueNotifier.generation.Load())
+
+	blocks <- coretypes.ResultEvent{Events: map[string][]string{"message.module": {"distribution"}}}
+	txs <- coretypes.ResultEvent{Events: map[string][]string{"message.module": {"bank"}}}
+	txs <- coretypes.ResultEvent{Events: map[string][]string{"message.module": {"consensus"}}}
+	require.Eventually(t, func() bool {
+		return r.queueNotifier.generation.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	close(blocks)
+	require.ErrorIs(t, <-done, ErrPalomaEventsSubscriptionClosed)
+}
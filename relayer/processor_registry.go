@@ -0,0 +1,89 @@
+package relayer
+
+import (
+	"sync"
+
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/util/slice"
+)
+
+// processorRegistry keeps track of the processors for all chains pigeon is
+// relaying to. It is safe for concurrent use. Every chain gets its own lock
+// so that work on one chain never has to wait for another chain to finish.
+type processorRegistry struct {
+	mu         sync.RWMutex
+	processors []chain.Processor
+	lockers    map[string]*sync.Mutex
+}
+
+func newProcessorRegistry() *processorRegistry {
+	return &processorRegistry{
+		lockers: make(map[string]*sync.Mutex),
+	}
+}
+
+// List returns a snapshot of all registered processors.
+func (pr *processorRegistry) List() []chain.Processor {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	if pr.processors == nil {
+		return nil
+	}
+
+	res := make([]chain.Processor, len(pr.processors))
+	copy(res, pr.processors)
+	return res
+}
+
+func (pr *processorRegistry) Len() int {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return len(pr.processors)
+}
+
+// Get returns the processor registered for the given chain reference ID.
+func (pr *processorRegistry) Get(chainReferenceID string) (chain.Processor, bool) {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	for _, p := range pr.processors {
+		if p.GetChainReferenceID() == chainReferenceID {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// Set replaces all registered processors.
+func (pr *processorRegistry) Set(processors []chain.Processor) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.processors = processors
+}
+
+// ChainReferenceIDs returns the chain reference IDs of all registered
+// processors.
+func (pr *processorRegistry) ChainReferenceIDs() []string {
+	return slice.Map(pr.List(), func(p chain.Processor) string {
+		return p.GetChainReferenceID()
+	})
+}
+
+// Locker returns the lock which guards the work done on a single chain. The
+// same lock is returned for a chain even after its processor gets rebuilt.
+func (pr *processorRegistry) Locker(chainReferenceID string) sync.Locker {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	l, ok := pr.lockers[chainReferenceID]
+	if !ok {
+		l = &sync.Mutex{}
+		pr.lockers[chainReferenceID] = l
+	}
+
+	return l
+}
@@ -0,0 +1,141 @@
+package relayer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	evmtypes "github.com/palomachain/paloma/x/evm/types"
+)
+
+type quarantinedChain struct {
+	reason string
+	since  time.Time
+	// chainInfo is the chain info the chain failed to be built from.
+	chainInfo evmtypes.ChainInfo
+	// breaker backs off the rebuilds while the chain info stays the same.
+	breaker *circuitBreaker
+}
+
+// chainQuarantine keeps track of chains which couldn't be built, for example
+// because they are missing from the config or pigeon is connected to the
+// wrong chain. Quarantined chains are skipped, so that the healthy chains
+// keep relaying. They are only rebuilt with a backoff until their chain info
+// changes, so that a misconfigured RPC isn't redialed on every tick.
+type chainQuarantine struct {
+	mu     sync.RWMutex
+	chains map[string]quarantinedChain
+	cfg    circuitBreakerConfig
+	// base is the backoff after the first failed rebuild.
+	base time.Duration
+	now  func() time.Time
+}
+
+func newChainQuarantine(cfg circuitBreakerConfig, base time.Duration) *chainQuarantine {
+	return &chainQuarantine{
+		chains: make(map[string]quarantinedChain),
+		cfg:    cfg,
+		base:   base,
+		now:    time.Now,
+	}
+}
+
+// add quarantines the chain which failed to be built from the chain info. It
+// returns true if the chain wasn't quarantined for the same reason before.
+func (q *chainQuarantine) add(chainInfo evmtypes.ChainInfo, err error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reason := err.Error()
+	qc, ok := q.chains[chainInfo.GetChainReferenceID()]
+	if !ok {
+		qc.since = q.now()
+	}
+	if !ok || !chainInfoEqual(qc.chainInfo, chainInfo) {
+		qc.chainInfo = chainInfo
+		qc.breaker = newCircuitBreaker(q.cfg, q.base)
+		qc.breaker.now = q.now
+	}
+	qc.breaker.failure(err)
+	changed := !ok || qc.reason != reason
+	qc.reason = reason
+	q.chains[chainInfo.GetChainReferenceID()] = qc
+
+	return changed
+}
+
+// retry reports whether the chain should be built from the chain info. A
+// quarantined chain is only retried once its backoff passed, unless its
+// chain info changed.
+func (q *chainQuarantine) retry(chainInfo evmtypes.ChainInfo) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	qc, ok := q.chains[chainInfo.GetChainReferenceID()]
+	if !ok || !chainInfoEqual(qc.chainInfo, chainInfo) {
+		return true
+	}
+
+	return qc.breaker.allow()
+}
+
+// remove releases the chain from the quarantine. It returns true if the
+// chain was quarantined.
+func (q *chainQuarantine) remove(chainReferenceID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, ok := q.chains[chainReferenceID]
+	delete(q.chains, chainReferenceID)
+	return ok
+}
+
+// retain forgets about quarantined chains which are no longer known to
+// Paloma.
+func (q *chainQuarantine) retain(chainReferenceIDs []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	known := make(map[string]struct{}, len(chainReferenceIDs))
+	for _, id := range chainReferenceIDs {
+		known[id] = struct{}{}
+	}
+
+	for id := range q.chains {
+		if _, ok := known[id]; !ok {
+			delete(q.chains, id)
+		}
+	}
+}
+
+func (q *chainQuarantine) ChainReferenceIDs() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	res := make([]string, 0, len(q.chains))
+	for id := range q.chains {
+		res = append(res, id)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func (q *chainQuarantine) report() []QuarantinedChainReport {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	res := make([]QuarantinedChainReport, 0, len(q.chains))
+	for id, qc := range q.chains {
+		res = append(res, QuarantinedChainReport{
+			ChainReferenceID: id,
+			Reason:           qc.reason,
+			Since:            qc.since,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ChainReferenceID < res[j].ChainReferenceID
+	})
+
+	return res
+}
@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"time"
 
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	proto "github.com/cosmos/gogoproto/proto"
@@ -41,6 +42,9 @@ type PalomaClienter interface {
 	QueryGetValidatorAliveUntilBlockHeight(ctx context.Context) (int64, error)
 	KeepValidatorAlive(ctx context.Context, appVersion string) error
 
+	SubscribeToEvents(ctx context.Context, query string) (<-chan coretypes.ResultEvent, error)
+	UnsubscribeFromEvents(ctx context.Context) error
+
 	GravityQueryLastUnsignedBatch(ctx context.Context, chainReferenceID string) ([]gravity.OutgoingTxBatch, error)
 	GravityConfirmBatches(ctx context.Context, signatures ...chain.SignedGravityOutgoingTxBatch) error
 	GravityQueryBatchesForRelaying(ctx context.Context, chainReferenceID string) ([]chain.GravityBatchWithSignatures, error)
@@ -75,7 +79,14 @@ type Relayer struct {
 	time utiltime.Time
 
 	chainsInfos []evmtypes.ChainInfo
-	processors  []chain.Processor
+	processors  *processorRegistry
+	scheduler   *chainScheduler
+	breakers    *circuitBreakers
+	lifecycle   *lifecycle
+	quarantine  *chainQuarantine
+	scheduling  schedulingStrategy
+
+	queueNotifier queueNotifier
 
 	staking bool
 
@@ -85,17 +96,52 @@ type Relayer struct {
 type Config struct {
 	KeepAliveLoopTimeout    time.Duration
 	KeepAliveBlockThreshold int64
+
+	// SubscribeToPalomaEvents makes the message loops wait for Paloma
+	// blocks which changed the consensus queues instead of polling them.
+	SubscribeToPalomaEvents bool
+
+	// PalomaEventsMaxIdle is how long the event driven loops may go
+	// without running while subscribed to Paloma events.
+	PalomaEventsMaxIdle time.Duration
+
+	// Loops overrides the default settings of the process loops, keyed by
+	// the loop name.
+	Loops map[string]config.Loop
+
+	// CircuitBreaker configures the backoff and circuit breakers of the
+	// loops running for every chain.
+	CircuitBreaker config.CircuitBreaker
+
+	// Scheduling configures the order in which work is done and how the
+	// relaying of messages is shared with the other validators.
+	Scheduling config.Scheduling
+
+	// ShutdownTimeout is how long work in flight is allowed to run after
+	// pigeon was asked to stop.
+	ShutdownTimeout time.Duration
 }
 
 func New(config config.Root, palomaClient PalomaClienter, evmFactory EvmFactorier, customTime utiltime.Time, cfg Config) *Relayer {
-	return &Relayer{
+	r := &Relayer{
 		config:        config,
 		palomaClient:  palomaClient,
 		evmFactory:    evmFactory,
 		time:          customTime,
 		relayerConfig: cfg,
 		staking:       false,
+		processors:    newProcessorRegistry(),
+		breakers:      newCircuitBreakers(newCircuitBreakerConfig(cfg.CircuitBreaker)),
+		lifecycle:     newLifecycle(),
+		quarantine:    newChainQuarantine(newCircuitBreakerConfig(cfg.CircuitBreaker), cfg.loop(loopUpdateChainWorkers).interval),
 	}
+	r.scheduler = newChainScheduler(r.chainLoops()...)
+	r.scheduling = newSchedulingStrategy(cfg.Scheduling, func() sdk.ValAddress {
+		return r.palomaClient.GetValidatorAddress()
+	})
+	r.queueNotifier.maxIdle = queueGateMaxIdle(cfg.PalomaEventsMaxIdle, r.scheduling)
+
+	return r
 }
 
 func (r *Relayer) SetAppVersion(appVersion string) {
@@ -0,0 +1,173 @@
+package relayer
+
+import (
+	"context"
+	goerrors "errors"
+	"sync"
+
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/internal/liblog"
+	"github.com/palomachain/pigeon/util/slice"
+	log "github.com/sirupsen/logrus"
+)
+
+// chainLoop is a process which runs separately for every chain, so a slow or
+// stuck chain only ever holds up its own work.
+type chainLoop struct {
+	loopConfig
+	requiresStaking bool
+	// eventDriven loops only run when the consensus queues on Paloma have
+	// changed, as long as pigeon is subscribed to Paloma events.
+	eventDriven bool
+	process     func(context.Context, []chain.Processor) error
+}
+
+type chainScheduler struct {
+	mu    sync.Mutex
+	loops []chainLoop
+	// ctx is the context the workers run with. The workers outlive the
+	// iteration which started them, so they must not run with its context.
+	ctx     context.Context
+	workers map[string]context.CancelFunc
+}
+
+func newChainScheduler(loops ...chainLoop) *chainScheduler {
+	return &chainScheduler{
+		loops:   loops,
+		ctx:     context.Background(),
+		workers: make(map[string]context.CancelFunc),
+	}
+}
+
+// run makes the workers started from now on run until ctx is done.
+func (s *chainScheduler) run(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ctx = ctx
+}
+
+// chainLoops returns all enabled loops which run for every chain.
+func (r *Relayer) chainLoops() []chainLoop {
+	cfg := r.relayerConfig
+	loops := []chainLoop{
+		{loopConfig: cfg.loop(loopSignMessages), requiresStaking: true, eventDriven: true, process: r.signMessages},
+		{loopConfig: cfg.loop(loopRelayMessages), requiresStaking: true, eventDriven: true, process: r.relayMessages},
+		{loopConfig: cfg.loop(loopAttestMessages), requiresStaking: true, eventDriven: true, process: r.attestMessages},
+		{loopConfig: cfg.loop(loopGravitySignBatches), requiresStaking: true, process: r.gravitySignBatches},
+		{loopConfig: cfg.loop(loopGravityRelayBatches), requiresStaking: true, process: r.gravityRelayBatches},
+		{loopConfig: cfg.loop(loopGravityBatchSendEvents), requiresStaking: true, process: r.handleBatchSendEvents},
+		{loopConfig: cfg.loop(loopGravitySendToPalomaEvents), requiresStaking: true, process: r.handleSendToPalomaEvents},
+		// stuck transactions are dealt with even when not staking, so that
+		// they don't hold up the account's nonce
+		{loopConfig: cfg.loop(loopTrackTransactions), process: r.trackTransactions},
+	}
+
+	return slice.Filter(loops, func(loop chainLoop) bool {
+		return loop.enabled
+	})
+}
+
+// UpdateChainWorkers rebuilds the processors if the chain infos on Paloma
+// have changed and makes sure that every chain has its workers running.
+func (r *Relayer) UpdateChainWorkers(ctx context.Context, locker sync.Locker) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err := r.buildProcessors(ctx, locker); err != nil {
+		return err
+	}
+
+	r.syncChainWorkers(ctx)
+	return nil
+}
+
+// syncChainWorkers starts workers for the chains which have been added to
+// the registry and stops workers of the chains which are gone. The workers
+// run with the scheduler's context, not with the given one.
+func (r *Relayer) syncChainWorkers(ctx context.Context) {
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		// pigeon is shutting down, so don't start anything new
+		return
+	}
+
+	active := make(map[string]struct{})
+	for _, chainReferenceID := range r.processors.ChainReferenceIDs() {
+		chainReferenceID := chainReferenceID
+		active[chainReferenceID] = struct{}{}
+		if _, ok := s.workers[chainReferenceID]; ok {
+			continue
+		}
+
+		liblog.WithContext(ctx).WithField("chain-reference-id", chainReferenceID).Info("starting chain workers")
+		workerCtx, cancel := context.WithCancel(s.ctx)
+		s.workers[chainReferenceID] = cancel
+		for _, loop := range s.loops {
+			loop := loop
+			r.lifecycle.Go(func() {
+				r.startChainProcess(workerCtx, chainReferenceID, loop)
+			})
+		}
+	}
+
+	for chainReferenceID, cancel := range s.workers {
+		if _, ok := active[chainReferenceID]; ok {
+			continue
+		}
+
+		liblog.WithContext(ctx).WithField("chain-reference-id", chainReferenceID).Info("stopping chain workers")
+		cancel()
+		delete(s.workers, chainReferenceID)
+		r.breakers.remove(chainReferenceID)
+	}
+}
+
+func (r *Relayer) startChainProcess(ctx context.Context, chainReferenceID string, loop chainLoop) {
+	logger := log.WithFields(log.Fields{
+		"chain-reference-id": chainReferenceID,
+		"loop":               loop.name,
+	})
+	logger.Debug("starting chain worker")
+
+	process := func(ctx context.Context) error {
+		p, ok := r.processors.Get(chainReferenceID)
+		if !ok {
+			return nil
+		}
+
+		return loop.process(ctx, []chain.Processor{p})
+	}
+	if loop.eventDriven {
+		process = r.queueNotifier.gate(process)
+	}
+
+	breaker := r.breakers.get(loop.loopConfig, chainReferenceID)
+	r.startProcess(ctx, r.processors.Locker(chainReferenceID), loop.loopConfig, loop.requiresStaking, func(ctx context.Context, locker sync.Locker) error {
+		if !breaker.allow() {
+			logger.Debug("skipping iteration due to previous failures")
+			return nil
+		}
+
+		locker.Lock()
+		defer locker.Unlock()
+
+		err := process(ctx)
+		switch {
+		case err == nil:
+			breaker.success()
+		case goerrors.Is(err, context.Canceled):
+			// the worker is being stopped, which is not the chain's fault
+		default:
+			if breaker.failure(err) {
+				logger.WithError(err).Warn("circuit breaker opened")
+			}
+		}
+
+		return handleProcessError(err)
+	})
+}
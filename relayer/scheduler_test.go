@@ -0,0 +1,168 @@
+package relayer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/palomachain/pigeon/chain"
+	chainmocks "github.com/palomachain/pigeon/chain/mocks"
+	"github.com/palomachain/pigeon/config"
+	"github.com/palomachain/pigeon/relayer/mocks"
+	timemocks "github.com/palomachain/pigeon/util/time/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncChainWorkers(t *testing.T) {
+	newProcessor := func(t *testing.T, chainReferenceID string) *chainmocks.Processor {
+		p := chainmocks.NewProcessor(t)
+		p.On("GetChainReferenceID").Return(chainReferenceID).Maybe()
+		return p
+	}
+
+	t.Run("a stuck chain does not block other chains", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		r := New(config.Root{}, mocks.NewPalomaClienter(t), mocks.NewEvmFactorier(t), timemocks.NewTime(t), Config{})
+		t.Cleanup(r.lifecycle.abortInFlight)
+		r.processors.Set([]chain.Processor{
+			newProcessor(t, "slow-chain"),
+			newProcessor(t, "fast-chain"),
+		})
+
+		processed := make(chan string, 10)
+		r.scheduler = newChainScheduler(chainLoop{
+			loopConfig: loopConfig{name: "test", interval: 10 * time.Millisecond, enabled: true},
+			process: func(ctx context.Context, processors []chain.Processor) error {
+				chainReferenceID := processors[0].GetChainReferenceID()
+				if chainReferenceID == "slow-chain" {
+					<-ctx.Done()
+					return nil
+				}
+				processed <- chainReferenceID
+				return nil
+			},
+		})
+
+		r.scheduler.run(ctx)
+		r.syncChainWorkers(ctx)
+
+		for i := 0; i < 3; i++ {
+			select {
+			case chainReferenceID := <-processed:
+				require.Equal(t, "fast-chain", chainReferenceID)
+			case <-time.After(time.Second):
+				t.Fatal("fast chain was blocked by the slow chain")
+			}
+		}
+	})
+
+	t.Run("workers of removed chains are stopped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		r := New(config.Root{}, mocks.NewPalomaClienter(t), mocks.NewEvmFactorier(t), timemocks.NewTime(t), Config{})
+		r.scheduler = newChainScheduler(chainLoop{
+			loopConfig: loopConfig{name: "test", interval: time.Hour, enabled: true},
+			process: func(context.Context, []chain.Processor) error {
+				return nil
+			},
+		})
+		r.scheduler.run(ctx)
+
+		r.processors.Set([]chain.Processor{
+			newProcessor(t, "chain-1"),
+			newProcessor(t, "chain-2"),
+		})
+		r.syncChainWorkers(ctx)
+		require.Len(t, r.scheduler.workers, 2)
+
+		r.processors.Set([]chain.Processor{
+			newProcessor(t, "chain-2"),
+		})
+		r.syncChainWorkers(ctx)
+		require.Len(t, r.scheduler.workers, 1)
+		require.Contains(t, r.scheduler.workers, "chain-2")
+	})
+
+	t.Run("workers stop when pigeon shuts down", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		r := New(config.Root{}, mocks.NewPalomaClienter(t), mocks.NewEvmFactorier(t), timemocks.NewTime(t), Config{})
+		t.Cleanup(r.lifecycle.abortInFlight)
+		r.processors.Set([]chain.Processor{newProcessor(t, "chain-1")})
+
+		processed := make(chan struct{}, 100)
+		r.scheduler = newChainScheduler(chainLoop{
+			loopConfig: loopConfig{name: "test", interval: 10 * time.Millisecond, enabled: true},
+			process: func(context.Context, []chain.Processor) error {
+				processed <- struct{}{}
+				return nil
+			},
+		})
+		r.scheduler.run(ctx)
+
+		// the workers are started by an iteration of the update loop, whose
+		// context isn't canceled when pigeon shuts down
+		r.syncChainWorkers(r.lifecycle.inFlightContext(ctx))
+		select {
+		case <-processed:
+		case <-time.After(time.Second):
+			t.Fatal("the chain workers didn't start")
+		}
+
+		cancel()
+		start := time.Now()
+		r.lifecycle.drain(5 * time.Second)
+		require.Less(t, time.Since(start), time.Second)
+
+		for len(processed) > 0 {
+			<-processed
+		}
+		time.Sleep(50 * time.Millisecond)
+		require.Empty(t, processed)
+
+		// no workers are started once pigeon is shutting down
+		r.processors.Set([]chain.Processor{newProcessor(t, "chain-2")})
+		r.syncChainWorkers(r.lifecycle.inFlightContext(ctx))
+		require.NotContains(t, r.scheduler.workers, "chain-2")
+	})
+
+	t.Run("workers outlive the timeout of the iteration which started them", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		r := New(config.Root{}, mocks.NewPalomaClienter(t), mocks.NewEvmFactorier(t), timemocks.NewTime(t), Config{})
+		r.processors.Set([]chain.Processor{newProcessor(t, "chain-1")})
+
+		processed := make(chan struct{}, 100)
+		r.scheduler = newChainScheduler(chainLoop{
+			loopConfig: loopConfig{name: "test", interval: 10 * time.Millisecond, enabled: true},
+			process: func(context.Context, []chain.Processor) error {
+				processed <- struct{}{}
+				return nil
+			},
+		})
+		r.scheduler.run(ctx)
+
+		var locker sync.Mutex
+		err := runProcess(r.lifecycle.inFlightContext(ctx), &locker, 5*time.Millisecond, func(ctx context.Context, _ sync.Locker) error {
+			r.syncChainWorkers(ctx)
+			return nil
+		})
+		require.NoError(t, err)
+
+		time.Sleep(50 * time.Millisecond)
+		for len(processed) > 0 {
+			<-processed
+		}
+		select {
+		case <-processed:
+		case <-time.After(time.Second):
+			t.Fatal("the chain workers were stopped together with the iteration")
+		}
+	})
+}
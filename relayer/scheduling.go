@@ -0,0 +1,205 @@
+package relayer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/config"
+)
+
+const (
+	schedulingSequential = "sequential"
+	schedulingRandom     = "random"
+	schedulingAssigned   = "assigned"
+
+	defaultSchedulingRelayersPerMessage = 1
+	defaultSchedulingFallbackTimeout    = 2 * time.Minute
+)
+
+// schedulingStrategy decides in which order pigeon goes through the chains
+// and queues, and which of the messages ready for relaying it should relay
+// itself. Without coordination, every pigeon would try to relay the same
+// messages at the same time and all but one of them would waste gas on a
+// reverted transaction.
+type schedulingStrategy interface {
+	orderProcessors(processors []chain.Processor) []chain.Processor
+	orderQueues(queueNames []string) []string
+	selectForRelaying(queueName string, msgs []chain.MessageWithSignatures) []chain.MessageWithSignatures
+}
+
+func newSchedulingStrategy(cfg config.Scheduling, self func() sdk.ValAddress) schedulingStrategy {
+	switch cfg.Strategy {
+	case schedulingSequential:
+		return sequentialScheduling{}
+	case schedulingAssigned:
+		return newAssignedScheduling(cfg, self)
+	default:
+		return randomScheduling{}
+	}
+}
+
+func validateScheduling(cfg config.Scheduling) error {
+	switch cfg.Strategy {
+	case "", schedulingSequential, schedulingRandom, schedulingAssigned:
+	default:
+		return ErrUnknownSchedulingStrategy.Format(cfg.Strategy)
+	}
+
+	if cfg.RelayersPerMessage < 0 {
+		return ErrInvalidSchedulingConfig.Format("relayers per message can't be negative")
+	}
+
+	if cfg.FallbackTimeout < 0 {
+		return ErrInvalidSchedulingConfig.Format("fallback timeout can't be negative")
+	}
+
+	return nil
+}
+
+// sequentialScheduling goes through everything in the order it was given
+// and relays every message.
+type sequentialScheduling struct{}
+
+func (sequentialScheduling) orderProcessors(processors []chain.Processor) []chain.Processor {
+	return processors
+}
+
+func (sequentialScheduling) orderQueues(queueNames []string) []string {
+	return queueNames
+}
+
+func (sequentialScheduling) selectForRelaying(_ string, msgs []chain.MessageWithSignatures) []chain.MessageWithSignatures {
+	return msgs
+}
+
+// randomScheduling goes through the chains and queues in a random order, so
+// that pigeons are less likely to relay the same messages at the same time.
+// Messages within a queue keep their order, as they might depend on each
+// other.
+type randomScheduling struct{}
+
+func (randomScheduling) orderProcessors(processors []chain.Processor) []chain.Processor {
+	return shuffle(processors)
+}
+
+func (randomScheduling) orderQueues(queueNames []string) []string {
+	return shuffle(queueNames)
+}
+
+func (randomScheduling) selectForRelaying(_ string, msgs []chain.MessageWithSignatures) []chain.MessageWithSignatures {
+	return msgs
+}
+
+func shuffle[T any](in []T) []T {
+	res := make([]T, len(in))
+	copy(res, in)
+	//nolint:gosec // the order doesn't need a secure source of randomness
+	rand.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+
+	return res
+}
+
+// assignedScheduling deterministically assigns every message to a few
+// validators, which are the only ones relaying it at first. Every validator
+// ranks the validators which signed a message in the same way, so they all
+// agree on who goes first without talking to each other. If the assigned
+// validators don't relay the message in time, the next ones in line take
+// over after every fallback timeout.
+type assignedScheduling struct {
+	randomScheduling
+
+	relayersPerMessage int
+	fallbackTimeout    time.Duration
+	self               func() sdk.ValAddress
+	now                func() time.Time
+
+	mu sync.Mutex
+	// firstSeen holds the time each message was first seen ready for
+	// relaying, keyed by queue name and message ID.
+	firstSeen map[string]map[uint64]time.Time
+}
+
+func newAssignedScheduling(cfg config.Scheduling, self func() sdk.ValAddress) *assignedScheduling {
+	s := &assignedScheduling{
+		relayersPerMessage: cfg.RelayersPerMessage,
+		fallbackTimeout:    cfg.FallbackTimeout,
+		self:               self,
+		now:                time.Now,
+		firstSeen:          make(map[string]map[uint64]time.Time),
+	}
+	if s.relayersPerMessage <= 0 {
+		s.relayersPerMessage = defaultSchedulingRelayersPerMessage
+	}
+	if s.fallbackTimeout <= 0 {
+		s.fallbackTimeout = defaultSchedulingFallbackTimeout
+	}
+
+	return s
+}
+
+func (s *assignedScheduling) selectForRelaying(queueName string, msgs []chain.MessageWithSignatures) []chain.MessageWithSignatures {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	self := s.self()
+	seen := make(map[uint64]time.Time, len(msgs))
+	res := make([]chain.MessageWithSignatures, 0, len(msgs))
+	for _, msg := range msgs {
+		firstSeen, ok := s.firstSeen[queueName][msg.ID]
+		if !ok {
+			firstSeen = now
+		}
+		// messages which are no longer in the queue are forgotten
+		seen[msg.ID] = firstSeen
+
+		tier := relayerRank(msg, self) / s.relayersPerMessage
+		if now.Sub(firstSeen) >= time.Duration(tier)*s.fallbackTimeout {
+			res = append(res, msg)
+		}
+	}
+	s.firstSeen[queueName] = seen
+
+	return res
+}
+
+// relayerRank returns the position of the validator in the order in which
+// validators are allowed to relay the message. Validators which didn't sign
+// the message come last.
+func relayerRank(msg chain.MessageWithSignatures, val sdk.ValAddress) int {
+	type ranked struct {
+		val  sdk.ValAddress
+		hash []byte
+	}
+
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], msg.ID)
+	vals := make([]ranked, 0, len(msg.Signatures))
+	for _, sig := range msg.Signatures {
+		h := sha256.New()
+		h.Write(id[:])
+		h.Write(sig.ValAddress)
+		vals = append(vals, ranked{val: sig.ValAddress, hash: h.Sum(nil)})
+	}
+
+	sort.Slice(vals, func(i, j int) bool {
+		return bytes.Compare(vals[i].hash, vals[j].hash) < 0
+	})
+
+	for i, v := range vals {
+		if v.val.Equals(val) {
+			return i
+		}
+	}
+
+	return len(vals)
+}
@@ -0,0 +1,133 @@
+package relayer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/config"
+	"github.com/palomachain/pigeon/util/slice"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomScheduling(t *testing.T) {
+	queues := []string{"a", "b", "c", "d", "e"}
+	ordered := randomScheduling{}.orderQueues(queues)
+
+	require.ElementsMatch(t, queues, ordered)
+	// the input is left alone
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, queues)
+}
+
+func TestAssignedScheduling(t *testing.T) {
+	vals := []sdk.ValAddress{
+		sdk.ValAddress("validator-1"),
+		sdk.ValAddress("validator-2"),
+		sdk.ValAddress("validator-3"),
+	}
+	msgs := slice.IterN(20, func(i int) chain.MessageWithSignatures {
+		return chain.MessageWithSignatures{
+			QueuedMessage: chain.QueuedMessage{ID: uint64(i)},
+			Signatures: slice.Map(vals, func(val sdk.ValAddress) chain.ValidatorSignature {
+				return chain.ValidatorSignature{ValAddress: val}
+			}),
+		}
+	})
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newStrategy := func(val sdk.ValAddress) *assignedScheduling {
+		s := newAssignedScheduling(config.Scheduling{
+			RelayersPerMessage: 1,
+			FallbackTimeout:    time.Minute,
+		}, func() sdk.ValAddress { return val })
+		s.now = func() time.Time { return now }
+		return s
+	}
+	ids := func(msgs []chain.MessageWithSignatures) []uint64 {
+		return slice.Map(msgs, func(msg chain.MessageWithSignatures) uint64 {
+			return msg.ID
+		})
+	}
+
+	strategies := slice.Map(vals, newStrategy)
+
+	t.Run("every message is assigned to exactly one validator", func(t *testing.T) {
+		var selected []uint64
+		for _, s := range strategies {
+			selected = append(selected, ids(s.selectForRelaying("queue", msgs))...)
+		}
+		require.ElementsMatch(t, ids(msgs), selected)
+	})
+
+	t.Run("the next validators take over after the fallback timeout", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		var selected []uint64
+		for _, s := range strategies {
+			selected = append(selected, ids(s.selectForRelaying("queue", msgs))...)
+		}
+		require.Len(t, selected, 2*len(msgs))
+
+		now = now.Add(time.Minute)
+		require.Len(t, strategies[0].selectForRelaying("queue", msgs), len(msgs))
+	})
+
+	t.Run("messages which left the queue are forgotten", func(t *testing.T) {
+		s := strategies[0]
+		s.selectForRelaying("queue", msgs[:1])
+		require.Len(t, s.firstSeen["queue"], 1)
+	})
+
+	t.Run("validators which didn't sign come last", func(t *testing.T) {
+		require.Equal(t, len(vals), relayerRank(msgs[0], sdk.ValAddress("stranger")))
+	})
+}
+
+func TestAssignedSchedulingFallbackWhileSubscribed(t *testing.T) {
+	ctx := context.Background()
+	vals := []sdk.ValAddress{
+		sdk.ValAddress("validator-1"),
+		sdk.ValAddress("validator-2"),
+	}
+	msgs := slice.IterN(10, func(i int) chain.MessageWithSignatures {
+		return chain.MessageWithSignatures{
+			QueuedMessage: chain.QueuedMessage{ID: uint64(i)},
+			Signatures: slice.Map(vals, func(val sdk.ValAddress) chain.ValidatorSignature {
+				return chain.ValidatorSignature{ValAddress: val}
+			}),
+		}
+	})
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	s := newAssignedScheduling(config.Scheduling{
+		RelayersPerMessage: 1,
+		FallbackTimeout:    time.Minute,
+	}, func() sdk.ValAddress { return vals[0] })
+	s.now = clock
+
+	// the queues stay quiet, as the assigned relayer went silent
+	n := queueNotifier{maxIdle: queueGateMaxIdle(10*time.Minute, s), now: clock}
+	n.subscribed.Store(true)
+	n.notify()
+
+	var selected []chain.MessageWithSignatures
+	process := n.gate(func(context.Context) error {
+		selected = s.selectForRelaying("queue", msgs)
+		return nil
+	})
+
+	require.NoError(t, process(ctx))
+	assigned := len(selected)
+	require.Less(t, assigned, len(msgs))
+
+	now = now.Add(30 * time.Second)
+	require.NoError(t, process(ctx))
+	require.Len(t, selected, assigned)
+
+	// the forced run takes over the messages of the silent relayer
+	now = now.Add(30 * time.Second)
+	require.NoError(t, process(ctx))
+	require.Len(t, selected, len(msgs))
+}
@@ -16,12 +16,15 @@ const (
 	relayMessagesLoopInterval        = 500 * time.Millisecond
 	attestMessagesLoopInterval       = 500 * time.Millisecond
 	checkStakingLoopInterval         = 5 * time.Second
+	updateChainWorkersLoopInterval   = 5 * time.Second
 
 	updateGravityOrchestratorAddressInterval = 1 * time.Minute
 	gravitySignBatchesLoopInterval           = 5 * time.Second
 	gravityRelayBatchesLoopInterval          = 5 * time.Second
 	batchSendEventWatcherLoopInterval        = 5 * time.Second
 	sendToPalomaEventWatcherLoopInterval     = 5 * time.Second
+
+	trackTransactionsLoopInterval = 15 * time.Second
 )
 
 func (r *Relayer) checkStaking(ctx context.Context, locker sync.Locker) error {
@@ -41,19 +44,27 @@ func (r *Relayer) checkStaking(ctx context.Context, locker sync.Locker) error {
 	return nil
 }
 
-func (r *Relayer) startProcess(ctx context.Context, locker sync.Locker, tickerInterval time.Duration, requiresStaking bool, process func(context.Context, sync.Locker) error) {
-	ticker := time.NewTicker(tickerInterval)
+func (r *Relayer) startProcess(ctx context.Context, locker sync.Locker, loop loopConfig, requiresStaking bool, process func(context.Context, sync.Locker) error) {
+	ticker := time.NewTicker(loop.interval)
 	defer ticker.Stop()
 
-	logger := log.WithFields(log.Fields{})
+	logger := log.WithFields(log.Fields{
+		"loop": loop.name,
+	})
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Warn("exiting due to context being done")
 			return
 		case <-ticker.C:
+			if ctx.Err() != nil {
+				// pigeon is shutting down, so don't start anything new
+				continue
+			}
 			if !requiresStaking || r.staking {
-				err := process(liblog.MustEnrichContext(ctx), locker)
+				// the iteration is allowed to finish even if pigeon is
+				// shutting down in the meantime
+				err := runProcess(liblog.MustEnrichContext(r.lifecycle.inFlightContext(ctx)), locker, loop.timeout, process)
 				if err != nil {
 					logger.Error(err)
 				}
@@ -62,38 +73,89 @@ func (r *Relayer) startProcess(ctx context.Context, locker sync.Locker, tickerIn
 	}
 }
 
+// runProcess runs a single iteration of a process. If the timeout is set,
+// the iteration gets cancelled once it runs for longer than that.
+func runProcess(ctx context.Context, locker sync.Locker, timeout time.Duration, process func(context.Context, sync.Locker) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return process(ctx, locker)
+}
+
+// startLoop starts the process in the background unless its loop has been
+// disabled.
+func (r *Relayer) startLoop(ctx context.Context, locker sync.Locker, name string, requiresStaking bool, process func(context.Context, sync.Locker) error) {
+	loop := r.relayerConfig.loop(name)
+	if !loop.enabled {
+		log.WithField("loop", name).Info("loop is disabled")
+		return
+	}
+
+	r.lifecycle.Go(func() {
+		r.startProcess(ctx, locker, loop, requiresStaking, process)
+	})
+}
+
 // Start starts the relayer. It's responsible for handling the communication
-// with Paloma and other chains.
+// with Paloma and other chains. It blocks until the context is done and all
+// of the work in flight has drained.
 func (r *Relayer) Start(ctx context.Context) error {
+	if err := r.relayerConfig.Validate(); err != nil {
+		return err
+	}
+
 	log.Info("starting pigeon")
 	var locker sync.Mutex
 
 	_ = r.checkStaking(ctx, &locker)
 
-	// Start background goroutines to run separately from each other
-	go r.startProcess(ctx, &locker, checkStakingLoopInterval, false, r.checkStaking)
-	go r.startProcess(ctx, &locker, updateExternalChainsLoopInterval, true, r.UpdateExternalChainInfos)
-	go r.startProcess(ctx, &locker, signMessagesLoopInterval, true, r.SignMessages)
-	go r.startProcess(ctx, &locker, relayMessagesLoopInterval, true, r.RelayMessages)
-	go r.startProcess(ctx, &locker, attestMessagesLoopInterval, true, r.AttestMessages)
+	// Immediately send a keep alive to Paloma during startup
+	if err := r.keepAlive(liblog.MustEnrichContext(ctx), &locker); err != nil {
+		log.WithError(err).Warn("unable to send the startup keep alive")
+	}
+
+	// Start background goroutines to run separately from each other. The
+	// locker here only guards the Paloma specific loops. Every chain gets its
+	// own set of workers and its own lock, which are managed by the
+	// UpdateChainWorkers loop.
+	r.scheduler.run(ctx)
+	r.startLoop(ctx, &locker, loopCheckStaking, false, r.checkStaking)
+	r.startLoop(ctx, &locker, loopUpdateChainWorkers, true, r.UpdateChainWorkers)
+	r.startLoop(ctx, &locker, loopUpdateExternalChainInfos, true, r.UpdateExternalChainInfos)
+	r.startLoop(ctx, &locker, loopKeepAlive, false, r.keepAlive)
+
+	if r.relayerConfig.SubscribeToPalomaEvents {
+		r.lifecycle.Go(func() {
+			r.watchPalomaEvents(ctx)
+		})
+	}
 
 	if !libvalid.IsNil(r.mevClient) {
-		go r.startProcess(ctx, &locker, r.mevClient.GetHealthprobeInterval(), false, r.mevClient.KeepAlive)
+		mevKeepAlive := loopConfig{
+			name:     "mev-keep-alive",
+			interval: r.mevClient.GetHealthprobeInterval(),
+			enabled:  true,
+		}
+		r.lifecycle.Go(func() {
+			r.startProcess(ctx, &locker, mevKeepAlive, false, r.mevClient.KeepAlive)
+		})
 	}
 
-	// Start gravity background goroutines to run separately from each other
-	go r.startProcess(ctx, &locker, gravitySignBatchesLoopInterval, true, r.GravitySignBatches)
-	go r.startProcess(ctx, &locker, gravityRelayBatchesLoopInterval, true, r.GravityRelayBatches)
-	go r.startProcess(ctx, &locker, batchSendEventWatcherLoopInterval, true, r.GravityHandleBatchSendEvent)
-	go r.startProcess(ctx, &locker, sendToPalomaEventWatcherLoopInterval, true, r.GravityHandleSendToPalomaEvent)
+	r.lifecycle.setState(stateRunning)
+	<-ctx.Done()
 
-	// Start the foreground process
-	r.startProcess(ctx, &locker, r.relayerConfig.KeepAliveLoopTimeout, false, r.keepAlive)
+	log.Info("stopping pigeon")
+	r.lifecycle.drain(r.shutdownTimeout())
+	return nil
+}
 
-	// Immediately send a keep alive to Paloma during startup
-	_ = r.keepAlive(liblog.MustEnrichContext(ctx), &locker)
+func (r *Relayer) shutdownTimeout() time.Duration {
+	if r.relayerConfig.ShutdownTimeout > 0 {
+		return r.relayerConfig.ShutdownTimeout
+	}
 
-	// Start the foreground process
-	r.startProcess(ctx, &locker, r.relayerConfig.KeepAliveLoopTimeout, false, r.keepAlive)
-	return nil
+	return defaultShutdownTimeout
 }
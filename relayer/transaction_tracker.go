@@ -0,0 +1,25 @@
+package relayer
+
+import (
+	"context"
+
+	"github.com/VolumeFi/whoops"
+	"github.com/palomachain/pigeon/chain"
+	"github.com/palomachain/pigeon/internal/liblog"
+	log "github.com/sirupsen/logrus"
+)
+
+func (r *Relayer) trackTransactions(ctx context.Context, processors []chain.Processor) error {
+	var g whoops.Group
+	for _, p := range processors {
+		if err := p.TrackTransactions(ctx); err != nil {
+			liblog.WithContext(ctx).WithError(err).WithFields(log.Fields{
+				"chain-reference-id": p.GetChainReferenceID(),
+				"action":             "track-transactions",
+			}).Error("couldn't track transactions")
+			g.Add(err)
+		}
+	}
+
+	return g.Return()
+}
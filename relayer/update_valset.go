@@ -23,7 +23,7 @@ func (r *Relayer) UpdateExternalChainInfos(ctx context.Context, locker sync.Lock
 
 	log.Info("updating external chain infos")
 	externalAccounts := slice.Map(
-		r.processors,
+		r.processors.List(),
 		func(p chain.Processor) chain.ExternalAccount {
 			return p.ExternalAccount()
 		},
@@ -49,6 +49,12 @@ func (r *Relayer) UpdateExternalChainInfos(ctx context.Context, locker sync.Lock
 		return info
 	})
 
+	// quarantined chains are left out, so that Paloma doesn't expect this
+	// pigeon to relay to them
+	if quarantined := r.quarantine.ChainReferenceIDs(); len(quarantined) > 0 {
+		log.WithField("chain-reference-ids", quarantined).Warn("not sending account info of quarantined chains to paloma")
+	}
+
 	if len(chainInfos) == 0 {
 		return nil
 	}